@@ -18,12 +18,15 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/nats-io/nack/controllers/jetstream"
 	clientset "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned"
@@ -52,15 +55,66 @@ func run() error {
 	kubeConfig := flag.String("kubeconfig", "", "Path to kubeconfig")
 	namespace := flag.String("namespace", v1.NamespaceAll, "Restrict to a namespace")
 	version := flag.Bool("version", false, "Print the version and exit")
+	export := flag.Bool("export", false, "Print all Stream and Consumer resources as a YAML manifest and exit")
+	importNATS := flag.Bool("import", false, "Print a Stream and Consumer resource, in -namespace or default, for every stream and durable consumer in NATS as a YAML manifest and exit")
+	reconcileAll := flag.Bool("reconcile-all", false, "Reconcile every Stream and Consumer once, print a summary and exit")
+	reconcileTimeout := flag.Duration("reconcile-timeout", 5*time.Minute, "How long -reconcile-all waits for all resources to be reconciled")
 	creds := flag.String("creds", "", "NATS Credentials")
 	nkey := flag.String("nkey", "", "NATS NKey")
+	tokenFile := flag.String("token-file", "", "File holding the NATS auth token, like a projected service account token, read again on every reconnect")
 	cert := flag.String("tlscert", "", "NATS TLS public certificate")
 	key := flag.String("tlskey", "", "NATS TLS private key")
 	ca := flag.String("tlsca", "", "NATS TLS certificate authority chain")
 	server := flag.String("s", "", "NATS Server URL")
+	serversFromSRV := flag.String("servers-from-srv", "", "SRV name, like _nats._tcp.example.com, to discover the NATS servers from instead of -s")
+	defaultCredsSecret := flag.String("default-creds-secret", "", "Secret key holding the NATS credentials of resources without their own, as namespace/name/key, used with -crd-connect")
 	crdConnect := flag.Bool("crd-connect", false, "If true, then NATS connections will be made from CRD config, not global config")
 	cleanupPeriod := flag.Duration("cleanup-period", 30*time.Second, "Period to run object cleanup")
+	workers := flag.Int("workers", 0, "Number of streams and of consumers reconciled at once, defaults to the number of CPUs up to 16")
 	readOnly := flag.Bool("read-only", false, "Starts the controller without causing changes to the NATS resources")
+	auditOnly := flag.Bool("audit-only", false, "Only compare consumers with NATS and report whether they are in sync, never changing them")
+	disableFinalizers := flag.Bool("disable-finalizers", false, "Never delete streams and consumers from NATS, leaving their lifecycle to be managed out-of-band")
+	recordLastApplied := flag.Bool("record-last-applied-config", false, "Store the config sent to NATS in the jetstream.nats.io/last-applied-config annotation")
+	deliverSubjectPreflight := flag.Bool("deliver-subject-preflight", false, "Warn before creating a push consumer whose deliver subject the user credentials can't publish to")
+	publishResults := flag.Bool("publish-results", false, "Publish the outcome of each reconcile on nack.events.<kind>.<namespace>.<name>, not available with -crd-connect")
+	strictConsumerReadiness := flag.Bool("strict-consumer-readiness", false, "Only mark consumers Ready once they are bound or have delivered messages")
+	clusterReconcileRate := flag.Float64("cluster-reconcile-rate", 0, "Maximum reconciles per second against any one NATS cluster, 0 for unlimited")
+	clusterReconcileBurst := flag.Int("cluster-reconcile-burst", 1, "Number of reconciles against a NATS cluster allowed at once before -cluster-reconcile-rate applies")
+	notFoundRequeueWindow := flag.Duration("not-found-requeue-window", 0, "How long to keep requeueing a resource missing from the informer cache before treating it as deleted, 0 to disable")
+	suppressNoopEvents := flag.Bool("suppress-noop-events", false, "Log Noop and SkipUpdate reconciles at debug level instead of recording events")
+	maxConcurrentConnections := flag.Int("max-concurrent-connections", 0, "Maximum number of NATS connections held by reconciles at once, 0 for unlimited")
+	maxStreamsPerNamespace := flag.Int("max-streams-per-namespace", 0, "Maximum number of streams created for the Stream resources of a namespace, 0 for unlimited")
+	ackSampleMetrics := flag.Bool("ack-sample-metrics", false, "Export the ack delays sampled by consumers with a sampleFreq as histograms under /debug/vars")
+	reconcileOnChangeOnly := flag.Bool("reconcile-on-change-only", false, "Skip reconciling streams and consumers whose current generation is already reconciled and Ready, at the cost of not reverting changes made in NATS")
+	streamRequeueInterval := flag.Duration("stream-requeue-interval", 0, "How long after a successful reconcile a stream is reconciled again, 0 to only reconcile it on changes")
+	consumerRequeueInterval := flag.Duration("consumer-requeue-interval", 0, "How long after a successful reconcile a consumer is reconciled again, 0 to only reconcile it on changes")
+	consumerUpdateStrategy := flag.String("consumer-update-strategy", jetstream.ConsumerUpdateWarn, "How changes of consumer fields NATS can't update in place are handled for consumers without their own updateStrategy or allowRecreate: warn, recreate or inplace")
+	subjectsFromConfigMaps := flag.Bool("subjects-from-configmaps", false, "Watch ConfigMaps so that streams can take subjects from them with subjectsFrom, caching every ConfigMap in the watched namespaces")
+	secretCacheTTL := flag.Duration("secret-cache-ttl", 0, "How long secrets read for credentials and TLS are reused between reconciles, 0 to disable")
+	streamCacheTTL := flag.Duration("stream-cache-ttl", 5*time.Second, "How long a stream found in NATS is cached between reconciles, 0 to disable")
+	managedByLabel := flag.Bool("managed-by-label", true, "Label reconciled streams and consumers with app.kubernetes.io/managed-by=nack, unless they already have the label")
+	connErrorCooldown := flag.Duration("connection-error-cooldown", 0, "How long identical NATS connection failures of a resource are neither recorded as events nor logged again, 0 to report all")
+	maxReconcileDuration := flag.Duration("max-reconcile-duration", 0, "Maximum duration of a single reconcile, after which its NATS and Kubernetes calls are cancelled, 0 for no limit")
+	lagThreshold := flag.Uint64("lag-threshold", 0, "Number of pending messages above which consumers have their Lagging condition set, 0 to disable")
+	lagSetAfter := flag.Int("lag-set-after", 1, "Consecutive reconciles a consumer must be over the lag threshold before it's marked lagging")
+	lagClearAfter := flag.Int("lag-clear-after", 1, "Consecutive reconciles a consumer must be within the lag threshold before it's no longer marked lagging")
+	stuckAfter := flag.Int("stuck-after", 0, "Consecutive status refreshes a consumer must redeliver more messages without its ack floor moving before it's marked stuck, 0 to disable")
+	jsDomain := flag.String("js-domain", "", "JetStream domain to manage streams and consumers in")
+	autoDiscoverDomain := flag.Bool("auto-discover-domain", false, "Use the JetStream domain of the connected server when -js-domain is unset")
+	capacityWarningPercent := flag.Int("capacity-warning-percent", 0, "Percentage of a stream's max bytes at which to warn it's near capacity, checked on status refresh, 0 to disable")
+	statusRefreshInterval := flag.Duration("status-refresh-interval", 0, "How often the live state of streams and consumers is refreshed into their status, 0 to disable")
+	stuckTerminatingThreshold := flag.Duration("stuck-terminating-threshold", 0, "Warn about streams and consumers deleted for longer than this that still have finalizers, 0 to disable")
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve expvar metrics on under /debug/vars and the health check under /healthz, empty to disable")
+	pauseConfigMap := flag.String("pause-configmap", "", "ConfigMap, as namespace/name, whose paused key pauses all reconciles while \"true\"")
+	adminAddr := flag.String("admin-addr", "", "Address to serve read-only admin endpoints on, like stream diffs under /diff/stream/<namespace>/<name>, empty to disable")
+	reseedAddr := flag.String("reseed-addr", "", "Address to serve consumer reseeds on under /reseed/stream/<namespace>/<name>, which delete and recreate consumers, empty to disable")
+	reseedTokenFile := flag.String("reseed-token-file", "", "File holding the bearer token reseed requests must carry, required with -reseed-addr")
+	reseedCert := flag.String("reseed-tlscert", "", "TLS certificate of the reseed endpoint")
+	reseedKey := flag.String("reseed-tlskey", "", "TLS private key of the reseed endpoint")
+	webhookAddr := flag.String("webhook-addr", "", "Address to serve the stream validating admission webhook on under /validate-streams, empty to disable")
+	webhookCert := flag.String("webhook-tlscert", "", "TLS certificate of the admission webhook")
+	webhookKey := flag.String("webhook-tlskey", "", "TLS private key of the admission webhook")
+	maxConditionMessageLength := flag.Int("max-condition-message-length", jetstream.DefaultMaxConditionMessageLength, "Maximum length of status condition messages, longer messages are truncated")
 	flag.Parse()
 
 	if *version {
@@ -68,10 +122,46 @@ func run() error {
 		return nil
 	}
 
-	if *server == "" && !*crdConnect {
+	if *server == "" && *serversFromSRV == "" && !*crdConnect && !*export {
 		return errors.New("NATS Server URL is required")
 	}
 
+	var defaultCreds jetstream.SecretKeyRef
+	if *defaultCredsSecret != "" {
+		parts := strings.Split(*defaultCredsSecret, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return fmt.Errorf("invalid default creds secret %q, want namespace/name/key", *defaultCredsSecret)
+		}
+		defaultCreds = jetstream.SecretKeyRef{Namespace: parts[0], Name: parts[1], Key: parts[2]}
+	}
+
+	if !jetstream.ValidConsumerUpdateStrategy(*consumerUpdateStrategy) {
+		return fmt.Errorf("invalid consumer update strategy %q, want warn, recreate or inplace", *consumerUpdateStrategy)
+	}
+
+	var reseedToken string
+	if *reseedAddr != "" {
+		if *reseedTokenFile == "" {
+			return errors.New("-reseed-token-file is required with -reseed-addr")
+		}
+		b, err := os.ReadFile(*reseedTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read reseed token: %w", err)
+		}
+		if reseedToken = strings.TrimSpace(string(b)); reseedToken == "" {
+			return fmt.Errorf("reseed token file %q is empty", *reseedTokenFile)
+		}
+	}
+
+	var pauseCM types.NamespacedName
+	if *pauseConfigMap != "" {
+		parts := strings.Split(*pauseConfigMap, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid pause configmap %q, want namespace/name", *pauseConfigMap)
+		}
+		pauseCM = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+
 	var config *rest.Config
 	var err error
 	if *kubeConfig == "" {
@@ -104,25 +194,121 @@ func run() error {
 	ctrl := jetstream.NewController(jetstream.Options{
 		// FIXME: Move context to be param from Run
 		// to avoid keeping state in options.
-		Ctx:             ctx,
-		NATSCredentials: *creds,
-		NATSNKey:        *nkey,
-		NATSServerURL:   *server,
-		NATSCA:          *ca,
-		NATSCertificate: *cert,
-		NATSKey:         *key,
-		KubeIface:       kc,
-		JetstreamIface:  jc,
-		Namespace:       *namespace,
-		CRDConnect:      *crdConnect,
-		CleanupPeriod:   *cleanupPeriod,
-		ReadOnly:        *readOnly,
+		Ctx:                       ctx,
+		NATSCredentials:           *creds,
+		NATSNKey:                  *nkey,
+		NATSTokenFile:             *tokenFile,
+		NATSServerURL:             *server,
+		ServersFromSRV:            *serversFromSRV,
+		NATSCA:                    *ca,
+		NATSCertificate:           *cert,
+		NATSKey:                   *key,
+		KubeIface:                 kc,
+		JetstreamIface:            jc,
+		Namespace:                 *namespace,
+		CRDConnect:                *crdConnect,
+		DefaultCredentialsSecret:  defaultCreds,
+		CleanupPeriod:             *cleanupPeriod,
+		ReadOnly:                  *readOnly,
+		AuditOnly:                 *auditOnly,
+		Workers:                   *workers,
+		StreamCacheTTL:            *streamCacheTTL,
+		SecretCacheTTL:            *secretCacheTTL,
+		DisableFinalizers:         *disableFinalizers,
+		MaxConcurrentConnections:  *maxConcurrentConnections,
+		MaxStreamsPerNamespace:    *maxStreamsPerNamespace,
+		AckSampleMetrics:          *ackSampleMetrics,
+		ReconcileOnChangeOnly:     *reconcileOnChangeOnly,
+		StreamRequeueInterval:     *streamRequeueInterval,
+		ConsumerRequeueInterval:   *consumerRequeueInterval,
+		ConsumerUpdateStrategy:    *consumerUpdateStrategy,
+		RecordLastAppliedConfig:   *recordLastApplied,
+		StrictConsumerReadiness:   *strictConsumerReadiness,
+		DeliverSubjectPreflight:   *deliverSubjectPreflight,
+		ClusterReconcileRate:      *clusterReconcileRate,
+		ClusterReconcileBurst:     *clusterReconcileBurst,
+		NotFoundRequeueWindow:     *notFoundRequeueWindow,
+		SuppressNoopEvents:        *suppressNoopEvents,
+		StuckTerminatingThreshold: *stuckTerminatingThreshold,
+		PauseConfigMap:            pauseCM,
+		SubjectsFromConfigMaps:    *subjectsFromConfigMaps,
+		PublishResults:            *publishResults,
+		StatusRefreshInterval:     *statusRefreshInterval,
+		CapacityWarningPercent:    *capacityWarningPercent,
+		JSDomain:                  *jsDomain,
+		AutoDiscoverDomain:        *autoDiscoverDomain,
+		LagThreshold:              *lagThreshold,
+		LagSetAfter:               *lagSetAfter,
+		LagClearAfter:             *lagClearAfter,
+		StuckAfter:                *stuckAfter,
+		ConnectionErrorCooldown:   *connErrorCooldown,
+		ManagedByLabel:            *managedByLabel,
+		MaxReconcileDuration:      *maxReconcileDuration,
+		MaxConditionMessageLength: *maxConditionMessageLength,
+		ReseedToken:               reseedToken,
 	})
 
+	if *export {
+		return ctrl.Export(os.Stdout)
+	}
+	if *importNATS {
+		ns := *namespace
+		if ns == v1.NamespaceAll {
+			ns = v1.NamespaceDefault
+		}
+		return ctrl.Import(os.Stdout, ns)
+	}
+	if *reconcileAll {
+		go handleSignals(cancel)
+		return ctrl.ReconcileAll(os.Stdout, *reconcileTimeout)
+	}
+
 	klog.Infof("Starting %s v%s...", os.Args[0], Version)
 	if *readOnly {
 		klog.Infof("Running in read-only mode: JetStream state in server will not be changed")
 	}
+	if *metricsAddr != "" {
+		ctrl.PublishMetrics()
+		http.HandleFunc("/healthz", ctrl.Healthz)
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, nil); err != nil {
+				klog.Errorf("failed to serve metrics: %s", err)
+			}
+		}()
+	}
+	if *webhookAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/validate-streams", ctrl.ValidateStreams)
+		go func() {
+			if err := http.ListenAndServeTLS(*webhookAddr, *webhookCert, *webhookKey, mux); err != nil {
+				klog.Errorf("failed to serve admission webhook: %s", err)
+			}
+		}()
+	}
+	if *adminAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc(jetstream.DiffStreamPath, ctrl.DiffStream)
+		go func() {
+			if err := http.ListenAndServe(*adminAddr, mux); err != nil {
+				klog.Errorf("failed to serve admin endpoints: %s", err)
+			}
+		}()
+	}
+	if *reseedAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc(jetstream.ReseedStreamPath, ctrl.ReseedStream)
+		go func() {
+			var err error
+			if *reseedCert != "" {
+				err = http.ListenAndServeTLS(*reseedAddr, *reseedCert, *reseedKey, mux)
+			} else {
+				err = http.ListenAndServe(*reseedAddr, mux)
+			}
+			if err != nil {
+				klog.Errorf("failed to serve reseed endpoint: %s", err)
+			}
+		}()
+	}
 	go handleSignals(cancel)
 	return ctrl.Run()
 }
@@ -0,0 +1,190 @@
+package jetstream
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	jsmetric "github.com/nats-io/jsm.go/api/jetstream/metric"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	"github.com/nats-io/nats.go"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// ackSampleMetricsAnnotation, set to "true" on a consumer, exports its
+	// ack samples even without AckSampleMetrics.
+	ackSampleMetricsAnnotation = "jetstream.nats.io/ack-sample-metrics"
+
+	// ackDelayVar is the expvar name of the ack delay histograms.
+	ackDelayVar = "jetstream_consumer_ack_delay_seconds"
+)
+
+// ackDelayBuckets are the upper bounds, in seconds, of the ack delay
+// histogram buckets.
+var ackDelayBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// ackSubscriber subscribes to the ack samples of consumers, as done by
+// nats.Conn.
+type ackSubscriber interface {
+	Subscribe(subject string, cb nats.MsgHandler) (*nats.Subscription, error)
+}
+
+// ackDelayHistogram is a cumulative histogram of ack delays, in the bucket
+// layout of Prometheus, published as JSON.
+type ackDelayHistogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newAckDelayHistogram() *ackDelayHistogram {
+	return &ackDelayHistogram{counts: make([]uint64, len(ackDelayBuckets))}
+}
+
+func (h *ackDelayHistogram) observe(d time.Duration) {
+	s := d.Seconds()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, le := range ackDelayBuckets {
+		if s <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += s
+}
+
+// String implements expvar.Var.
+func (h *ackDelayHistogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make(map[string]uint64, len(ackDelayBuckets)+1)
+	for i, le := range ackDelayBuckets {
+		buckets[strconv.FormatFloat(le, 'g', -1, 64)] = h.counts[i]
+	}
+	buckets["+Inf"] = h.count
+	data, _ := json.Marshal(struct {
+		Buckets map[string]uint64 `json:"buckets"`
+		Count   uint64            `json:"count"`
+		Sum     float64           `json:"sum"`
+	}{buckets, h.count, h.sum})
+	return string(data)
+}
+
+// ackSampler subscribes to the ack samples of the consumers that have them
+// exported, and keeps a histogram of their ack delays per consumer. Only
+// consumers with a sample frequency publish samples, so nothing is
+// subscribed to for the others.
+type ackSampler struct {
+	// sub subscribes to ack samples, nil unless the controller has its own
+	// NATS connection.
+	sub ackSubscriber
+
+	mu   sync.Mutex
+	subs map[string]*nats.Subscription
+
+	// delays holds an *ackDelayHistogram per consumer resource.
+	delays *expvar.Map
+}
+
+func newAckSampler() *ackSampler {
+	return &ackSampler{
+		subs:   make(map[string]*nats.Subscription),
+		delays: new(expvar.Map).Init(),
+	}
+}
+
+// ackSampleSubject returns the subject the server publishes the ack samples
+// of a consumer on.
+func ackSampleSubject(stream, consumer string) string {
+	return fmt.Sprintf("%s.%s.%s", jsmapi.JSMetricConsumerAckPre, stream, consumer)
+}
+
+// watch subscribes to the ack samples of the consumer at key, unless it
+// already is. A consumer that's been watched before starts over with an
+// empty histogram.
+func (s *ackSampler) watch(key, stream, consumer string) error {
+	subject := ackSampleSubject(stream, consumer)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.subs[key]; ok {
+		if old.Subject == subject {
+			return nil
+		}
+		// The consumer was renamed or moved to another stream.
+		if err := old.Unsubscribe(); err != nil {
+			klog.V(4).Infof("failed to unsubscribe from ack samples of consumer %s: %s", key, err)
+		}
+		delete(s.subs, key)
+	}
+
+	h := newAckDelayHistogram()
+	sub, err := s.sub.Subscribe(subject, func(m *nats.Msg) {
+		var sample jsmetric.ConsumerAckMetricV1
+		if err := json.Unmarshal(m.Data, &sample); err != nil {
+			klog.V(4).Infof("ignoring ack sample of consumer %s: %s", key, err)
+			return
+		}
+		h.observe(time.Duration(sample.Delay))
+	})
+	if err != nil {
+		return err
+	}
+	s.subs[key] = sub
+	s.delays.Set(key, h)
+	return nil
+}
+
+// forget unsubscribes from the ack samples of the consumer at key and drops
+// its histogram.
+func (s *ackSampler) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[key]
+	if !ok {
+		return
+	}
+	if err := sub.Unsubscribe(); err != nil {
+		klog.V(4).Infof("failed to unsubscribe from ack samples of consumer %s: %s", key, err)
+	}
+	delete(s.subs, key)
+	s.delays.Delete(key)
+}
+
+// ackSamplingEnabled returns whether the ack samples of cns should be
+// exported: it needs a sample frequency, and either AckSampleMetrics or the
+// ack-sample-metrics annotation.
+func (c *Controller) ackSamplingEnabled(cns *apis.Consumer) bool {
+	freq := strings.TrimSuffix(cns.Spec.SampleFreq, "%")
+	if freq == "" || freq == "0" || cns.DeletionTimestamp != nil {
+		return false
+	}
+	return c.opts.AckSampleMetrics || cns.Annotations[ackSampleMetricsAnnotation] == "true"
+}
+
+// syncAckSamples subscribes to or unsubscribes from the ack samples of cns
+// after it's reconciled. It's best-effort, failures are only logged.
+func (c *Controller) syncAckSamples(cns *apis.Consumer) {
+	if c.acks.sub == nil {
+		return
+	}
+	key := objectKey(cns.Namespace, cns.Name)
+	durable, err := resolveDurableName(cns)
+	if err != nil || !c.ackSamplingEnabled(cns) {
+		c.acks.forget(key)
+		return
+	}
+	if err := c.acks.watch(key, cns.Spec.StreamName, durable); err != nil {
+		klog.Infof("failed to subscribe to ack samples of consumer %s: %s", key, err)
+	}
+}
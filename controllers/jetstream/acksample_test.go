@@ -0,0 +1,163 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	jsmetric "github.com/nats-io/jsm.go/api/jetstream/metric"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nats.go"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+type mockAckSubscriber struct {
+	handlers map[string]nats.MsgHandler
+}
+
+func (s *mockAckSubscriber) Subscribe(subject string, cb nats.MsgHandler) (*nats.Subscription, error) {
+	s.handlers[subject] = cb
+	return &nats.Subscription{Subject: subject}, nil
+}
+
+func TestAckSampleMetrics(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+	sub := &mockAckSubscriber{handlers: make(map[string]nats.MsgHandler)}
+	ctrl.acks.sub = sub
+
+	cns := &apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:   "default",
+			Name:        "my-consumer",
+			Annotations: map[string]string{ackSampleMetricsAnnotation: "true"},
+		},
+		Spec: apis.ConsumerSpec{
+			StreamName:  "orders",
+			DurableName: "processor",
+			SampleFreq:  "50",
+		},
+	}
+	ctrl.syncAckSamples(cns)
+
+	handler := sub.handlers["$JS.EVENT.METRIC.CONSUMER.ACK.orders.processor"]
+	if handler == nil {
+		t.Fatalf("got subscriptions %v; want the ack samples of the consumer", sub.handlers)
+	}
+	for _, d := range []time.Duration{20 * time.Millisecond, 2 * time.Second} {
+		data, err := json.Marshal(jsmetric.ConsumerAckMetricV1{
+			Stream:   "orders",
+			Consumer: "processor",
+			Delay:    int64(d),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler(&nats.Msg{Data: data})
+	}
+	handler(&nats.Msg{Data: []byte("garbage")})
+
+	h, ok := ctrl.acks.delays.Get("default/my-consumer").(*ackDelayHistogram)
+	if !ok {
+		t.Fatal("missing ack delay histogram")
+	}
+	var got struct {
+		Buckets map[string]uint64 `json:"buckets"`
+		Count   uint64            `json:"count"`
+		Sum     float64           `json:"sum"`
+	}
+	if err := json.Unmarshal([]byte(h.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 2 || got.Sum != 2.02 {
+		t.Fatalf("got count=%d sum=%g; want count=2 sum=2.02", got.Count, got.Sum)
+	}
+	for le, want := range map[string]uint64{"0.01": 0, "0.025": 1, "1": 1, "2.5": 2, "+Inf": 2} {
+		if got.Buckets[le] != want {
+			t.Errorf("bucket %s: got=%d; want=%d", le, got.Buckets[le], want)
+		}
+	}
+
+	// A durable name with placeholders is subscribed to as resolved.
+	tmpl := cns.DeepCopy()
+	tmpl.Name = "templated"
+	tmpl.Spec.DurableName = "{{.Namespace}}-processor"
+	ctrl.syncAckSamples(tmpl)
+	if sub.handlers["$JS.EVENT.METRIC.CONSUMER.ACK.orders.default-processor"] == nil {
+		t.Fatalf("got subscriptions %v; want the ack samples of the resolved durable", sub.handlers)
+	}
+
+	// Without a sample frequency the samples are no longer exported.
+	cns.Spec.SampleFreq = ""
+	ctrl.syncAckSamples(cns)
+	if ctrl.acks.delays.Get("default/my-consumer") != nil {
+		t.Fatal("got an ack delay histogram; want it dropped")
+	}
+}
+
+func TestAckSamplesOnChangeOnly(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        clientsetfake.NewSimpleClientset(),
+		Recorder:              record.NewFakeRecorder(10),
+		ReconcileOnChangeOnly: true,
+	})
+	sub := &mockAckSubscriber{handlers: make(map[string]nats.MsgHandler)}
+	ctrl.acks.sub = sub
+
+	ns, name := "default", "my-consumer"
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:   ns,
+			Name:        name,
+			Generation:  1,
+			Annotations: map[string]string{ackSampleMetricsAnnotation: "true"},
+		},
+		Spec: apis.ConsumerSpec{
+			StreamName:  "orders",
+			DurableName: "processor",
+			SampleFreq:  "50",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+				Conditions: []apis.Condition{{
+					Type:   readyCondType,
+					Status: k8sapi.ConditionTrue,
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the Ready consumer is skipped, but its ack samples
+	// are subscribed to again.
+	jsmc := &mockJsmClient{}
+	if err := ctrl.processConsumer(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if jsmc.newConsumers != 0 {
+		t.Fatalf("got %d consumers created; want the consumer skipped", jsmc.newConsumers)
+	}
+	if sub.handlers["$JS.EVENT.METRIC.CONSUMER.ACK.orders.processor"] == nil {
+		t.Fatalf("got subscriptions %v; want the ack samples of the skipped consumer", sub.handlers)
+	}
+}
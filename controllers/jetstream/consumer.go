@@ -2,12 +2,17 @@ package jetstream
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/nats-io/jsm.go"
@@ -19,28 +24,90 @@ import (
 	k8sapi "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/util/retry"
 	klog "k8s.io/klog/v2"
 )
 
 func (c *Controller) runConsumerQueue() {
 	for {
-		processQueueNext(c.cnsQueue, &realJsmClient{jm: c.jm}, c.processConsumer)
+		processQueueNext(c.cnsQueue, c.jsmClient(), c.whenResumed(c.processConsumer))
 	}
 }
 
-func (c *Controller) processConsumer(ns, name string, jsmc jsmClient) (err error) {
+func (c *Controller) processConsumer(ns, name string, jsmc jsmClient) error {
+	return c.reconcileConsumer(ns, name, jsmc, nil)
+}
+
+// reconcileConsumer reconciles the consumer ns/name, recording what was done in res
+// unless it's nil.
+func (c *Controller) reconcileConsumer(ns, name string, jsmc jsmClient, res *ReconcileResult) (err error) {
+	defer func() {
+		if err != nil && res != nil {
+			res.Action = ActionErrored
+		}
+	}()
+
 	cns, err := c.cnsLister.Consumers(ns).Get(name)
 	if err != nil && k8serrors.IsNotFound(err) {
+		if c.requeueNotFound(c.cnsQueue, "consumer", ns, name) {
+			klog.V(4).Infof("consumer %s/%s not found, requeued", ns, name)
+		} else {
+			c.acks.forget(objectKey(ns, name))
+			c.dlq.forget(objectKey(ns, name))
+		}
 		return nil
 	} else if err != nil {
 		return err
 	}
+	c.notFound.forget(outcomeKey("consumer", ns, name))
+
+	until, paused, err := pausedUntil(cns, time.Now())
+	if err != nil {
+		c.warningEvent(cns, "InvalidAnnotation", err.Error())
+	} else if paused {
+		c.normalEvent(cns, "PausedUntil", fmt.Sprintf("Reconcile of consumer %q paused until %s", cns.Spec.DurableName, until.Format(time.RFC3339)))
+		c.cnsQueue.AddAfter(fmt.Sprintf("%s/%s", ns, name), time.Until(until))
+		c.outcomes.skip("consumer", ns, name, fmt.Sprintf("paused until %s", until.Format(time.RFC3339)))
+		return nil
+	}
+	if !c.forced.take(outcomeKey("consumer", ns, name)) && c.unchangedSinceReconcile(cns, cns.Spec, cns.Status.Status) {
+		// The ack sample and max deliveries subscriptions only live in
+		// memory, so they're restored for skipped consumers after a restart.
+		c.syncAckSamples(cns)
+		c.syncDeadLetters(cns)
+		klog.V(4).Infof("consumer %s/%s unchanged since its last reconcile, skipped", ns, name)
+		return nil
+	}
 
-	return c.processConsumerObject(cns, jsmc)
+	ctx, cancel := c.reconcileContext()
+	defer cancel()
+	ctx = withResult(ctx, res)
+	err = c.processConsumerObject(ctx, cns, jsmc)
+	if err == nil {
+		c.syncAckSamples(cns)
+		c.syncDeadLetters(cns)
+	}
+	c.warnReconcileTimeout(cns, err)
+	err = c.reportConnectError(cns, outcomeKey("consumer", ns, name), err)
+	c.waitingForLeader(cns, err)
+	c.publishResult("consumer", cns, err)
+	c.outcomes.record("consumer", ns, name, err)
+	c.metrics.record(ns, err)
+
+	// The resource may have been edited again while it was reconciled, in
+	// which case the latest spec is reconciled too.
+	if err == nil {
+		if cur, gerr := c.cnsLister.Consumers(ns).Get(name); gerr == nil && specHash(cur.Spec) != specHash(cns.Spec) {
+			klog.V(2).Infof("consumer %s/%s changed during reconcile, requeued", ns, name)
+			c.cnsQueue.Add(objectKey(ns, name))
+		}
+		c.requeueAfterSuccess(c.cnsQueue, cns, c.opts.ConsumerRequeueInterval)
+	}
+	return err
 }
 
-func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (err error) {
+func (c *Controller) processConsumerObject(ctx context.Context, cns *apis.Consumer, jsmc jsmClient) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("failed to process consumer: %w", err)
@@ -48,7 +115,10 @@ func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (
 	}()
 
 	ns := cns.Namespace
-	spec := cns.Spec
+	spec, err := c.mutateConsumerSpec(cns)
+	if err != nil {
+		return err
+	}
 	ifc := c.ji.Consumers(ns)
 
 	var (
@@ -56,6 +126,9 @@ func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (
 		remoteClientKey  string
 		remoteRootCA     string
 		accServers       []string
+		accContextOpts   []nats.Option
+		accContextCreds  string
+		accUserCreds     string
 	)
 	if spec.Account != "" && c.opts.CRDConnect {
 		// Lookup the account using the REST client.
@@ -71,7 +144,7 @@ func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (
 		// Lookup the TLS secrets
 		if acc.Spec.TLS != nil && acc.Spec.TLS.Secret != nil {
 			secretName := acc.Spec.TLS.Secret.Name
-			secret, err := c.ki.Secrets(ns).Get(c.ctx, secretName, k8smeta.GetOptions{})
+			secret, err := c.getSecret(ctx, ns, secretName)
 			if err != nil {
 				return err
 			}
@@ -92,19 +165,74 @@ func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (
 				}
 			}
 		}
-		// FIXME: Add support for UserCredentials for consumer.
+		// Lookup the UserCredentials.
+		if acc.Spec.Creds != nil {
+			accUserCreds, err = c.getCreds(ctx, ns, spec.Account, acc.Spec.Creds)
+			if err != nil {
+				return err
+			}
+		}
+		// Lookup the NATS context.
+		if acc.Spec.Context != nil {
+			servers, opts, creds, err := c.loadAccountContext(ns, spec.Account, acc.Spec.Context)
+			if err != nil {
+				return err
+			}
+			accServers = append(accServers, servers...)
+			accContextOpts = opts
+			accContextCreds = creds
+		}
+	}
+
+	if err := c.clusterLimiter.wait(ctx, c.clusterServers(spec.Servers, accServers)); err != nil {
+		return err
 	}
 
 	defer func() {
 		if err == nil {
 			return
 		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = &reconcileTimeoutError{timeout: c.opts.MaxReconcileDuration, err: err}
+		}
+		// A leader election isn't a failure of the resource, it's retried
+		// without marking it errored.
+		if classifyError(err) == errKindNoLeader {
+			return
+		}
 
-		if _, serr := setConsumerErrored(c.ctx, cns, ifc, err); serr != nil {
+		if _, serr := setConsumerErrored(c.ctx, cns, ifc, err, c.opts.MaxConditionMessageLength); serr != nil {
 			err = fmt.Errorf("%s: %w", err, serr)
 		}
 	}()
 
+	spec.DurableName, err = resolveDurableName(cns)
+	if err != nil {
+		c.warningEvent(cns, "InvalidName", err.Error())
+		return err
+	}
+	// An empty stream name is left to jsm.go, which refuses it before
+	// sending any request.
+	if spec.StreamName != "" {
+		if err := validateName("stream name", spec.StreamName); err != nil {
+			c.warningEvent(cns, "InvalidName", err.Error())
+			return err
+		}
+	}
+
+	if err := validateDeadLetterSubject(spec); err != nil {
+		c.warningEvent(cns, "InvalidDeadLetterSubject", err.Error())
+		return err
+	}
+	if spec.DeadLetterSubject != "" && spec.MaxDeliver <= 0 {
+		c.warningEvent(cns, "DeadLettersUnused", fmt.Sprintf("Dead letter subject %q is unused without maxDeliver", spec.DeadLetterSubject))
+	}
+
+	// The resolved durable name is recorded in status once NATS has
+	// accepted the consumer.
+	resolved := cns.DeepCopy()
+	resolved.Status.ConsumerName = spec.DurableName
+
 	type operator func(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (err error)
 
 	natsClientUtil := func(op operator) error {
@@ -113,9 +241,14 @@ func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (
 			// Create a new client
 			opts := make([]nats.Option, 0)
 			opts = append(opts, nats.Name(fmt.Sprintf("%s-con-%s-%d", c.opts.NATSClientName, spec.DurableName, cns.Generation)))
-			// Use JWT/NKEYS based credentials if present.
-			if spec.Creds != "" {
-				opts = append(opts, nats.UserCredentials(spec.Creds))
+			// Use JWT/NKEYS based credentials if present, or the default
+			// credentials if the consumer has none of its own.
+			creds, err := c.resourceCreds(spec.Creds, spec.Nkey != "" || accUserCreds != "" || accContextOpts != nil)
+			if err != nil {
+				return err
+			}
+			if creds != "" {
+				opts = append(opts, nats.UserCredentials(creds))
 			} else if spec.Nkey != "" {
 				opt, err := nats.NkeyOptionFromSeed(spec.Nkey)
 				if err != nil {
@@ -134,102 +267,473 @@ func (c *Controller) processConsumerObject(cns *apis.Consumer, jsmc jsmClient) (
 			if remoteRootCA != "" {
 				opts = append(opts, nats.RootCAs(remoteRootCA))
 			}
+			if accUserCreds != "" {
+				opts = append(opts, nats.UserCredentials(accUserCreds))
+			}
 
 			if len(spec.TLS.RootCAs) > 0 {
 				opts = append(opts, nats.RootCAs(spec.TLS.RootCAs...))
 			}
 
+			opts = append(opts, accContextOpts...)
 			opts = append(opts, nats.MaxReconnects(-1))
 
+			extraOpts, err := getNATSOptions(spec.NATSOptions)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, extraOpts...)
+
+			release, err := c.acquireConn(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
 			natsServers := strings.Join(append(servers, accServers...), ",")
 			newNc, err := nats.Connect(natsServers, opts...)
 			if err != nil {
-				return fmt.Errorf("failed to connect to leaf nats(%s): %w", natsServers, err)
+				return &connectError{servers: natsServers, err: err}
 			}
+			// Deferred after release, so the connection is closed before
+			// its slot is given back, whether the operation failed or not.
+			newJsmc := &realJsmClient{nc: newNc}
+			defer newJsmc.Close()
 
 			c.normalEvent(cns, "Connecting", "Connecting to new nats-servers")
-			newJm, err := jsm.New(newNc)
+			newJsmc.jm, newJsmc.domain, err = c.newJsmManager(newNc)
 			if err != nil {
 				return err
 			}
-			newJsmc := &realJsmClient{nc: newNc, jm: newJm}
 
-			if err := op(c.ctx, newJsmc, spec); err != nil {
+			if err := op(ctx, newJsmc, spec); err != nil {
 				return err
 			}
-			newJsmc.Close()
+			resolved.Status.Domain = newJsmc.Domain()
 		} else {
-			if err := op(c.ctx, jsmc, spec); err != nil {
+			if err := op(ctx, jsmc, spec); err != nil {
 				return err
 			}
+			resolved.Status.Domain = jsmc.Domain()
 		}
 		return nil
 	}
 
 	deleteOK := cns.GetDeletionTimestamp() != nil
-	newGeneration := cns.Generation != cns.Status.ObservedGeneration
+	newGeneration := cns.Generation != cns.Status.ObservedGeneration ||
+		(cns.Status.ObservedSpecHash != "" && cns.Status.ObservedSpecHash != specHash(cns.Spec))
+
+	// NATS can't rename a durable, a consumer last created under another
+	// name is created again under the new one, and the old one deleted when
+	// recreating is allowed.
+	if old := cns.Status.ConsumerName; old != "" && old != spec.DurableName && !deleteOK {
+		if c.consumerUpdateStrategy(spec) == ConsumerUpdateRecreate && !spec.PreventDelete {
+			c.normalEvent(cns, "Renaming", fmt.Sprintf("Renaming consumer %q on stream %q to %q", old, spec.StreamName, spec.DurableName))
+			err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) error {
+				spec.DurableName = old
+				return deleteConsumer(ctx, jc, spec)
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			c.warningEvent(cns, "Renamed", fmt.Sprintf("Consumer %q on stream %q was renamed to %q and is left in place, set allowRecreate or the recreate update strategy to delete it",
+				old, spec.StreamName, spec.DurableName))
+		}
+	}
 	consumerOK := true
 	err = natsClientUtil(consumerExists)
 	var apierr jsmapi.ApiError
 	if errors.As(err, &apierr) && apierr.NotFoundError() {
 		consumerOK = false
 	} else if err != nil {
+		if classifyError(err) == errKindJetStreamNotEnabled {
+			c.warningEvent(cns, "JetStreamNotEnabled", "JetStream not enabled for this account")
+		}
+		return err
+	}
+	if c.opts.AuditOnly {
+		var drifted []string
+		if consumerOK {
+			err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+				drifted, err = consumerDrift(ctx, jc, spec)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		_, err := setConsumerAudited(ctx, resolved, ifc, consumerOK, drifted)
 		return err
 	}
 	updateOK := (consumerOK && !deleteOK && newGeneration)
 	createOK := (!consumerOK && !deleteOK && newGeneration)
+	if (createOK || updateOK) && spec.DeliverSubject != "" && spec.DeliverGroup == "" {
+		c.warnSharedDeliverSubject(cns, spec.DeliverSubject)
+	}
+
+	// A durable recorded against an earlier stream of the same name was
+	// orphaned when its stream got deleted and recreated.
+	var orphaned bool
+	if !deleteOK {
+		var created string
+		err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+			created, err = streamCreated(ctx, jc, spec.StreamName)
+			return err
+		})
+		if err != nil && !(errors.As(err, &apierr) && apierr.NotFoundError()) {
+			return err
+		}
+		if created != "" {
+			orphaned = cns.Status.StreamCreated != "" && cns.Status.StreamCreated != created
+			resolved.Status.StreamCreated = created
+		}
+	}
+	if orphaned {
+		c.warningEvent(cns, "Orphaned",
+			fmt.Sprintf("Stream %q of consumer %q was recreated", spec.StreamName, spec.DurableName))
+		if !consumerOK {
+			createOK, updateOK = true, false
+		}
+	}
+
+	// setOK marks the consumer as created and, unless strict readiness
+	// finds it isn't active yet, as ready. It also refreshes the live state
+	// of the consumer when due, and its Lagging and Stuck conditions.
+	setOK := func() error {
+		// Strict readiness, the live state and the lag all need the state of
+		// the consumer, it's fetched once for all of them.
+		now := time.Now()
+		liveStateDue := c.liveStateDue(resolved.Status.State, now)
+		var (
+			state    jsmapi.ConsumerInfo
+			stateErr error
+		)
+		if c.opts.StrictConsumerReadiness || liveStateDue || c.opts.LagThreshold > 0 {
+			stateErr = natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+				state, err = consumerState(ctx, jc, spec)
+				return err
+			})
+		}
+
+		ready := true
+		if c.opts.StrictConsumerReadiness {
+			if stateErr != nil {
+				return stateErr
+			}
+			ready = consumerActive(state)
+		}
+		if stateErr != nil {
+			klog.Infof("failed to get state of consumer %q: %s", spec.DurableName, stateErr)
+		} else {
+			if liveStateDue {
+				prev := resolved.Status.State
+				resolved.Status.State = &apis.LiveState{
+					NumPending:     state.NumPending,
+					NumAckPending:  state.NumAckPending,
+					NumRedelivered: state.NumRedelivered,
+					AckFloor:       state.AckFloor.Stream,
+					RefreshedAt:    now.UTC().Format(time.RFC3339),
+				}
+				c.updateStuck(resolved, prev)
+			}
+			if c.opts.LagThreshold > 0 {
+				c.updateLagging(resolved, state.NumPending)
+			}
+		}
+		if _, err := setConsumerCreated(ctx, resolved, ifc, ready); err != nil {
+			return err
+		}
+		if !ready {
+			c.cnsQueue.AddAfter(fmt.Sprintf("%s/%s", cns.Namespace, cns.Name), consumerReadyRecheckInterval)
+		}
+		return nil
+	}
 
 	switch {
 	case createOK:
 		c.normalEvent(cns, "Creating",
 			fmt.Sprintf("Creating consumer %q on stream %q", spec.DurableName, spec.StreamName))
+		if c.opts.DeliverSubjectPreflight && spec.DeliverSubject != "" {
+			// The preflight reads the same creds the connection uses.
+			creds := c.opts.NATSCredentials
+			var credsErr error
+			if c.opts.CRDConnect {
+				creds, credsErr = c.connectionCreds(spec.Creds, spec.Nkey != "", accUserCreds, accContextCreds, accContextOpts != nil)
+			}
+			if credsErr != nil {
+				klog.Infof("Skipping deliver subject preflight of consumer %q: %s", spec.DurableName, credsErr)
+			} else {
+				c.preflightDeliverSubject(cns, spec, creds)
+			}
+		}
+		var conflict string
+		err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+			conflict, err = workQueueConflict(ctx, jc, spec)
+			return err
+		})
+		if err != nil {
+			klog.Infof("Skipping workqueue validation of consumer %q: %s", spec.DurableName, err)
+		} else if conflict != "" {
+			msg := fmt.Sprintf("Workqueue stream %q allows only one consumer per filter, consumer %q overlaps consumer %q",
+				spec.StreamName, spec.DurableName, conflict)
+			c.warningEvent(cns, "WorkQueueConflict", msg)
+			return errors.New(msg)
+		}
 		if err := natsClientUtil(createConsumer); err != nil {
+			c.warnUnsupportedFeature(cns, err)
+			if classifyError(err) == errKindWorkQueueConflict {
+				c.warningEvent(cns, "WorkQueueConflict",
+					fmt.Sprintf("Workqueue stream %q allows only one consumer per filter, consumer %q overlaps an existing one", spec.StreamName, spec.DurableName))
+			}
+			if spec.DeliverSubject != "" && classifyError(err) == errKindPermissionDenied {
+				c.warningEvent(cns, "PermissionDenied",
+					fmt.Sprintf("PermissionDenied for deliver subject %q of consumer %q", spec.DeliverSubject, spec.DurableName))
+			}
 			return err
 		}
 
-		if _, err := setConsumerOK(c.ctx, cns, ifc); err != nil {
+		if err := setOK(); err != nil {
 			return err
 		}
-		c.normalEvent(cns, "Created",
-			fmt.Sprintf("Created consumer %q on stream %q", spec.DurableName, spec.StreamName))
+		if c.opts.RecordLastAppliedConfig {
+			if err := setConsumerLastApplied(ctx, cns, ifc, spec); err != nil {
+				return err
+			}
+		}
+		if c.opts.ManagedByLabel {
+			if err := setConsumerManagedBy(ctx, cns, ifc); err != nil {
+				return err
+			}
+		}
+		if orphaned {
+			c.normalEvent(cns, "Recreated",
+				fmt.Sprintf("Recreated consumer %q on stream %q", spec.DurableName, spec.StreamName))
+		} else {
+			c.normalEvent(cns, "Created",
+				fmt.Sprintf("Created consumer %q on stream %q", spec.DurableName, spec.StreamName))
+		}
+		recordConsumerResult(ctx, ActionCreated, spec)
 	case updateOK:
 		if cns.Spec.PreventUpdate {
-			c.normalEvent(cns, "SkipUpdate", fmt.Sprintf("Skip updating consumer %q on stream %q", spec.DurableName, spec.StreamName))
-			if _, err := setConsumerOK(c.ctx, cns, ifc); err != nil {
+			c.noopEvent(cns, "SkipUpdate", fmt.Sprintf("Skip updating consumer %q on stream %q", spec.DurableName, spec.StreamName))
+			if _, err := setConsumerOK(ctx, resolved, ifc); err != nil {
 				return err
 			}
 			return nil
 		}
+
+		var immutable []string
+		err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+			immutable, err = consumerImmutableChanges(ctx, jc, spec)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		var ackPolicyChanged bool
+		for _, f := range immutable {
+			ackPolicyChanged = ackPolicyChanged || f == "ackPolicy"
+		}
+		if ackPolicyChanged && spec.AckPolicy == "none" {
+			c.warningEvent(cns, "AckPolicyDowngrade", fmt.Sprintf("Changing consumer %q on stream %q to ackPolicy none drops delivery guarantees, "+
+				"messages count as processed once sent and are never redelivered", spec.DurableName, spec.StreamName))
+		}
+		strategy := c.consumerUpdateStrategy(spec)
+		if len(immutable) > 0 && strategy == ConsumerUpdateRecreate && !spec.PreventDelete {
+			recreate := spec
+			if spec.RecreateFromAckFloor {
+				var floor uint64
+				err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+					floor, err = consumerAckFloor(ctx, jc, spec)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+				if floor > 0 {
+					recreate.DeliverPolicy = "byStartSequence"
+					recreate.OptStartSeq = int(floor + 1)
+					recreate.OptStartTime = ""
+					c.normalEvent(cns, "ResumingFromAckFloor", fmt.Sprintf("Recreated consumer %q on stream %q will start at sequence %d, after the ack floor",
+						spec.DurableName, spec.StreamName, recreate.OptStartSeq))
+				}
+			}
+
+			c.normalEvent(cns, "Recreating", fmt.Sprintf("Recreating consumer %q on stream %q to change %s",
+				spec.DurableName, spec.StreamName, strings.Join(immutable, ", ")))
+			if err := natsClientUtil(deleteConsumer); err != nil {
+				return err
+			}
+			err = natsClientUtil(func(ctx context.Context, jc jsmClient, _ apis.ConsumerSpec) error {
+				return createConsumer(ctx, jc, recreate)
+			})
+			if err != nil {
+				c.warnUnsupportedFeature(cns, err)
+				return err
+			}
+			if err := setOK(); err != nil {
+				return err
+			}
+			c.normalEvent(cns, "Recreated", fmt.Sprintf("Recreated consumer %q on stream %q", spec.DurableName, spec.StreamName))
+			recordConsumerResult(ctx, ActionUpdated, recreate)
+			return nil
+		} else if len(immutable) > 0 && strategy != ConsumerUpdateInPlace {
+			c.warningEvent(cns, "ImmutableChange", fmt.Sprintf("Consumer %q on stream %q can't be updated to change %s, set allowRecreate or the recreate update strategy to recreate it",
+				spec.DurableName, spec.StreamName, strings.Join(immutable, ", ")))
+		}
+
 		c.normalEvent(cns, "Updating", fmt.Sprintf("Updating consumer %q on stream %q", spec.DurableName, spec.StreamName))
 		if err := natsClientUtil(updateConsumer); err != nil {
+			c.warnUnsupportedFeature(cns, err)
 			return err
 		}
 
-		if _, err := setConsumerOK(c.ctx, cns, ifc); err != nil {
+		if err := setOK(); err != nil {
 			return err
 		}
+		if c.opts.RecordLastAppliedConfig {
+			if err := setConsumerLastApplied(ctx, cns, ifc, spec); err != nil {
+				return err
+			}
+		}
+		if c.opts.ManagedByLabel {
+			if err := setConsumerManagedBy(ctx, cns, ifc); err != nil {
+				return err
+			}
+		}
 		c.normalEvent(cns, "Updated", fmt.Sprintf("Updated consumer %q on stream %q", spec.DurableName, spec.StreamName))
+		recordConsumerResult(ctx, ActionUpdated, spec)
 	case deleteOK:
-		if cns.Spec.PreventDelete {
+		if cns.Spec.PreventDelete || c.opts.DisableFinalizers {
 			c.normalEvent(cns, "SkipDelete", fmt.Sprintf("Skip deleting consumer %q on stream %q", spec.DurableName, spec.StreamName))
-			if _, err := setConsumerOK(c.ctx, cns, ifc); err != nil {
+			if _, err := setConsumerOK(ctx, resolved, ifc); err != nil {
 				return err
 			}
 			return nil
 		}
 		c.normalEvent(cns, "Deleting", fmt.Sprintf("Deleting consumer %q on stream %q", spec.DurableName, spec.StreamName))
+		var last bool
+		err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.ConsumerSpec) (err error) {
+			last, err = lastInterestConsumer(ctx, jc, spec)
+			return err
+		})
+		if err != nil {
+			klog.Infof("failed to check remaining consumers of stream %q: %s", spec.StreamName, err)
+		} else if last {
+			c.warningEvent(cns, "LastInterestConsumer", fmt.Sprintf("Consumer %q is the last one on interest retention stream %q, messages may be purged once it's deleted",
+				spec.DurableName, spec.StreamName))
+		}
 		if err := natsClientUtil(deleteConsumer); err != nil {
 			return err
 		}
+		// Resources held by other finalizers stay around until those are
+		// removed, so record the outcome for anyone watching them.
+		if len(cns.Finalizers) > 0 {
+			if _, err := setConsumerDeleted(ctx, resolved, ifc); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+		c.lag.forget(objectKey(cns.Namespace, cns.Name))
+		recordConsumerResult(ctx, ActionDeleted, spec)
 	default:
-		c.normalEvent(cns, "Noop", fmt.Sprintf("Nothing done for consumer %q (prevent-delete=%v, prevent-update=%v)",
-			spec.DurableName, spec.PreventDelete, spec.PreventUpdate,
-		))
-		if _, err := setConsumerOK(c.ctx, cns, ifc); err != nil {
+		// Strict readiness rechecks pending consumers every
+		// consumerReadyRecheckInterval, those aren't worth an event each.
+		if !(c.opts.StrictConsumerReadiness && readyPending(cns)) {
+			c.noopEvent(cns, "Noop", fmt.Sprintf("Nothing done for consumer %q (prevent-delete=%v, prevent-update=%v)",
+				spec.DurableName, spec.PreventDelete, spec.PreventUpdate,
+			))
+		}
+		if err := setOK(); err != nil {
 			return err
 		}
+		if c.opts.ManagedByLabel {
+			if err := setConsumerManagedBy(ctx, cns, ifc); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// warnSharedDeliverSubject warns when other managed consumers deliver to the
+// same subject as cns, which has no deliver group, so every message would be
+// delivered to the subscribers of all of them.
+func (c *Controller) warnSharedDeliverSubject(cns *apis.Consumer, subject string) {
+	consumers, err := c.cnsLister.List(labels.Everything())
+	if err != nil {
+		klog.Infof("failed to list consumers sharing deliver subject %q: %s", subject, err)
+		return
+	}
+	var shared []string
+	for _, other := range consumers {
+		if other.Namespace == cns.Namespace && other.Name == cns.Name {
+			continue
+		}
+		if other.Spec.DeliverSubject == subject && other.DeletionTimestamp == nil {
+			shared = append(shared, objectKey(other.Namespace, other.Name))
+		}
+	}
+	if len(shared) == 0 {
+		return
 	}
+	sort.Strings(shared)
+	c.warningEvent(cns, "SharedDeliverSubject", fmt.Sprintf("Deliver subject %q of consumer %q is shared with %s without a deliver group, messages will be duplicated to all of them",
+		subject, cns.Spec.DurableName, strings.Join(shared, ", ")))
+}
 
+// resolveDurableName expands the {{.Namespace}} and {{.StreamName}}
+// placeholders in the consumer's durable name, so that one manifest can
+// produce unique durable names per namespace.
+func resolveDurableName(cns *apis.Consumer) (string, error) {
+	name := cns.Spec.DurableName
+	if strings.Contains(name, "{{") {
+		tmpl, err := template.New("durableName").Parse(name)
+		if err != nil {
+			return "", fmt.Errorf("invalid durable name template %q: %w", name, err)
+		}
+
+		var b strings.Builder
+		data := struct {
+			Namespace  string
+			StreamName string
+		}{
+			Namespace:  cns.Namespace,
+			StreamName: cns.Spec.StreamName,
+		}
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("invalid durable name template %q: %w", name, err)
+		}
+		name = b.String()
+	}
+
+	if err := validateDurableName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func validateDurableName(name string) error {
+	return validateName("durable name", name)
+}
+
+// maxNameLen is the longest stream, consumer or durable name NATS accepts.
+const maxNameLen = 255
+
+// validateName returns an error when NATS would reject name as a stream,
+// consumer or durable name, described by kind. Names are used as subject
+// tokens and, with file storage, as directory names.
+func validateName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s cannot be empty", kind)
+	}
+	if len(name) > maxNameLen {
+		return fmt.Errorf("invalid %s %q: longer than %d characters", kind, name, maxNameLen)
+	}
+	if strings.ContainsAny(name, ".*>/\\ \t\r\n\f") {
+		return fmt.Errorf("invalid %s %q: must not contain '.', '*', '>', '/', '\\' or whitespace", kind, name)
+	}
 	return nil
 }
 
@@ -244,6 +748,255 @@ func consumerExists(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (e
 	return err
 }
 
+// Consumer update strategies, deciding what's done when a field NATS can't
+// update in place changes.
+const (
+	// ConsumerUpdateWarn records an ImmutableChange warning and tries to
+	// update the consumer anyway.
+	ConsumerUpdateWarn = "warn"
+
+	// ConsumerUpdateRecreate deletes the consumer and creates it again,
+	// unless preventDelete is set.
+	ConsumerUpdateRecreate = "recreate"
+
+	// ConsumerUpdateInPlace tries to update the consumer without a warning,
+	// leaving NATS to refuse the change.
+	ConsumerUpdateInPlace = "inplace"
+)
+
+// ValidConsumerUpdateStrategy returns whether s is a consumer update
+// strategy, empty standing for the default.
+func ValidConsumerUpdateStrategy(s string) bool {
+	switch s {
+	case "", ConsumerUpdateWarn, ConsumerUpdateRecreate, ConsumerUpdateInPlace:
+		return true
+	}
+	return false
+}
+
+// consumerUpdateStrategy returns how changes of the fields of spec NATS can't
+// update in place are handled: its own updateStrategy, recreate when it sets
+// allowRecreate, otherwise the ConsumerUpdateStrategy of the controller, warn
+// by default.
+func (c *Controller) consumerUpdateStrategy(spec apis.ConsumerSpec) string {
+	switch {
+	case spec.UpdateStrategy != "":
+		return spec.UpdateStrategy
+	case spec.AllowRecreate:
+		return ConsumerUpdateRecreate
+	case c.opts.ConsumerUpdateStrategy != "":
+		return c.opts.ConsumerUpdateStrategy
+	}
+	return ConsumerUpdateWarn
+}
+
+// consumerImmutableChanges returns the names of the fields the spec changes
+// on the consumer that NATS doesn't allow to update.
+func consumerImmutableChanges(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) ([]string, error) {
+	cn, err := c.LoadConsumer(ctx, spec.StreamName, spec.DurableName)
+	if err != nil {
+		return nil, err
+	}
+	state, err := cn.LatestState()
+	if err != nil {
+		return nil, err
+	}
+	current := state.Config
+	opts, err := consumerSpecToOpts(resumedStart(spec, current))
+	if err != nil {
+		return nil, err
+	}
+
+	// Updates apply the spec on top of the current config.
+	desired, err := jsm.NewConsumerConfiguration(current, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	if current.DeliverPolicy != desired.DeliverPolicy {
+		changed = append(changed, "deliverPolicy")
+	}
+	if current.OptStartSeq != desired.OptStartSeq {
+		changed = append(changed, "optStartSeq")
+	}
+	if !reflect.DeepEqual(current.OptStartTime, desired.OptStartTime) {
+		changed = append(changed, "optStartTime")
+	}
+	if current.AckPolicy != desired.AckPolicy {
+		changed = append(changed, "ackPolicy")
+	}
+	if current.ReplayPolicy != desired.ReplayPolicy {
+		changed = append(changed, "replayPolicy")
+	}
+	if current.Heartbeat != desired.Heartbeat {
+		changed = append(changed, "heartbeatInterval")
+	}
+	if current.FlowControl != desired.FlowControl {
+		changed = append(changed, "flowControl")
+	}
+	if current.MaxWaiting != desired.MaxWaiting {
+		changed = append(changed, "maxWaiting")
+	}
+	if (current.DeliverSubject == "") != (desired.DeliverSubject == "") {
+		changed = append(changed, "deliverSubject")
+	}
+	return changed, nil
+}
+
+// resumedStart returns spec starting where the current consumer does when it
+// was recreated from its predecessor's ack floor, as it keeps starting there
+// whatever its deliver policy, and NATS doesn't allow to update it.
+func resumedStart(spec apis.ConsumerSpec, current jsmapi.ConsumerConfig) apis.ConsumerSpec {
+	if !spec.RecreateFromAckFloor || current.DeliverPolicy != jsmapi.DeliverByStartSequence {
+		return spec
+	}
+	spec.DeliverPolicy = "byStartSequence"
+	spec.OptStartSeq = int(current.OptStartSeq)
+	spec.OptStartTime = ""
+	return spec
+}
+
+// consumerDrift returns the names of the config fields of the consumer that
+// differ from its spec.
+func consumerDrift(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) ([]string, error) {
+	cn, err := c.LoadConsumer(ctx, spec.StreamName, spec.DurableName)
+	if err != nil {
+		return nil, err
+	}
+	state, err := cn.LatestState()
+	if err != nil {
+		return nil, err
+	}
+	current := state.Config
+	opts, err := consumerSpecToOpts(resumedStart(spec, current))
+	if err != nil {
+		return nil, err
+	}
+
+	// Like updates, the spec is applied on top of the current config so
+	// fields it leaves out don't count as drift.
+	desired, err := jsm.NewConsumerConfiguration(current, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cv, dv := reflect.ValueOf(current), reflect.ValueOf(*desired)
+	var drifted []string
+	for i := 0; i < cv.NumField(); i++ {
+		if !fieldsEqual(cv.Field(i), dv.Field(i)) {
+			drifted = append(drifted, cv.Type().Field(i).Name)
+		}
+	}
+	return drifted, nil
+}
+
+// consumerAckFloor returns the stream sequence of the consumer's ack floor,
+// below which every message has been acknowledged.
+func consumerAckFloor(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (uint64, error) {
+	cn, err := c.LoadConsumer(ctx, spec.StreamName, spec.DurableName)
+	if err != nil {
+		return 0, err
+	}
+	state, err := cn.LatestState()
+	if err != nil {
+		return 0, err
+	}
+	return state.AckFloor.Stream, nil
+}
+
+// consumerState returns the latest state of the consumer of spec.
+func consumerState(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (jsmapi.ConsumerInfo, error) {
+	cn, err := c.LoadConsumer(ctx, spec.StreamName, spec.DurableName)
+	if err != nil {
+		return jsmapi.ConsumerInfo{}, err
+	}
+	return cn.LatestState()
+}
+
+// consumerActive reports whether the consumer is bound to a push subscriber,
+// has pull requests waiting, or has delivered any messages.
+func consumerActive(state jsmapi.ConsumerInfo) bool {
+	return state.PushBound || state.NumWaiting > 0 || state.Delivered.Consumer > 0
+}
+
+// streamCreated returns the creation time of the stream, which tells apart
+// streams recreated with the same name.
+func streamCreated(ctx context.Context, c jsmClient, name string) (string, error) {
+	js, err := c.LoadStream(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	info, err := js.LatestInformation()
+	if err != nil {
+		return "", err
+	}
+	if info.Created.IsZero() {
+		return "", nil
+	}
+	return info.Created.UTC().Format(time.RFC3339Nano), nil
+}
+
+// workQueueConflict returns the name of a consumer whose filter overlaps that
+// of spec when its stream has workqueue retention, which allows only one
+// consumer per filter.
+func workQueueConflict(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (string, error) {
+	str, err := c.LoadStream(ctx, spec.StreamName)
+	if err != nil {
+		return "", err
+	}
+	if str.Configuration().Retention != jsmapi.WorkQueuePolicy {
+		return "", nil
+	}
+
+	names, err := str.ConsumerNames()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if name == spec.DurableName {
+			continue
+		}
+		cn, err := c.LoadConsumer(ctx, spec.StreamName, name)
+		if err != nil {
+			return "", err
+		}
+		state, err := cn.LatestState()
+		if err != nil {
+			return "", err
+		}
+		filter := state.Config.FilterSubject
+		if filter == "" || spec.FilterSubject == "" || subjectsOverlap(filter, spec.FilterSubject) {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// lastInterestConsumer reports whether the consumer is the last one on an
+// interest retention stream, which no longer retains messages without
+// consumers.
+func lastInterestConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (bool, error) {
+	str, err := c.LoadStream(ctx, spec.StreamName)
+	if err != nil {
+		return false, err
+	}
+	if str.Configuration().Retention != jsmapi.InterestPolicy {
+		return false, nil
+	}
+
+	names, err := str.ConsumerNames()
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		if name != spec.DurableName {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func createConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (err error) {
 	defer func() {
 		if err != nil {
@@ -251,6 +1004,10 @@ func createConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (e
 		}
 	}()
 
+	if err = checkConsumerFeatures(c.ServerVersion(), spec); err != nil {
+		return
+	}
+
 	opts, err := consumerSpecToOpts(spec)
 	if err != nil {
 		return
@@ -266,11 +1023,23 @@ func updateConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (e
 		}
 	}()
 
+	if err = checkConsumerFeatures(c.ServerVersion(), spec); err != nil {
+		return
+	}
+
 	js, err := c.LoadConsumer(ctx, spec.StreamName, spec.DurableName)
 	if err != nil {
 		return
 	}
 
+	if spec.RecreateFromAckFloor {
+		var state jsmapi.ConsumerInfo
+		if state, err = js.LatestState(); err != nil {
+			return
+		}
+		spec = resumedStart(spec, state.Config)
+	}
+
 	opts, err := consumerSpecToOpts(spec)
 	if err != nil {
 		return
@@ -280,21 +1049,107 @@ func updateConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (e
 	return
 }
 
+// processingSLAAckWaitFactor is how many times the processing SLA duration
+// a derived ackWait is, leaving headroom for slow deliveries.
+const processingSLAAckWaitFactor = 2
+
+// crdDefaultAckWait is the ackWait the CRD defaults to, treated like an unset
+// one.
+const crdDefaultAckWait = "1ns"
+
+// applyProcessingSLA fills in the ackWait and maxAckPending left out of spec,
+// or at their CRD defaults, from its processing SLA:
+//
+//	ackWait       = 2 * duration
+//	maxAckPending = ceil(throughput * ackWait in seconds)
+//
+// so a message is only redelivered once it took twice its SLA, and no more
+// messages are pending than the subscribers can process within one ackWait.
+// Without a throughput, maxAckPending is left to the server.
+func applyProcessingSLA(spec apis.ConsumerSpec) (apis.ConsumerSpec, error) {
+	sla := spec.ProcessingSLA
+	if sla == nil {
+		return spec, nil
+	}
+	d, err := time.ParseDuration(sla.Duration)
+	if err != nil {
+		return spec, fmt.Errorf("invalid processing SLA duration: %w", err)
+	}
+	if d <= 0 {
+		return spec, fmt.Errorf("processing SLA duration must be positive, got %s", sla.Duration)
+	}
+
+	ackWait := processingSLAAckWaitFactor * d
+	if spec.AckWait != "" && spec.AckWait != crdDefaultAckWait {
+		if ackWait, err = time.ParseDuration(spec.AckWait); err != nil {
+			return spec, err
+		}
+	} else {
+		spec.AckWait = ackWait.String()
+	}
+	if spec.MaxAckPending == 0 && sla.Throughput > 0 {
+		spec.MaxAckPending = int(math.Ceil(float64(sla.Throughput) * ackWait.Seconds()))
+	}
+	return spec, nil
+}
+
 func consumerSpecToOpts(spec apis.ConsumerSpec) ([]jsm.ConsumerOption, error) {
+	spec, err := applyProcessingSLA(spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.DeliverGroup != "" && spec.DeliverSubject == "" {
+		// Queue groups share the messages pushed to the deliver subject,
+		// pull consumers are shared by fetching from them instead.
+		return nil, fmt.Errorf("'deliverGroup' is only valid for push consumers, but 'deliverSubject' is not set")
+	}
+	if spec.DeliverSubject != "" {
+		// They bound the pull requests waiting on a consumer, and how much
+		// and how long each may ask for, which push consumers don't serve.
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"maxWaiting", spec.MaxWaiting != 0},
+			{"maxRequestBatch", spec.MaxRequestBatch != 0},
+			{"maxRequestExpires", spec.MaxRequestExpires != ""},
+			{"maxRequestMaxBytes", spec.MaxRequestMaxBytes != 0},
+		} {
+			if f.set {
+				return nil, fmt.Errorf("'%s' is only valid for pull consumers, but 'deliverSubject' is set", f.name)
+			}
+		}
+	}
+	if spec.MaxRequestMaxBytes < 0 {
+		return nil, fmt.Errorf("invalid value for 'maxRequestMaxBytes': %d. Must be positive", spec.MaxRequestMaxBytes)
+	}
+
 	opts := []jsm.ConsumerOption{
 		jsm.DurableName(spec.DurableName),
 		jsm.DeliverySubject(spec.DeliverSubject),
 		jsm.FilterStreamBySubject(spec.FilterSubject),
 		jsm.RateLimitBitsPerSecond(uint64(spec.RateLimitBps)),
-		jsm.MaxAckPending(uint(spec.MaxAckPending)),
 		jsm.ConsumerDescription(spec.Description),
 		jsm.DeliverGroup(spec.DeliverGroup),
-		jsm.MaxWaiting(uint(spec.MaxWaiting)),
-		jsm.MaxRequestBatch(uint(spec.MaxRequestBatch)),
-		jsm.MaxRequestMaxBytes(spec.MaxRequestMaxBytes),
 		jsm.ConsumerOverrideReplicas(spec.Replicas),
 	}
 
+	// Limits left out of the spec are left to the server, which fills them
+	// in from its own or the stream's defaults, so that updates keep them
+	// instead of resetting them.
+	if spec.MaxAckPending != 0 {
+		opts = append(opts, jsm.MaxAckPending(uint(spec.MaxAckPending)))
+	}
+	if spec.MaxWaiting != 0 {
+		opts = append(opts, jsm.MaxWaiting(uint(spec.MaxWaiting)))
+	}
+	if spec.MaxRequestBatch != 0 {
+		opts = append(opts, jsm.MaxRequestBatch(uint(spec.MaxRequestBatch)))
+	}
+	if spec.MaxRequestMaxBytes != 0 {
+		opts = append(opts, jsm.MaxRequestMaxBytes(spec.MaxRequestMaxBytes))
+	}
+
 	switch spec.DeliverPolicy {
 	case "all":
 		opts = append(opts, jsm.DeliverAllAvailable())
@@ -389,6 +1244,11 @@ func consumerSpecToOpts(spec apis.ConsumerSpec) ([]jsm.ConsumerOption, error) {
 		if err != nil {
 			return nil, err
 		}
+		// Servers refuse shorter ones, pull requests without an expiry
+		// aren't bounded by it.
+		if dur < time.Millisecond {
+			return nil, fmt.Errorf("invalid value for 'maxRequestExpires': %s. Must be at least 1ms", spec.MaxRequestExpires)
+		}
 		opts = append(opts, jsm.MaxRequestExpires(dur))
 	}
 
@@ -403,6 +1263,21 @@ func consumerSpecToOpts(spec apis.ConsumerSpec) ([]jsm.ConsumerOption, error) {
 	return opts, nil
 }
 
+func consumerSpecToConfig(spec apis.ConsumerSpec) (jsmapi.ConsumerConfig, error) {
+	var config jsmapi.ConsumerConfig
+
+	opts, err := consumerSpecToOpts(spec)
+	if err != nil {
+		return config, err
+	}
+	for _, opt := range opts {
+		if err := opt(&config); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
 func deleteConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (err error) {
 	stream, consumer := spec.StreamName, spec.DurableName
 	defer func() {
@@ -428,15 +1303,64 @@ func deleteConsumer(ctx context.Context, c jsmClient, spec apis.ConsumerSpec) (e
 }
 
 func setConsumerOK(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterface) (*apis.Consumer, error) {
+	return setConsumerCreated(ctx, s, i, true)
+}
+
+// setConsumerCreated sets the Created condition, and sets the Ready condition
+// according to ready.
+func setConsumerCreated(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterface, ready bool) (*apis.Consumer, error) {
 	sc := s.DeepCopy()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
 
 	sc.Status.ObservedGeneration = s.Generation
+	sc.Status.ObservedSpecHash = specHash(s.Spec)
 	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, apis.Condition{
+		Type:               createdCondType,
+		Status:             k8sapi.ConditionTrue,
+		LastTransitionTime: now,
+		Reason:             "Created",
+		Message:            "Consumer successfully created",
+	})
+	readyCond := apis.Condition{
 		Type:               readyCondType,
 		Status:             k8sapi.ConditionTrue,
-		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		LastTransitionTime: now,
 		Reason:             "Created",
 		Message:            "Consumer successfully created",
+	}
+	if !ready {
+		readyCond.Status = k8sapi.ConditionFalse
+		readyCond.Reason = "Pending"
+		readyCond.Message = "Consumer created, waiting for it to be bound or to deliver messages"
+	}
+	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, readyCond)
+
+	var res *apis.Consumer
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		res, err = i.UpdateStatus(ctx, sc, k8smeta.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set consumer %q status: %w", s.Spec.DurableName, err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// setConsumerDeleted sets the terminal Deleted condition of a consumer
+// deleted from NATS.
+func setConsumerDeleted(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterface) (*apis.Consumer, error) {
+	sc := s.DeepCopy()
+
+	sc.Status.ObservedGeneration = s.Generation
+	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, apis.Condition{
+		Type:               deletedCondType,
+		Status:             k8sapi.ConditionTrue,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "Deleted",
+		Message:            "Consumer successfully deleted",
 	})
 
 	var res *apis.Consumer
@@ -453,18 +1377,64 @@ func setConsumerOK(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterf
 	return res, err
 }
 
-func setConsumerErrored(ctx context.Context, s *apis.Consumer, sif typed.ConsumerInterface, err error) (*apis.Consumer, error) {
+// setConsumerAudited sets the InSync condition from an audit of the consumer,
+// which is missing from NATS unless exists, and otherwise differs from its
+// spec in the drifted fields.
+func setConsumerAudited(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterface, exists bool, drifted []string) (*apis.Consumer, error) {
+	cond := apis.Condition{
+		Type:               inSyncCondType,
+		Status:             k8sapi.ConditionTrue,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "InSync",
+		Message:            "Consumer matches its spec",
+	}
+	switch {
+	case !exists:
+		cond.Status = k8sapi.ConditionFalse
+		cond.Reason = "Missing"
+		cond.Message = "Consumer doesn't exist"
+	case len(drifted) > 0:
+		cond.Status = k8sapi.ConditionFalse
+		cond.Reason = "Drifted"
+		cond.Message = fmt.Sprintf("Consumer differs from its spec in %s", strings.Join(drifted, ", "))
+	}
+
+	sc := s.DeepCopy()
+	sc.Status.ObservedGeneration = s.Generation
+	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, cond)
+
+	var res *apis.Consumer
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		res, err = i.UpdateStatus(ctx, sc, k8smeta.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set consumer %q status: %w", s.Spec.DurableName, err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+func setConsumerErrored(ctx context.Context, s *apis.Consumer, sif typed.ConsumerInterface, err error, maxMessage int) (*apis.Consumer, error) {
 	if err == nil {
 		return s, nil
 	}
 
+	reason := "Errored"
+	var terr *reconcileTimeoutError
+	if errors.As(err, &terr) {
+		reason = "ReconcileTimeout"
+	}
+
 	sc := s.DeepCopy()
 	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, apis.Condition{
 		Type:               readyCondType,
 		Status:             k8sapi.ConditionFalse,
 		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
-		Reason:             "Errored",
-		Message:            err.Error(),
+		Reason:             reason,
+		Message:            truncateMessage(err.Error(), maxMessage),
 	})
 
 	var res *apis.Consumer
@@ -480,3 +1450,85 @@ func setConsumerErrored(ctx context.Context, s *apis.Consumer, sif typed.Consume
 	})
 	return res, err
 }
+
+// setConsumerManagedBy adds the managed-by label to the consumer if it has
+// none, leaving its other labels, and a managed-by label set otherwise, as
+// is.
+func setConsumerManagedBy(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterface) error {
+	if _, ok := s.Labels[managedByLabel]; ok {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		cur, err := i.Get(ctx, s.Name, k8smeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get consumer %q: %w", s.Spec.DurableName, err)
+		}
+		if _, ok := cur.Labels[managedByLabel]; ok {
+			return nil
+		}
+		if cur.Labels == nil {
+			cur.Labels = make(map[string]string)
+		}
+		cur.Labels[managedByLabel] = managedByValue
+
+		if _, err := i.Update(ctx, cur, k8smeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to label consumer %q: %w", s.Spec.DurableName, err)
+		}
+		return nil
+	})
+}
+
+// setConsumerLastApplied records the config resolved from spec in the
+// last-applied-config annotation. Metadata changes don't bump the
+// generation, so this doesn't trigger another reconcile.
+func setConsumerLastApplied(ctx context.Context, s *apis.Consumer, i typed.ConsumerInterface, spec apis.ConsumerSpec) error {
+	config, err := consumerSpecToConfig(spec)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		cur, err := i.Get(ctx, s.Name, k8smeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get consumer %q: %w", spec.DurableName, err)
+		}
+		if cur.Annotations[lastAppliedConfigAnnotation] == string(b) {
+			return nil
+		}
+		if cur.Annotations == nil {
+			cur.Annotations = make(map[string]string)
+		}
+		cur.Annotations[lastAppliedConfigAnnotation] = string(b)
+
+		if _, err := i.Update(ctx, cur, k8smeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to set consumer %q last applied config: %w", spec.DurableName, err)
+		}
+		return nil
+	})
+}
+
+// recordConsumerResult records action on the result carried by ctx, along with
+// the config applied when the consumer was created or updated.
+func recordConsumerResult(ctx context.Context, action ReconcileAction, spec apis.ConsumerSpec) {
+	res := resultFrom(ctx)
+	if res == nil {
+		return
+	}
+	res.Action = action
+	if action == ActionCreated || action == ActionUpdated {
+		if config, err := consumerSpecToConfig(spec); err == nil {
+			res.Config = config
+		}
+	}
+}
@@ -3,16 +3,22 @@ package jetstream
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/nats-io/jsm.go"
 	jsmapi "github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/jwt/v2"
 	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
 	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nkeys"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	k8sapi "k8s.io/api/core/v1"
 	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
@@ -179,8 +185,10 @@ func TestProcessConsumer(t *testing.T) {
 			Spec: apis.ConsumerSpec{
 				DurableName: name,
 			},
-			Status: apis.Status{
-				ObservedGeneration: 1,
+			Status: apis.ConsumerStatus{
+				Status: apis.Status{
+					ObservedGeneration: 1,
+				},
 			},
 		})
 		if err != nil {
@@ -373,6 +381,138 @@ func TestConsumerSpecToOpts(t *testing.T) {
 				Durable: "my-consumer",
 			},
 		},
+		"processing SLA": {
+			given: apis.ConsumerSpec{
+				DurableName:   "my-consumer",
+				AckWait:       "1ns",
+				ProcessingSLA: &apis.ProcessingSLA{Duration: "15s", Throughput: 20},
+			},
+			expected: jsmapi.ConsumerConfig{
+				Durable:       "my-consumer",
+				AckWait:       30 * time.Second,
+				MaxAckPending: 600,
+			},
+		},
+		"processing SLA with explicit ackWait and maxAckPending": {
+			given: apis.ConsumerSpec{
+				DurableName:   "my-consumer",
+				AckWait:       "1m",
+				MaxAckPending: 100,
+				ProcessingSLA: &apis.ProcessingSLA{Duration: "15s", Throughput: 20},
+			},
+			expected: jsmapi.ConsumerConfig{
+				Durable:       "my-consumer",
+				AckWait:       time.Minute,
+				MaxAckPending: 100,
+			},
+		},
+		"processing SLA without throughput": {
+			given: apis.ConsumerSpec{
+				DurableName:   "my-consumer",
+				ProcessingSLA: &apis.ProcessingSLA{Duration: "500ms"},
+			},
+			expected: jsmapi.ConsumerConfig{
+				Durable: "my-consumer",
+				AckWait: time.Second,
+			},
+		},
+		"invalid processing SLA duration": {
+			given: apis.ConsumerSpec{
+				DurableName:   "my-consumer",
+				ProcessingSLA: &apis.ProcessingSLA{Duration: "soon"},
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "invalid processing SLA duration")
+			},
+		},
+		"pull consumer max request bytes": {
+			given: apis.ConsumerSpec{
+				DurableName:        "my-consumer",
+				MaxRequestMaxBytes: 1 << 20,
+			},
+			expected: jsmapi.ConsumerConfig{
+				Durable:            "my-consumer",
+				MaxRequestMaxBytes: 1 << 20,
+			},
+		},
+		"push consumer max request bytes": {
+			given: apis.ConsumerSpec{
+				DurableName:        "my-consumer",
+				DeliverSubject:     "deliver.orders",
+				MaxRequestMaxBytes: 1 << 20,
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "'maxRequestMaxBytes' is only valid for pull consumers")
+			},
+		},
+		"pull consumer request limits": {
+			given: apis.ConsumerSpec{
+				DurableName:       "my-consumer",
+				MaxWaiting:        64,
+				MaxRequestBatch:   100,
+				MaxRequestExpires: "30s",
+			},
+			expected: jsmapi.ConsumerConfig{
+				Durable:           "my-consumer",
+				MaxWaiting:        64,
+				MaxRequestBatch:   100,
+				MaxRequestExpires: 30 * time.Second,
+			},
+		},
+		"push consumer max request expires": {
+			given: apis.ConsumerSpec{
+				DurableName:       "my-consumer",
+				DeliverSubject:    "deliver.orders",
+				MaxRequestExpires: "30s",
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "'maxRequestExpires' is only valid for pull consumers")
+			},
+		},
+		"push consumer max waiting": {
+			given: apis.ConsumerSpec{
+				DurableName:    "my-consumer",
+				DeliverSubject: "deliver.orders",
+				MaxWaiting:     64,
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "'maxWaiting' is only valid for pull consumers")
+			},
+		},
+		"too short max request expires": {
+			given: apis.ConsumerSpec{
+				DurableName:       "my-consumer",
+				MaxRequestExpires: "100us",
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "invalid value for 'maxRequestExpires'")
+			},
+		},
+		"pull consumer deliver group": {
+			given: apis.ConsumerSpec{
+				DurableName:  "my-consumer",
+				DeliverGroup: "workers",
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "'deliverGroup' is only valid for push consumers")
+			},
+		},
+		"negative max request bytes": {
+			given: apis.ConsumerSpec{
+				DurableName:        "my-consumer",
+				MaxRequestMaxBytes: -1,
+			},
+			errCheck: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "invalid value for 'maxRequestMaxBytes'")
+			},
+		},
 		"invalid deliver policy value": {
 			given: apis.ConsumerSpec{
 				DurableName:   "my-consumer",
@@ -432,3 +572,1268 @@ func TestConsumerSpecToOpts(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessConsumerDeliverSubjectPermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	wantEvents := 2
+	rec := record.NewFakeRecorder(wantEvents)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-consumer"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName:    name,
+			StreamName:     "my-stream",
+			DeliverSubject: "restricted.deliver",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumerErr:  errors.New(`nats: permissions violation for publish to "restricted.deliver"`),
+	}
+	if err := ctrl.processConsumer(ns, name, jsmc); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if got := len(rec.Events); got != wantEvents {
+		t.Error("unexpected number of events")
+		t.Fatalf("got=%d; want=%d", got, wantEvents)
+	}
+
+	<-rec.Events
+	gotEvent := <-rec.Events
+	if !strings.Contains(gotEvent, "PermissionDenied for deliver subject") {
+		t.Error("unexpected event")
+		t.Fatalf("got=%s; want=%s", gotEvent, "PermissionDenied...")
+	}
+}
+
+func TestProcessConsumerDurableNameTemplate(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "team-a", "my-consumer"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: "{{.Namespace}}-{{.StreamName}}-worker",
+			StreamName:  "orders",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotStatusName string
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject()
+		gotStatusName = obj.(*apis.Consumer).Status.ConsumerName
+		return true, obj, nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumer:     &mockConsumer{},
+	}
+	if err := ctrl.processConsumer(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	var config jsmapi.ConsumerConfig
+	for _, opt := range jsmc.newConsumerOpts {
+		require.NoError(t, opt(&config))
+	}
+	assert.Equal(t, "team-a-orders-worker", config.Durable)
+	assert.Equal(t, "team-a-orders-worker", gotStatusName)
+}
+
+func TestProcessConsumerRecreatesOrphaned(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-consumer"
+	recreated := time.Date(2022, 11, 2, 10, 0, 0, 0, time.UTC)
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: "worker",
+			StreamName:  "orders",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+				StreamCreated:      "2022-11-01T10:00:00Z",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var gotStreamCreated string
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject()
+		gotStreamCreated = obj.(*apis.Consumer).Status.StreamCreated
+		return true, obj, nil
+	})
+
+	// The stream was recreated and took the durable with it.
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{
+			info: &jsmapi.StreamInfo{Created: recreated},
+		},
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumer:     &mockConsumer{},
+	}
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+	require.NotNil(t, jsmc.newConsumerOpts, "expected consumer to be recreated")
+	assert.Equal(t, recreated.Format(time.RFC3339Nano), gotStreamCreated)
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	all := strings.Join(events, "\n")
+	assert.Contains(t, all, `Orphaned Stream "orders" of consumer "worker" was recreated`)
+	assert.Contains(t, all, `Recreated Recreated consumer "worker" on stream "orders"`)
+}
+
+func TestProcessConsumerRecreatedStreamOnChangeOnly(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        jc,
+		Recorder:              record.NewFakeRecorder(10),
+		ReconcileOnChangeOnly: true,
+	})
+
+	ns, name := "default", "my-consumer"
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: "worker",
+			StreamName:  "orders",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+				StreamCreated:      "2022-11-01T10:00:00Z",
+				Conditions: []apis.Condition{{
+					Type:   readyCondType,
+					Status: k8sapi.ConditionTrue,
+				}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// The stream was recreated and took the durable with it.
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{
+			info: &jsmapi.StreamInfo{Created: time.Date(2022, 11, 2, 10, 0, 0, 0, time.UTC)},
+		},
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumer:     &mockConsumer{},
+	}
+
+	// A resync of the Ready consumer is skipped.
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+	require.Zero(t, jsmc.newConsumers)
+
+	// Queued by the recreated stream, it's created again, once.
+	ctrl.enqueueStreamConsumers(ns, "orders")
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+	require.Equal(t, 1, jsmc.newConsumers, "expected consumer to be recreated")
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+	require.Equal(t, 1, jsmc.newConsumers)
+}
+
+func TestProcessConsumerDeliverSubjectPreflight(t *testing.T) {
+	t.Parallel()
+
+	accKey, err := nkeys.CreateAccount()
+	require.NoError(t, err)
+	userKey, err := nkeys.CreateUser()
+	require.NoError(t, err)
+	userPub, err := userKey.PublicKey()
+	require.NoError(t, err)
+	userSeed, err := userKey.Seed()
+	require.NoError(t, err)
+
+	claims := jwt.NewUserClaims(userPub)
+	claims.Pub.Deny.Add("restricted.>")
+	token, err := claims.Encode(accKey)
+	require.NoError(t, err)
+	creds, err := jwt.FormatUserConfig(token, userSeed)
+	require.NoError(t, err)
+	credsFile := filepath.Join(t.TempDir(), "user.creds")
+	require.NoError(t, os.WriteFile(credsFile, creds, 0600))
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                     context.Background(),
+		KubeIface:               k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:          jc,
+		Recorder:                rec,
+		NATSCredentials:         credsFile,
+		DeliverSubjectPreflight: true,
+	})
+
+	ns, name := "default", "my-consumer"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err = informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName:    "worker",
+			StreamName:     "orders",
+			DeliverSubject: "restricted.deliver",
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumer:     &mockConsumer{},
+	}
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Contains(t, strings.Join(events, "\n"),
+		`PreflightFailed Deliver subject "restricted.deliver" of consumer "worker" is not allowed`)
+}
+
+func TestProcessConsumerWorkQueueConflict(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-consumer"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName:   "worker",
+			StreamName:    "jobs",
+			FilterSubject: "jobs.>",
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumerErr:  jsmapi.ApiError{Code: 400, ErrCode: 10100, Description: "filtered consumer not unique on workqueue stream"},
+	}
+	require.Error(t, ctrl.processConsumer(ns, name, jsmc))
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Contains(t, strings.Join(events, "\n"),
+		`WorkQueueConflict Workqueue stream "jobs" allows only one consumer per filter, consumer "worker" overlaps an existing one`)
+}
+
+func TestWorkQueueConflict(t *testing.T) {
+	t.Parallel()
+
+	existing := func(filter string) *mockConsumer {
+		return &mockConsumer{state: jsmapi.ConsumerInfo{Config: jsmapi.ConsumerConfig{FilterSubject: filter}}}
+	}
+	tests := []struct {
+		name      string
+		retention jsmapi.RetentionPolicy
+		existing  *mockConsumer
+		filter    string
+		want      string
+	}{
+		{"limits", jsmapi.LimitsPolicy, existing("jobs.a"), "jobs.a", ""},
+		{"disjoint", jsmapi.WorkQueuePolicy, existing("jobs.a"), "jobs.b", ""},
+		{"same filter", jsmapi.WorkQueuePolicy, existing("jobs.a"), "jobs.a", "other"},
+		{"wildcard", jsmapi.WorkQueuePolicy, existing("jobs.*"), "jobs.a", "other"},
+		{"unfiltered existing", jsmapi.WorkQueuePolicy, existing(""), "jobs.a", "other"},
+		{"unfiltered new", jsmapi.WorkQueuePolicy, existing("jobs.a"), "", "other"},
+	}
+	for _, tt := range tests {
+		jsmc := &mockJsmClient{
+			loadStream: &mockStream{
+				config:        jsmapi.StreamConfig{Retention: tt.retention},
+				consumerNames: []string{"worker", "other"},
+			},
+			loadConsumer: tt.existing,
+		}
+		got, err := workQueueConflict(context.Background(), jsmc, apis.ConsumerSpec{
+			DurableName:   "worker",
+			StreamName:    "jobs",
+			FilterSubject: tt.filter,
+		})
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestProcessConsumerLastInterestConsumer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		retention jsmapi.RetentionPolicy
+		consumers []string
+		wantWarn  bool
+	}{
+		{"last on interest stream", jsmapi.InterestPolicy, []string{"my-consumer"}, true},
+		{"others remain", jsmapi.InterestPolicy, []string{"my-consumer", "other"}, false},
+		{"limits stream", jsmapi.LimitsPolicy, []string{"my-consumer"}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := record.NewFakeRecorder(10)
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: clientsetfake.NewSimpleClientset(),
+				Recorder:       rec,
+			})
+
+			ts := k8smeta.Unix(1600216923, 0)
+			ns, name := "default", "my-consumer"
+			err := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore().Add(&apis.Consumer{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:         ns,
+					Name:              name,
+					DeletionTimestamp: &ts,
+				},
+				Spec: apis.ConsumerSpec{
+					DurableName: name,
+					StreamName:  "orders",
+				},
+			})
+			require.NoError(t, err)
+
+			jsmc := &mockJsmClient{
+				loadStream: &mockStream{
+					config:        jsmapi.StreamConfig{Retention: tt.retention},
+					consumerNames: tt.consumers,
+				},
+				loadConsumer: &mockConsumer{},
+			}
+			require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+			var warned bool
+			for len(rec.Events) > 0 {
+				if strings.Contains(<-rec.Events, "LastInterestConsumer") {
+					warned = true
+				}
+			}
+			assert.Equal(t, tt.wantWarn, warned)
+		})
+	}
+}
+
+func TestProcessConsumerKeepsServerDefaults(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	ns, name := "default", "my-consumer"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName:   "worker",
+			StreamName:    "orders",
+			DeliverPolicy: "all",
+			AckPolicy:     "explicit",
+			AckWait:       "30s",
+			ReplayPolicy:  "instant",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// The limits the spec leaves out were filled in by the server.
+	server := jsmapi.ConsumerConfig{
+		Durable:            "worker",
+		DeliverPolicy:      jsmapi.DeliverAll,
+		AckPolicy:          jsmapi.AckExplicit,
+		AckWait:            30 * time.Second,
+		ReplayPolicy:       jsmapi.ReplayInstant,
+		MaxAckPending:      1000,
+		MaxWaiting:         512,
+		MaxRequestBatch:    100,
+		MaxRequestMaxBytes: 1024,
+	}
+	mc := &mockConsumer{}
+	jsmc := &mockJsmClient{
+		loadConsumer: mc,
+	}
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+	got := server
+	for _, opt := range mc.updatedOpts {
+		require.NoError(t, opt(&got))
+	}
+	assert.Equal(t, server, got, "update should keep the server defaults")
+}
+
+func TestProcessConsumerAuditOnly(t *testing.T) {
+	t.Parallel()
+
+	server := jsmapi.ConsumerConfig{
+		Durable:       "worker",
+		DeliverPolicy: jsmapi.DeliverAll,
+		AckPolicy:     jsmapi.AckExplicit,
+		AckWait:       30 * time.Second,
+		ReplayPolicy:  jsmapi.ReplayInstant,
+		MaxAckPending: 1000,
+	}
+	drifted := server
+	drifted.AckWait = 10 * time.Second
+
+	tests := map[string]struct {
+		deleting   bool
+		jsmc       *mockJsmClient
+		wantStatus k8sapi.ConditionStatus
+		wantReason string
+	}{
+		"in sync": {
+			jsmc:       &mockJsmClient{loadConsumer: &mockConsumer{state: jsmapi.ConsumerInfo{Config: server}}},
+			wantStatus: k8sapi.ConditionTrue,
+			wantReason: "InSync",
+		},
+		"drifted": {
+			jsmc:       &mockJsmClient{loadConsumer: &mockConsumer{state: jsmapi.ConsumerInfo{Config: drifted}}},
+			wantStatus: k8sapi.ConditionFalse,
+			wantReason: "Drifted",
+		},
+		"missing": {
+			jsmc:       &mockJsmClient{loadConsumerErr: jsmapi.ApiError{Code: 404}},
+			wantStatus: k8sapi.ConditionFalse,
+			wantReason: "Missing",
+		},
+		"deleting": {
+			deleting:   true,
+			jsmc:       &mockJsmClient{loadConsumer: &mockConsumer{state: jsmapi.ConsumerInfo{Config: server}}},
+			wantStatus: k8sapi.ConditionTrue,
+			wantReason: "InSync",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       record.NewFakeRecorder(10),
+				AuditOnly:      true,
+			})
+
+			ns, name := "default", "my-consumer"
+			cns := &apis.Consumer{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 2,
+				},
+				Spec: apis.ConsumerSpec{
+					DurableName:   "worker",
+					StreamName:    "orders",
+					DeliverPolicy: "all",
+					AckPolicy:     "explicit",
+					AckWait:       "30s",
+					ReplayPolicy:  "instant",
+				},
+				Status: apis.ConsumerStatus{
+					Status: apis.Status{
+						ObservedGeneration: 1,
+					},
+				},
+			}
+			if tt.deleting {
+				ts := k8smeta.Now()
+				cns.DeletionTimestamp = &ts
+			}
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+			require.NoError(t, informer.Informer().GetStore().Add(cns))
+
+			var got *apis.Consumer
+			jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				got = a.(k8stesting.UpdateAction).GetObject().(*apis.Consumer)
+				return true, got, nil
+			})
+
+			require.NoError(t, ctrl.processConsumer(ns, name, tt.jsmc))
+
+			require.NotNil(t, got)
+			require.Len(t, got.Status.Conditions, 1)
+			cond := got.Status.Conditions[0]
+			assert.Equal(t, inSyncCondType, cond.Type)
+			assert.Equal(t, tt.wantStatus, cond.Status)
+			assert.Equal(t, tt.wantReason, cond.Reason)
+			if tt.wantReason == "Drifted" {
+				assert.Contains(t, cond.Message, "AckWait")
+			}
+
+			// Nothing is ever changed on NATS.
+			assert.Nil(t, tt.jsmc.newConsumerOpts, "unexpected create")
+			if mc, ok := tt.jsmc.loadConsumer.(*mockConsumer); ok {
+				assert.Nil(t, mc.updatedOpts, "unexpected update")
+				assert.False(t, mc.deleted, "unexpected delete")
+			}
+		})
+	}
+}
+
+func TestProcessConsumerImmutableChange(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		defaultStrategy string
+		updateStrategy  string
+		allowRecreate   bool
+		wantRecreate    bool
+		wantEvent       string
+	}{
+		"warns by default": {
+			wantEvent: `Warning ImmutableChange Consumer "worker" on stream "orders" can't be updated to change ackPolicy`,
+		},
+		"recreates when allowed": {
+			allowRecreate: true,
+			wantRecreate:  true,
+			wantEvent:     `Normal Recreated Recreated consumer "worker" on stream "orders"`,
+		},
+		"applies the controller default": {
+			defaultStrategy: ConsumerUpdateRecreate,
+			wantRecreate:    true,
+			wantEvent:       `Normal Recreated Recreated consumer "worker" on stream "orders"`,
+		},
+		"own strategy overrides the controller default": {
+			defaultStrategy: ConsumerUpdateRecreate,
+			updateStrategy:  ConsumerUpdateWarn,
+			wantEvent:       `Warning ImmutableChange Consumer "worker" on stream "orders" can't be updated to change ackPolicy`,
+		},
+		"updates in place": {
+			updateStrategy: ConsumerUpdateInPlace,
+			wantEvent:      `Normal Updated Updated consumer "worker" on stream "orders"`,
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			rec := record.NewFakeRecorder(10)
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       rec,
+
+				ConsumerUpdateStrategy: tt.defaultStrategy,
+			})
+
+			ns, name := "default", "my-consumer"
+
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+			err := informer.Informer().GetStore().Add(&apis.Consumer{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 2,
+				},
+				Spec: apis.ConsumerSpec{
+					DurableName:    "worker",
+					StreamName:     "orders",
+					AckPolicy:      "none",
+					AllowRecreate:  tt.allowRecreate,
+					UpdateStrategy: tt.updateStrategy,
+				},
+				Status: apis.ConsumerStatus{
+					Status: apis.Status{
+						ObservedGeneration: 1,
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				return true, a.(k8stesting.UpdateAction).GetObject(), nil
+			})
+
+			current := &mockConsumer{
+				state: jsmapi.ConsumerInfo{
+					Config: jsmapi.ConsumerConfig{
+						Durable:   "worker",
+						AckPolicy: jsmapi.AckExplicit,
+					},
+				},
+			}
+			jsmc := &mockJsmClient{
+				loadConsumer: current,
+				newConsumer:  &mockConsumer{},
+			}
+			require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+			assert.Equal(t, tt.wantRecreate, current.deleted)
+			assert.Equal(t, tt.wantRecreate, jsmc.newConsumerOpts != nil)
+			assert.Equal(t, !tt.wantRecreate, current.updatedOpts != nil)
+
+			var events []string
+			for len(rec.Events) > 0 {
+				events = append(events, <-rec.Events)
+			}
+			assert.Contains(t, strings.Join(events, "\n"), tt.wantEvent)
+			if tt.updateStrategy == ConsumerUpdateInPlace {
+				assert.NotContains(t, strings.Join(events, "\n"), "ImmutableChange")
+			}
+			assert.Contains(t, strings.Join(events, "\n"), `Warning AckPolicyDowngrade Changing consumer "worker" on stream "orders" to ackPolicy none`)
+		})
+	}
+}
+
+func TestProcessConsumerRename(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		allowRecreate bool
+		wantDeleted   bool
+		wantEvent     string
+	}{
+		"deletes the old durable when allowed": {
+			allowRecreate: true,
+			wantDeleted:   true,
+			wantEvent:     `Normal Renaming Renaming consumer "old-worker" on stream "orders" to "worker"`,
+		},
+		"leaves the old durable by default": {
+			wantEvent: `Warning Renamed Consumer "old-worker" on stream "orders" was renamed to "worker" and is left in place`,
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			rec := record.NewFakeRecorder(10)
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       rec,
+			})
+
+			ns, name := "default", "my-consumer"
+
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+			err := informer.Informer().GetStore().Add(&apis.Consumer{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 2,
+				},
+				Spec: apis.ConsumerSpec{
+					DurableName:   "worker",
+					StreamName:    "orders",
+					AckPolicy:     "explicit",
+					AllowRecreate: tt.allowRecreate,
+				},
+				Status: apis.ConsumerStatus{
+					Status: apis.Status{
+						ObservedGeneration: 1,
+					},
+					ConsumerName: "old-worker",
+				},
+			})
+			require.NoError(t, err)
+
+			var written *apis.Consumer
+			jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				written = a.(k8stesting.UpdateAction).GetObject().(*apis.Consumer)
+				return true, written, nil
+			})
+
+			old := &mockConsumer{}
+			jsmc := &mockJsmClient{
+				consumers:   map[string]jsmConsumer{"old-worker": old},
+				newConsumer: &mockConsumer{},
+			}
+			require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+			assert.Equal(t, tt.wantDeleted, old.deleted)
+			assert.NotNil(t, jsmc.newConsumerOpts, "the renamed consumer should be created")
+			require.NotNil(t, written)
+			assert.Equal(t, "worker", written.Status.ConsumerName)
+
+			var events []string
+			for len(rec.Events) > 0 {
+				events = append(events, <-rec.Events)
+			}
+			assert.Contains(t, strings.Join(events, "\n"), tt.wantEvent)
+		})
+	}
+}
+
+func TestProcessConsumerRecreateFromAckFloor(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-consumer"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName:          "worker",
+			StreamName:           "orders",
+			DeliverPolicy:        "new",
+			AllowRecreate:        true,
+			RecreateFromAckFloor: true,
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	current := &mockConsumer{
+		state: jsmapi.ConsumerInfo{
+			Config: jsmapi.ConsumerConfig{
+				Durable:       "worker",
+				AckPolicy:     jsmapi.AckExplicit,
+				DeliverPolicy: jsmapi.DeliverAll,
+			},
+			AckFloor: jsmapi.SequenceInfo{Consumer: 40, Stream: 41},
+		},
+	}
+	jsmc := &mockJsmClient{
+		loadConsumer: current,
+		newConsumer:  &mockConsumer{},
+	}
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+	require.True(t, current.deleted, "expected consumer to be recreated")
+
+	cfg, err := jsm.NewConsumerConfiguration(jsm.DefaultConsumer, jsmc.newConsumerOpts...)
+	require.NoError(t, err)
+	assert.Equal(t, jsmapi.DeliverByStartSequence, cfg.DeliverPolicy)
+	assert.Equal(t, uint64(42), cfg.OptStartSeq)
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Contains(t, strings.Join(events, "\n"),
+		`ResumingFromAckFloor Recreated consumer "worker" on stream "orders" will start at sequence 42, after the ack floor`)
+
+	// The resumed consumer isn't recreated again for its deliver policy.
+	current.state.Config.DeliverPolicy = jsmapi.DeliverByStartSequence
+	current.state.Config.OptStartSeq = 42
+	immutable, err := consumerImmutableChanges(context.Background(), jsmc, apis.ConsumerSpec{
+		DurableName:          "worker",
+		StreamName:           "orders",
+		DeliverPolicy:        "new",
+		RecreateFromAckFloor: true,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, immutable)
+
+	// Nor do its updates send the spec's deliver policy, which NATS doesn't
+	// allow to update.
+	err = updateConsumer(context.Background(), jsmc, apis.ConsumerSpec{
+		DurableName:          "worker",
+		StreamName:           "orders",
+		DeliverPolicy:        "new",
+		RecreateFromAckFloor: true,
+	})
+	require.NoError(t, err)
+	cfg, err = jsm.NewConsumerConfiguration(current.state.Config, current.updatedOpts...)
+	require.NoError(t, err)
+	assert.Equal(t, jsmapi.DeliverByStartSequence, cfg.DeliverPolicy)
+	assert.Equal(t, uint64(42), cfg.OptStartSeq)
+}
+
+func TestProcessConsumerSharedDeliverSubject(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	store := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore()
+	for _, name := range []string{"worker-a", "worker-b"} {
+		err := store.Add(&apis.Consumer{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:  "default",
+				Name:       name,
+				Generation: 1,
+			},
+			Spec: apis.ConsumerSpec{
+				DurableName:    name,
+				StreamName:     "orders",
+				DeliverSubject: "deliver.orders",
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumer:     &mockConsumer{},
+	}
+	require.NoError(t, ctrl.processConsumer("default", "worker-a", jsmc))
+	require.NotNil(t, jsmc.newConsumerOpts, "the warning is advisory, the consumer is still created")
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Contains(t, strings.Join(events, "\n"),
+		`Warning SharedDeliverSubject Deliver subject "deliver.orders" of consumer "worker-a" is shared with default/worker-b without a deliver group`)
+
+	// Consumers in a deliver group load balance instead.
+	obj, _, _ := store.GetByKey("default/worker-a")
+	grouped := obj.(*apis.Consumer).DeepCopy()
+	grouped.Spec.DeliverGroup = "workers"
+	require.NoError(t, store.Update(grouped))
+	require.NoError(t, ctrl.processConsumer("default", "worker-a", jsmc))
+	for len(rec.Events) > 0 {
+		assert.NotContains(t, <-rec.Events, "SharedDeliverSubject")
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name    string
+		wantErr string
+	}{
+		"valid":          {name: "orders-eu_1"},
+		"longest":        {name: strings.Repeat("a", maxNameLen)},
+		"empty":          {name: "", wantErr: "stream name cannot be empty"},
+		"too long":       {name: strings.Repeat("a", maxNameLen+1), wantErr: "longer than 255 characters"},
+		"dot":            {name: "orders.eu", wantErr: "must not contain"},
+		"space":          {name: "orders eu", wantErr: "must not contain"},
+		"wildcard":       {name: "orders*", wantErr: "must not contain"},
+		"path separator": {name: "orders/eu", wantErr: "must not contain"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateName("stream name", test.name)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProcessConsumerInvalidName(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	cns := &apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  "default",
+			Name:       "my-consumer",
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			StreamName:  "orders",
+			DurableName: strings.Repeat("worker", 50),
+		},
+	}
+	err := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore().Add(cns)
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// The name is rejected before connecting.
+	jsmc := &mockJsmClient{connectErr: errors.New("unexpected connect")}
+	err = ctrl.processConsumer(cns.Namespace, cns.Name, jsmc)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "longer than 255 characters")
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0], "InvalidName")
+}
+
+func TestResolveDurableName(t *testing.T) {
+	tests := map[string]struct {
+		durable string
+		want    string
+		wantErr string
+	}{
+		"plain name": {
+			durable: "worker",
+			want:    "worker",
+		},
+		"namespace and stream": {
+			durable: "{{.Namespace}}_{{.StreamName}}",
+			want:    "team-a_orders",
+		},
+		"unknown placeholder": {
+			durable: "{{.Cluster}}-worker",
+			wantErr: "invalid durable name template",
+		},
+		"resolves to illegal durable": {
+			durable: "{{.Namespace}}.worker",
+			wantErr: "must not contain",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolveDurableName(&apis.Consumer{
+				ObjectMeta: k8smeta.ObjectMeta{Namespace: "team-a"},
+				Spec: apis.ConsumerSpec{
+					DurableName: test.durable,
+					StreamName:  "orders",
+				},
+			})
+			if test.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestProcessConsumerReadiness(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		strict    bool
+		state     jsmapi.ConsumerInfo
+		wantReady k8sapi.ConditionStatus
+	}{
+		"created is ready": {
+			wantReady: k8sapi.ConditionTrue,
+		},
+		"strict and idle": {
+			strict:    true,
+			wantReady: k8sapi.ConditionFalse,
+		},
+		"strict and bound": {
+			strict:    true,
+			state:     jsmapi.ConsumerInfo{PushBound: true},
+			wantReady: k8sapi.ConditionTrue,
+		},
+		"strict and delivered": {
+			strict:    true,
+			state:     jsmapi.ConsumerInfo{Delivered: jsmapi.SequenceInfo{Consumer: 3}},
+			wantReady: k8sapi.ConditionTrue,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			ctrl := NewController(Options{
+				Ctx:                     context.Background(),
+				KubeIface:               k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface:          jc,
+				Recorder:                record.NewFakeRecorder(10),
+				StrictConsumerReadiness: tt.strict,
+			})
+
+			ns, name := "default", "my-consumer"
+
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+			err := informer.Informer().GetStore().Add(&apis.Consumer{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 2,
+				},
+				Spec: apis.ConsumerSpec{
+					DurableName: name,
+					StreamName:  "orders",
+				},
+				Status: apis.ConsumerStatus{
+					Status: apis.Status{
+						ObservedGeneration: 1,
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			var gotConditions []apis.Condition
+			jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				obj := a.(k8stesting.UpdateAction).GetObject()
+				gotConditions = obj.(*apis.Consumer).Status.Conditions
+				return true, obj, nil
+			})
+
+			jsmc := &mockJsmClient{
+				loadConsumer: &mockConsumer{state: tt.state},
+			}
+			require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+			got := make(map[string]k8sapi.ConditionStatus)
+			for _, c := range gotConditions {
+				got[c.Type] = c.Status
+			}
+			assert.Equal(t, k8sapi.ConditionTrue, got[createdCondType])
+			assert.Equal(t, tt.wantReady, got[readyCondType])
+		})
+	}
+}
+
+func TestProcessConsumerReadinessRecheck(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                     context.Background(),
+		KubeIface:               k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:          jc,
+		Recorder:                rec,
+		StrictConsumerReadiness: true,
+	})
+
+	ns, name := "default", "my-consumer"
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: name,
+			StreamName:  "orders",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+				Conditions: []apis.Condition{{
+					Type:   readyCondType,
+					Status: k8sapi.ConditionFalse,
+					Reason: "Pending",
+				}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// Rechecking a consumer that's still idle records no event.
+	jsmc := &mockJsmClient{
+		loadConsumer: &mockConsumer{},
+	}
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Empty(t, events)
+}
+
+func TestProcessConsumerRequeuesNotFound(t *testing.T) {
+	t.Parallel()
+
+	window := 200 * time.Millisecond
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        clientsetfake.NewSimpleClientset(),
+		NotFoundRequeueWindow: window,
+	})
+	defer ctrl.cnsQueue.ShutDown()
+
+	ns, name := "default", "my-consumer"
+	jsmc := &mockJsmClient{}
+
+	// Missing from the cache within the window, the key is requeued.
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+	require.Eventually(t, func() bool {
+		return ctrl.cnsQueue.Len() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	item, _ := ctrl.cnsQueue.Get()
+	assert.Equal(t, "default/my-consumer", item)
+	ctrl.cnsQueue.Done(item)
+
+	// Past the window, it's treated as deleted.
+	time.Sleep(window)
+	require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+	time.Sleep(2 * window)
+	assert.Equal(t, 0, ctrl.cnsQueue.Len())
+
+	// A Stream of the same name missing since long before has its own
+	// window, the consumer missing again is still requeued.
+	ctrl.notFound.missed(outcomeKey("stream", ns, name), time.Now().Add(-time.Hour))
+	require.True(t, ctrl.requeueNotFound(ctrl.cnsQueue, "consumer", ns, name))
+}
@@ -0,0 +1,167 @@
+package jetstream
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// consumerReady returns whether cns has a true Ready condition.
+func consumerReady(cns *apis.Consumer) bool {
+	for _, cond := range cns.Status.Conditions {
+		if cond.Type == readyCondType {
+			return cond.Status == k8sapi.ConditionTrue
+		}
+	}
+	return false
+}
+
+// readyPending returns whether cns was created but strict readiness is
+// still waiting for it to become active.
+func readyPending(cns *apis.Consumer) bool {
+	for _, cond := range cns.Status.Conditions {
+		if cond.Type == readyCondType {
+			return cond.Status == k8sapi.ConditionFalse && cond.Reason == "Pending"
+		}
+	}
+	return false
+}
+
+// streamConsumers returns the Consumers of str in its namespace, including
+// the ones stamped out by consumer templates.
+func (c *Controller) streamConsumers(str *apis.Stream) ([]*apis.Consumer, error) {
+	consumers, err := c.cnsLister.Consumers(str.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var res []*apis.Consumer
+	for _, cns := range consumers {
+		if cns.Spec.StreamName == str.Spec.Name {
+			res = append(res, cns)
+		}
+	}
+	return res, nil
+}
+
+// consumersReadyCondition returns the condition summarizing how many of the
+// Consumers of a stream are ready, and false when it has none. Its message
+// is truncated to maxMessage.
+func consumersReadyCondition(consumers []*apis.Consumer, maxMessage int) (apis.Condition, bool) {
+	if len(consumers) == 0 {
+		return apis.Condition{}, false
+	}
+
+	var notReady []string
+	for _, cns := range consumers {
+		if !consumerReady(cns) {
+			notReady = append(notReady, cns.Name)
+		}
+	}
+	sort.Strings(notReady)
+
+	cond := apis.Condition{
+		Type:    consumersReadyCondType,
+		Status:  k8sapi.ConditionTrue,
+		Reason:  "AllConsumersReady",
+		Message: fmt.Sprintf("%d/%d consumers ready", len(consumers)-len(notReady), len(consumers)),
+	}
+	if len(notReady) > 0 {
+		cond.Status = k8sapi.ConditionFalse
+		cond.Reason = "ConsumersNotReady"
+		cond.Message += fmt.Sprintf(", not ready: %s", strings.Join(notReady, ", "))
+	}
+	cond.Message = truncateMessage(cond.Message, maxMessage)
+	return cond, true
+}
+
+// consumersReadyChanged returns the ConsumersReady condition str should have,
+// and whether it differs from the one it has.
+func (c *Controller) consumersReadyChanged(str *apis.Stream) (apis.Condition, bool, error) {
+	consumers, err := c.streamConsumers(str)
+	if err != nil {
+		return apis.Condition{}, false, err
+	}
+	want, ok := consumersReadyCondition(consumers, c.opts.MaxConditionMessageLength)
+
+	for _, cond := range str.Status.Conditions {
+		if cond.Type != consumersReadyCondType {
+			continue
+		}
+		changed := !ok || cond.Status != want.Status || cond.Reason != want.Reason || cond.Message != want.Message
+		return want, changed, nil
+	}
+	return want, ok, nil
+}
+
+// updateConsumersReady sets the ConsumersReady condition of str from the
+// state of its Consumers, or removes it once it has none. It returns str as
+// updated, so the rest of its reconcile writes on top of it.
+func (c *Controller) updateConsumersReady(ctx context.Context, str *apis.Stream) (*apis.Stream, error) {
+	if str.DeletionTimestamp != nil {
+		return str, nil
+	}
+	want, changed, err := c.consumersReadyChanged(str)
+	if err != nil || !changed {
+		return str, err
+	}
+
+	sc := str.DeepCopy()
+	if want.Type == "" {
+		sc.Status.Conditions = removeCondition(sc.Status.Conditions, consumersReadyCondType)
+	} else {
+		want.LastTransitionTime = time.Now().UTC().Format(time.RFC3339Nano)
+		sc.Status.Conditions = upsertCondition(sc.Status.Conditions, want)
+	}
+
+	res := str
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		updated, err := c.ji.Streams(str.Namespace).UpdateStatus(ctx, sc, k8smeta.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set stream %q consumers ready status: %w", str.Spec.Name, err)
+		}
+		res = updated
+		return nil
+	})
+	return res, err
+}
+
+// enqueueConsumerStreams queues the streams of a changed Consumer whose
+// ConsumersReady condition no longer matches the state of their Consumers.
+func (c *Controller) enqueueConsumerStreams(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	cns, ok := obj.(*apis.Consumer)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object %T", obj))
+		return
+	}
+
+	streams, err := c.strLister.Streams(cns.Namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, str := range streams {
+		if str.Spec.Name != cns.Spec.StreamName {
+			continue
+		}
+		if _, changed, err := c.consumersReadyChanged(str); err != nil {
+			utilruntime.HandleError(err)
+		} else if changed {
+			c.strQueue.Add(objectKey(str.Namespace, str.Name))
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestProcessStreamConsumersReady(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	ns, name := "default", "orders"
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	streams := informers.Streams().Informer().GetStore()
+	consumers := informers.Consumers().Informer().GetStore()
+	err := streams.Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: ns, Name: name, Generation: 1},
+		Spec:       apis.StreamSpec{Name: "ORDERS", Storage: "memory"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withReady := func(status k8sapi.ConditionStatus) apis.ConsumerStatus {
+		return apis.ConsumerStatus{Status: apis.Status{Conditions: []apis.Condition{{Type: readyCondType, Status: status}}}}
+	}
+	billing := &apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: ns, Name: "billing"},
+		Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "billing"},
+		Status:     withReady(k8sapi.ConditionFalse),
+	}
+	for _, cns := range []*apis.Consumer{
+		billing,
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: ns, Name: "shipping"},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "shipping"},
+			Status:     withReady(k8sapi.ConditionTrue),
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: ns, Name: "audit"},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "audit"},
+			Status:     withReady(k8sapi.ConditionTrue),
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: ns, Name: "payments"},
+			Spec:       apis.ConsumerSpec{StreamName: "PAYMENTS", DurableName: "payments"},
+		},
+	} {
+		if err := consumers.Add(cns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var last *apis.Stream
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		last = a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		return true, last, nil
+	})
+	consumersReady := func() apis.Condition {
+		t.Helper()
+		if last == nil {
+			t.Fatal("stream status was not updated")
+		}
+		for _, cond := range last.Status.Conditions {
+			if cond.Type == consumersReadyCondType {
+				return cond
+			}
+		}
+		t.Fatalf("got conditions %+v; want a %s condition", last.Status.Conditions, consumersReadyCondType)
+		return apis.Condition{}
+	}
+
+	// A failing consumer degrades the condition of its stream.
+	if err := ctrl.processStream(ns, name, &mockJsmClient{loadStream: &mockStream{}}); err != nil {
+		t.Fatal(err)
+	}
+	got := consumersReady()
+	if got.Status != k8sapi.ConditionFalse || got.Reason != "ConsumersNotReady" {
+		t.Fatalf("got status=%s reason=%s; want ConsumersNotReady", got.Status, got.Reason)
+	}
+	if want := "2/3 consumers ready, not ready: billing"; got.Message != want {
+		t.Fatalf("got=%q; want=%q", got.Message, want)
+	}
+	if err := streams.Update(last); err != nil {
+		t.Fatal(err)
+	}
+
+	// A change not affecting the summary doesn't queue the stream.
+	ctrl.enqueueConsumerStreams(billing)
+	if n := ctrl.strQueue.Len(); n != 0 {
+		t.Fatalf("got=%d; want no stream queued", n)
+	}
+
+	// Once it's ready again, the stream is queued and recovers.
+	billing = billing.DeepCopy()
+	billing.Status = withReady(k8sapi.ConditionTrue)
+	if err := consumers.Update(billing); err != nil {
+		t.Fatal(err)
+	}
+	ctrl.enqueueConsumerStreams(billing)
+	if n := ctrl.strQueue.Len(); n != 1 {
+		t.Fatalf("got=%d; want the stream queued", n)
+	}
+	if err := ctrl.processStream(ns, name, &mockJsmClient{loadStream: &mockStream{}}); err != nil {
+		t.Fatal(err)
+	}
+	got = consumersReady()
+	if got.Status != k8sapi.ConditionTrue || got.Message != "3/3 consumers ready" {
+		t.Fatalf("got status=%s message=%q; want 3/3 consumers ready", got.Status, got.Message)
+	}
+}
+
+func TestConsumersReadyConditionWithoutConsumers(t *testing.T) {
+	t.Parallel()
+
+	if cond, ok := consumersReadyCondition(nil, DefaultMaxConditionMessageLength); ok {
+		t.Fatalf("got %+v; want no condition for a stream without consumers", cond)
+	}
+}
@@ -0,0 +1,401 @@
+package jetstream
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	typed "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/typed/jetstream/v1beta2"
+
+	k8sapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	klog "k8s.io/klog/v2"
+)
+
+func (c *Controller) runConsumerTemplateQueue() {
+	for {
+		processQueueNext(c.tmplQueue, c.jsmClient(), c.whenResumed(c.processConsumerTemplate))
+	}
+}
+
+// processConsumerTemplate stamps out a Consumer per Stream matching the
+// template's selector, and deletes the ones of Streams that no longer match.
+// The Consumers are reconciled against NATS like any other.
+func (c *Controller) processConsumerTemplate(ns, name string, _ jsmClient) (err error) {
+	tmpl, err := c.tmplLister.ConsumerTemplates(ns).Get(name)
+	if err != nil && k8serrors.IsNotFound(err) {
+		// Its Consumers are garbage collected through their owner reference.
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to process consumer template: %w", err)
+		}
+	}()
+
+	ifc := c.ji.ConsumerTemplates(ns)
+	if tmpl.GetDeletionTimestamp() != nil {
+		if err := c.deleteTemplateConsumers(tmpl, nil); err != nil {
+			return err
+		}
+		// The finalizer holds the template until its Consumers are gone,
+		// the removal of each of them queues it again.
+		owned, err := c.templateConsumers(tmpl)
+		if err != nil {
+			return err
+		}
+		if len(owned) > 0 {
+			klog.V(4).Infof("consumer template %s/%s waiting for %d consumers to be deleted", ns, name, len(owned))
+			return nil
+		}
+		return removeConsumerTemplateFinalizer(c.ctx, tmpl, ifc)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		if _, serr := setConsumerTemplateErrored(c.ctx, tmpl, ifc, err, c.opts.MaxConditionMessageLength); serr != nil {
+			err = fmt.Errorf("%s: %w", err, serr)
+		}
+	}()
+
+	if !c.opts.DisableFinalizers {
+		updated, err := addConsumerTemplateFinalizer(c.ctx, tmpl, ifc)
+		if err != nil {
+			return err
+		}
+		tmpl = updated
+	}
+
+	if tmpl.Spec.StreamSelector == nil {
+		return fmt.Errorf("'streamSelector' is required")
+	}
+	selector, err := k8smeta.LabelSelectorAsSelector(tmpl.Spec.StreamSelector)
+	if err != nil {
+		return fmt.Errorf("invalid 'streamSelector': %w", err)
+	}
+	all, err := c.strLister.Streams(ns).List(selector)
+	if err != nil {
+		return err
+	}
+	streams := make([]*apis.Stream, 0, len(all))
+	for _, str := range all {
+		if str.DeletionTimestamp == nil {
+			streams = append(streams, str)
+		}
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Name < streams[j].Name })
+	if max := tmpl.Spec.MaxConsumers; max > 0 && len(streams) > max {
+		c.warningEvent(tmpl, "MaxConsumers", fmt.Sprintf("%d streams match, only creating consumers on the first %d", len(streams), max))
+		streams = streams[:max]
+	}
+
+	desired := make(map[string]*apis.Consumer, len(streams))
+	wants := make([]*apis.Consumer, 0, len(streams))
+	for _, str := range streams {
+		name, err := c.templateConsumerName(tmpl, str)
+		if err != nil {
+			return err
+		}
+		cns := templateConsumer(tmpl, str, name)
+		desired[cns.Name] = cns
+		wants = append(wants, cns)
+	}
+
+	cnsIfc := c.ji.Consumers(ns)
+	for i, str := range streams {
+		want := wants[i]
+		have, err := c.cnsLister.Consumers(ns).Get(want.Name)
+		switch {
+		case k8serrors.IsNotFound(err):
+			if _, err := cnsIfc.Create(c.ctx, want, k8smeta.CreateOptions{}); err != nil {
+				return fmt.Errorf("failed to create consumer %q: %w", want.Name, err)
+			}
+			c.normalEvent(tmpl, "ConsumerCreated", fmt.Sprintf("Created consumer %q on stream %q", want.Name, str.Spec.Name))
+		case err != nil:
+			return err
+		case !k8smeta.IsControlledBy(have, tmpl):
+			return fmt.Errorf("consumer %q already exists and isn't managed by the template", want.Name)
+		case !equality.Semantic.DeepEqual(have.Spec, want.Spec):
+			next := have.DeepCopy()
+			next.Spec = want.Spec
+			if _, err := cnsIfc.Update(c.ctx, next, k8smeta.UpdateOptions{}); err != nil {
+				return fmt.Errorf("failed to update consumer %q: %w", want.Name, err)
+			}
+			c.normalEvent(tmpl, "ConsumerUpdated", fmt.Sprintf("Updated consumer %q on stream %q", want.Name, str.Spec.Name))
+		}
+	}
+
+	if err := c.deleteTemplateConsumers(tmpl, desired); err != nil {
+		return err
+	}
+
+	_, err = setConsumerTemplateOK(c.ctx, tmpl, ifc, len(desired))
+	return err
+}
+
+// deleteTemplateConsumers deletes the Consumers of the template that aren't
+// in keep nor already being deleted.
+func (c *Controller) deleteTemplateConsumers(tmpl *apis.ConsumerTemplate, keep map[string]*apis.Consumer) error {
+	owned, err := c.templateConsumers(tmpl)
+	if err != nil {
+		return err
+	}
+
+	cnsIfc := c.ji.Consumers(tmpl.Namespace)
+	for _, cns := range owned {
+		if _, ok := keep[cns.Name]; ok || cns.DeletionTimestamp != nil {
+			continue
+		}
+		err := cnsIfc.Delete(c.ctx, cns.Name, k8smeta.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete consumer %q: %w", cns.Name, err)
+		}
+		c.normalEvent(tmpl, "ConsumerDeleted", fmt.Sprintf("Deleted consumer %q on stream %q", cns.Name, cns.Spec.StreamName))
+	}
+	return nil
+}
+
+// templateConsumers returns the Consumers tmpl controls.
+func (c *Controller) templateConsumers(tmpl *apis.ConsumerTemplate) ([]*apis.Consumer, error) {
+	labelled, err := c.cnsLister.Consumers(tmpl.Namespace).List(labels.SelectorFromSet(labels.Set{consumerTemplateLabel: tmpl.Name}))
+	if err != nil {
+		return nil, err
+	}
+	var owned []*apis.Consumer
+	for _, cns := range labelled {
+		if k8smeta.IsControlledBy(cns, tmpl) {
+			owned = append(owned, cns)
+		}
+	}
+	return owned, nil
+}
+
+// templateConsumerName returns the name of the Consumer tmpl stamps out on
+// str. Template a-b on Stream c and template a on Stream b-c would both name
+// theirs a-b-c, so when another template of the namespace matches a Stream
+// giving the same name, both are hash-suffixed.
+func (c *Controller) templateConsumerName(tmpl *apis.ConsumerTemplate, str *apis.Stream) (string, error) {
+	joined := tmpl.Name + "-" + str.Name
+	tmpls, err := c.tmplLister.ConsumerTemplates(tmpl.Namespace).List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	for _, other := range tmpls {
+		prefix := other.Name + "-"
+		if other.Name == tmpl.Name || other.Spec.StreamSelector == nil || !strings.HasPrefix(joined, prefix) {
+			continue
+		}
+		s, err := c.strLister.Streams(tmpl.Namespace).Get(strings.TrimPrefix(joined, prefix))
+		if k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		selector, err := k8smeta.LabelSelectorAsSelector(other.Spec.StreamSelector)
+		if err != nil || s.DeletionTimestamp != nil || !selector.Matches(labels.Set(s.Labels)) {
+			continue
+		}
+		return hashedResourceName(joined, tmpl.Name+"/"+str.Name), nil
+	}
+	return joinedResourceName(tmpl.Name, str.Name), nil
+}
+
+// templateConsumer returns the Consumer the template stamps out on str,
+// named name.
+func templateConsumer(tmpl *apis.ConsumerTemplate, str *apis.Stream, name string) *apis.Consumer {
+	spec := *tmpl.Spec.ConsumerSpec.DeepCopy()
+	spec.StreamName = str.Spec.Name
+
+	return &apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: tmpl.Namespace,
+			Name:      name,
+			Labels:    map[string]string{consumerTemplateLabel: tmpl.Name},
+			OwnerReferences: []k8smeta.OwnerReference{
+				*k8smeta.NewControllerRef(tmpl, apis.SchemeGroupVersion.WithKind("ConsumerTemplate")),
+			},
+		},
+		Spec: spec,
+	}
+}
+
+// enqueueConsumerTemplates queues every consumer template in the namespace
+// of a changed stream, whose labels may now match their selectors
+// differently.
+func (c *Controller) enqueueConsumerTemplates(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	tmpls, err := c.tmplLister.ConsumerTemplates(o.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, tmpl := range tmpls {
+		if err := enqueueWork(c.tmplQueue, tmpl); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// enqueueOwningTemplate queues the consumer template of a changed Consumer
+// it stamped out, so that changes to it are reverted.
+func (c *Controller) enqueueOwningTemplate(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	if name, ok := o.GetLabels()[consumerTemplateLabel]; ok {
+		c.tmplQueue.Add(objectKey(o.GetNamespace(), name))
+	}
+}
+
+// addConsumerTemplateFinalizer adds the finalizer to the template if it has
+// none, and returns the template as updated.
+func addConsumerTemplateFinalizer(ctx context.Context, t *apis.ConsumerTemplate, i typed.ConsumerTemplateInterface) (*apis.ConsumerTemplate, error) {
+	if hasFinalizer(t.Finalizers, consumerTemplateFinalizer) {
+		return t, nil
+	}
+
+	res := t
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		cur, err := i.Get(ctx, t.Name, k8smeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get consumer template %q: %w", t.Name, err)
+		}
+		if hasFinalizer(cur.Finalizers, consumerTemplateFinalizer) {
+			res = cur
+			return nil
+		}
+		cur.Finalizers = append(cur.Finalizers, consumerTemplateFinalizer)
+		if res, err = i.Update(ctx, cur, k8smeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to add consumer template %q finalizer: %w", t.Name, err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+func removeConsumerTemplateFinalizer(ctx context.Context, t *apis.ConsumerTemplate, i typed.ConsumerTemplateInterface) error {
+	if !hasFinalizer(t.Finalizers, consumerTemplateFinalizer) {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		cur, err := i.Get(ctx, t.Name, k8smeta.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get consumer template %q: %w", t.Name, err)
+		}
+		finalizers := cur.Finalizers[:0]
+		for _, f := range cur.Finalizers {
+			if f != consumerTemplateFinalizer {
+				finalizers = append(finalizers, f)
+			}
+		}
+		cur.Finalizers = finalizers
+		if _, err := i.Update(ctx, cur, k8smeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to remove consumer template %q finalizer: %w", t.Name, err)
+		}
+		return nil
+	})
+}
+
+func hasFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func setConsumerTemplateOK(ctx context.Context, t *apis.ConsumerTemplate, i typed.ConsumerTemplateInterface, n int) (*apis.ConsumerTemplate, error) {
+	tc := t.DeepCopy()
+
+	tc.Status.ObservedGeneration = t.Generation
+	tc.Status.Conditions = upsertCondition(tc.Status.Conditions, apis.Condition{
+		Type:               readyCondType,
+		Status:             k8sapi.ConditionTrue,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "Created",
+		Message:            fmt.Sprintf("Consumer template manages %d consumers", n),
+	})
+
+	var res *apis.ConsumerTemplate
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		res, err = i.UpdateStatus(ctx, tc, k8smeta.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set consumer template %q status: %w", t.Name, err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+func setConsumerTemplateErrored(ctx context.Context, t *apis.ConsumerTemplate, i typed.ConsumerTemplateInterface, err error, maxMessage int) (*apis.ConsumerTemplate, error) {
+	if err == nil {
+		return t, nil
+	}
+
+	tc := t.DeepCopy()
+	tc.Status.Conditions = upsertCondition(tc.Status.Conditions, apis.Condition{
+		Type:               readyCondType,
+		Status:             k8sapi.ConditionFalse,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "Errored",
+		Message:            truncateMessage(err.Error(), maxMessage),
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var res *apis.ConsumerTemplate
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		res, err = i.UpdateStatus(ctx, tc, k8smeta.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set consumer template errored status: %w", err)
+		}
+		return nil
+	})
+	return res, err
+}
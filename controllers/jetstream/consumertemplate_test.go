@@ -0,0 +1,261 @@
+package jetstream
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestConsumerTemplate() *apis.ConsumerTemplate {
+	return &apis.ConsumerTemplate{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  "default",
+			Name:       "audit",
+			UID:        "audit-uid",
+			Generation: 1,
+		},
+		Spec: apis.ConsumerTemplateSpec{
+			ConsumerSpec: apis.ConsumerSpec{
+				DurableName: "audit",
+				AckPolicy:   "explicit",
+			},
+			StreamSelector: &k8smeta.LabelSelector{
+				MatchLabels: map[string]string{"team": "orders"},
+			},
+			MaxConsumers: 2,
+		},
+	}
+}
+
+func newTestTemplateStream(name string, lbls map[string]string) *apis.Stream {
+	return &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels:    lbls,
+		},
+		Spec: apis.StreamSpec{
+			Name: strings.ToUpper(name),
+		},
+	}
+}
+
+func TestProcessConsumerTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl := newTestConsumerTemplate()
+	orders := map[string]string{"team": "orders"}
+	streams := []*apis.Stream{
+		newTestTemplateStream("c-orders", orders),
+		newTestTemplateStream("a-orders", orders),
+		newTestTemplateStream("b-orders", orders),
+		newTestTemplateStream("billing", map[string]string{"team": "billing"}),
+	}
+	// A consumer stamped out on a stream that no longer matches.
+	stale := templateConsumer(tmpl, newTestTemplateStream("old", nil), "audit-old")
+
+	jc := clientsetfake.NewSimpleClientset(tmpl, stale)
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	if err := informers.ConsumerTemplates().Informer().GetStore().Add(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if err := informers.Consumers().Informer().GetStore().Add(stale); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range streams {
+		if err := informers.Streams().Informer().GetStore().Add(str); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ctrl.processConsumerTemplate(tmpl.Namespace, tmpl.Name, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cnss, err := jc.JetstreamV1beta2().Consumers(tmpl.Namespace).List(ctx, k8smeta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Capped at maxConsumers, in stream name order.
+	want := map[string]string{"audit-a-orders": "A-ORDERS", "audit-b-orders": "B-ORDERS"}
+	if got := len(cnss.Items); got != len(want) {
+		t.Fatalf("got=%d; want=%d consumers: %+v", got, len(want), cnss.Items)
+	}
+	for _, cns := range cnss.Items {
+		if got := cns.Spec.StreamName; got != want[cns.Name] {
+			t.Fatalf("got=%s; want=%s stream of consumer %q", got, want[cns.Name], cns.Name)
+		}
+		if cns.Spec.DurableName != "audit" || cns.Spec.AckPolicy != "explicit" {
+			t.Fatalf("got=%+v; want the template spec", cns.Spec)
+		}
+		if !k8smeta.IsControlledBy(&cns, tmpl) {
+			t.Fatalf("consumer %q isn't controlled by the template", cns.Name)
+		}
+	}
+
+	got, err := jc.JetstreamV1beta2().ConsumerTemplates(tmpl.Namespace).Get(ctx, tmpl.Name, k8smeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasFinalizer(got.Finalizers, consumerTemplateFinalizer) {
+		t.Fatalf("got=%v; want the consumer template finalizer", got.Finalizers)
+	}
+	if len(got.Status.Conditions) == 0 || got.Status.Conditions[0].Status != k8sapi.ConditionTrue {
+		t.Fatalf("got=%+v; want a Ready condition", got.Status.Conditions)
+	}
+
+	var maxEvent bool
+	for len(rec.Events) > 0 {
+		if strings.Contains(<-rec.Events, "MaxConsumers") {
+			maxEvent = true
+		}
+	}
+	if !maxEvent {
+		t.Fatal("missing MaxConsumers event")
+	}
+}
+
+func TestProcessConsumerTemplateDelete(t *testing.T) {
+	t.Parallel()
+
+	ts := k8smeta.Unix(1600216923, 0)
+	tmpl := newTestConsumerTemplate()
+	tmpl.DeletionTimestamp = &ts
+	tmpl.Finalizers = []string{consumerTemplateFinalizer}
+
+	owned := templateConsumer(tmpl, newTestTemplateStream("a-orders", nil), "audit-a-orders")
+	other := &apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "other",
+			Labels:    map[string]string{consumerTemplateLabel: tmpl.Name},
+		},
+	}
+
+	jc := clientsetfake.NewSimpleClientset(tmpl, owned, other)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	if err := informers.ConsumerTemplates().Informer().GetStore().Add(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	for _, cns := range []*apis.Consumer{owned, other} {
+		if err := informers.Consumers().Informer().GetStore().Add(cns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ctrl.processConsumerTemplate(tmpl.Namespace, tmpl.Name, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cnss, err := jc.JetstreamV1beta2().Consumers(tmpl.Namespace).List(ctx, k8smeta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the consumers the template controls are deleted.
+	if len(cnss.Items) != 1 || cnss.Items[0].Name != "other" {
+		t.Fatalf("got=%+v; want only the consumer not controlled by the template", cnss.Items)
+	}
+
+	// The finalizer holds the template until its consumers are gone.
+	got, err := jc.JetstreamV1beta2().ConsumerTemplates(tmpl.Namespace).Get(ctx, tmpl.Name, k8smeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasFinalizer(got.Finalizers, consumerTemplateFinalizer) {
+		t.Fatalf("got=%v; want the finalizer kept while consumers remain", got.Finalizers)
+	}
+
+	if err := informers.Consumers().Informer().GetStore().Delete(owned); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctrl.processConsumerTemplate(tmpl.Namespace, tmpl.Name, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err = jc.JetstreamV1beta2().ConsumerTemplates(tmpl.Namespace).Get(ctx, tmpl.Name, k8smeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasFinalizer(got.Finalizers, consumerTemplateFinalizer) {
+		t.Fatalf("got=%v; want the finalizer removed", got.Finalizers)
+	}
+}
+
+func TestProcessConsumerTemplateNameCollision(t *testing.T) {
+	t.Parallel()
+
+	// Template a-b on Stream c and template a on Stream b-c would both
+	// name their consumer a-b-c.
+	lbls := map[string]string{"team": "orders"}
+	ab, a := newTestConsumerTemplate(), newTestConsumerTemplate()
+	ab.Name, ab.UID, ab.Spec.MaxConsumers = "a-b", "a-b-uid", 0
+	a.Name, a.UID, a.Spec.MaxConsumers = "a", "a-uid", 0
+
+	jc := clientsetfake.NewSimpleClientset(ab, a)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	for _, tmpl := range []*apis.ConsumerTemplate{ab, a} {
+		if err := informers.ConsumerTemplates().Informer().GetStore().Add(tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"c", "b-c"} {
+		if err := informers.Streams().Informer().GetStore().Add(newTestTemplateStream(name, lbls)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tmpl := range []*apis.ConsumerTemplate{ab, a} {
+		if err := ctrl.processConsumerTemplate(tmpl.Namespace, tmpl.Name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cnss, err := jc.JetstreamV1beta2().Consumers("default").List(context.Background(), k8smeta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, cns := range cnss.Items {
+		got[cns.Name] = cns.Labels[consumerTemplateLabel] + "/" + cns.Spec.StreamName
+	}
+	if len(got) != 4 {
+		t.Fatalf("got=%v; want a consumer per template and stream", got)
+	}
+	if _, ok := got["a-b-c"]; ok {
+		t.Fatalf("got=%v; want the colliding names hash-suffixed", got)
+	}
+	if got["a-b-b-c"] != "a-b/B-C" || got["a-c"] != "a/C" {
+		t.Fatalf("got=%v; want the other names kept", got)
+	}
+}
@@ -15,14 +15,24 @@ package jetstream
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"hash/fnv"
+	"net"
 	"os"
+	goruntime "runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/nats-io/jsm.go"
 	jsmapi "github.com/nats-io/jsm.go/api"
 	"github.com/nats-io/nats.go"
+	"golang.org/x/time/rate"
 
 	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
 	clientset "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned"
@@ -37,11 +47,14 @@ import (
 	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	k8sscheme "k8s.io/client-go/kubernetes/scheme"
 	k8styped "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
@@ -57,8 +70,86 @@ const (
 
 	// readyCondType is the Ready condition type.
 	readyCondType = "Ready"
+
+	// createdCondType is the Created condition type, set once a consumer
+	// exists in NATS regardless of whether it's ready.
+	createdCondType = "Created"
+
+	// deletedCondType is the Deleted condition type, set once a resource's
+	// stream or consumer is deleted from NATS.
+	deletedCondType = "Deleted"
+
+	// inSyncCondType is the InSync condition type, set on consumers audited
+	// under AuditOnly.
+	inSyncCondType = "InSync"
+
+	// laggingCondType is the Lagging condition type, set on consumers with
+	// more pending messages than the LagThreshold.
+	laggingCondType = "Lagging"
+
+	// stuckCondType is the Stuck condition type, set on consumers whose
+	// redeliveries keep climbing while their ack floor doesn't move.
+	stuckCondType = "Stuck"
+
+	// consumersReadyCondType is the ConsumersReady condition type, set on
+	// streams to summarize how many of their consumers are ready.
+	consumersReadyCondType = "ConsumersReady"
+
+	// notFoundRequeueDelay is how long to wait before looking up a resource
+	// that wasn't found again, within the NotFoundRequeueWindow.
+	notFoundRequeueDelay = time.Second
+
+	// consumerReadyRecheckInterval is how often a created consumer that isn't
+	// ready yet is checked again under strict readiness.
+	consumerReadyRecheckInterval = 10 * time.Second
+
+	// pauseUntilAnnotation skips reconciliation of a resource until the
+	// RFC3339 timestamp it holds has passed.
+	pauseUntilAnnotation = "jetstream.nats.io/pause-until"
+
+	// lastAppliedConfigAnnotation holds the JSON config last sent to NATS.
+	lastAppliedConfigAnnotation = "jetstream.nats.io/last-applied-config"
+
+	// priorityAnnotation holds an integer priority, resources with a higher
+	// one are reconciled first. Defaults to 0.
+	priorityAnnotation = "jetstream.nats.io/priority"
+
+	// allowStreamNameConflictAnnotation lets a stream share its NATS stream
+	// name with other Stream resources, e.g. when they're in different
+	// accounts.
+	allowStreamNameConflictAnnotation = "jetstream.nats.io/allow-stream-name-conflict"
+
+	// recreateOnStorageChangeAnnotation, set to "true", lets the controller
+	// delete and recreate a stream whose storage type changed, as it can't
+	// change in place. The messages of the stream are lost.
+	recreateOnStorageChangeAnnotation = "jetstream.nats.io/recreate-on-storage-change"
+
+	// adoptAnnotation marks a resource that was imported from, and takes
+	// over, a stream or consumer that already existed in NATS.
+	adoptAnnotation = "jetstream.nats.io/adopt"
+
+	// managedByLabel, set to managedByValue, marks the resources the
+	// controller manages, unless they're already labelled otherwise.
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "nack"
+
+	// consumerTemplateLabel, set to the template name, marks the Consumers
+	// stamped out by a ConsumerTemplate.
+	consumerTemplateLabel = "jetstream.nats.io/consumer-template"
+
+	// consumerTemplateFinalizer holds a deleted ConsumerTemplate until its
+	// Consumers are deleted.
+	consumerTemplateFinalizer = "jetstream.nats.io/consumer-template"
+
+	// maxDefaultWorkers caps the number of workers derived from the
+	// available CPUs, as reconciles mostly wait on NATS and the API server.
+	maxDefaultWorkers = 16
 )
 
+// DefaultMaxConditionMessageLength is the MaxConditionMessageLength used
+// unless set.
+const DefaultMaxConditionMessageLength = 1024
+
 type Options struct {
 	Ctx context.Context
 
@@ -70,6 +161,20 @@ type Options struct {
 	NATSNKey        string
 	NATSServerURL   string
 
+	// NATSTokenFile is a file holding the token to authenticate with, like a
+	// projected service account token. It's read again on every reconnect,
+	// so that rotated tokens are picked up.
+	NATSTokenFile string
+
+	// JWTProvider mints the user JWTs to authenticate with, when there are
+	// no credentials or nkey.
+	JWTProvider JWTProvider
+
+	// ServersFromSRV is an SRV name, like _nats._tcp.example.com, resolved
+	// into the NATS servers to connect to instead of NATSServerURL. It's
+	// resolved again on every reconnect.
+	ServersFromSRV string
+
 	NATSCA          string
 	NATSCertificate string
 	NATSKey         string
@@ -79,14 +184,215 @@ type Options struct {
 	CleanupPeriod time.Duration
 	ReadOnly      bool
 
+	// DefaultCredentialsSecret holds the creds that streams and consumers
+	// connect with under CRDConnect when they have no credentials of their
+	// own, neither creds, nkey nor account ones. Unset when Name is empty.
+	DefaultCredentialsSecret SecretKeyRef
+
+	// CredentialsProviders read the creds of Accounts from secret backends
+	// other than Kubernetes, by the source set on the creds. Kubernetes
+	// secrets are read for source "kubernetes" or none, unless overridden.
+	CredentialsProviders map[string]CredentialsProvider
+
+	// ConsumerUpdateStrategy is how changes of consumer fields NATS can't
+	// update in place are handled, for consumers setting neither an
+	// updateStrategy nor allowRecreate: ConsumerUpdateWarn, the default,
+	// ConsumerUpdateRecreate or ConsumerUpdateInPlace.
+	ConsumerUpdateStrategy string
+
+	// Workers is the number of reconciles run at once for each of streams
+	// and consumers. Defaults to the number of usable CPUs, up to 16.
+	Workers int
+
+	// DisableFinalizers leaves the NATS lifecycle to be managed out-of-band.
+	// The controller never adds finalizers, so resources are always removed
+	// from Kubernetes right away; in this mode it also skips the NATS-side
+	// delete of streams and consumers, both on reconcile and in the cleanup
+	// loops. Deleting a resource then leaves its stream or consumer in NATS.
+	DisableFinalizers bool
+
+	// MaxConcurrentConnections limits how many reconciles may hold a NATS
+	// connection at the same time. Reconciles beyond the limit wait for a
+	// free slot before connecting. Zero means unlimited.
+	MaxConcurrentConnections int
+
+	// MaxStreamsPerNamespace caps the streams the controller creates for the
+	// Stream resources of any one namespace, oldest resources first. Streams
+	// that already exist are still updated and deleted. Zero means
+	// unlimited.
+	MaxStreamsPerNamespace int
+
+	// ReconcileOnChangeOnly skips reconciling streams and consumers whose
+	// current generation is already reconciled and Ready, such as when they
+	// are all enqueued again on startup. Changes made to them in NATS out of
+	// band are then never reverted. Streams with subjectsFrom are always
+	// reconciled, as their ConfigMap can change without their generation.
+	ReconcileOnChangeOnly bool
+
+	// StreamRequeueInterval and ConsumerRequeueInterval requeue a stream or
+	// consumer that long after each successful reconcile, so that its
+	// status, live state and readiness are checked against NATS at a steady
+	// cadence rather than only when it changes. Zero disables the requeue.
+	// They have no effect with ReconcileOnChangeOnly.
+	StreamRequeueInterval   time.Duration
+	ConsumerRequeueInterval time.Duration
+
+	// AckSampleMetrics exports the ack delays sampled by consumers with a
+	// sampleFreq as histograms, under /debug/vars. Consumers can also opt in
+	// one by one with the ack-sample-metrics annotation. It needs the
+	// controller's own NATS connection, so it does nothing with CRDConnect.
+	AckSampleMetrics bool
+
+	// ClusterReconcileRate bounds the reconciles per second against any one
+	// NATS cluster, identified by its server list, so that a mass resync
+	// can't flood a shared cluster. Reconciles wait for their turn. Zero
+	// means unlimited.
+	ClusterReconcileRate float64
+
+	// ClusterReconcileBurst is the number of reconciles against a cluster
+	// allowed at once before ClusterReconcileRate applies. Defaults to 1.
+	ClusterReconcileBurst int
+
+	// NotFoundRequeueWindow keeps requeueing a resource that isn't found in
+	// the informer cache for this long after it was first missed, instead of
+	// treating it as deleted right away, to absorb cache propagation delay.
+	// Zero disables the requeue.
+	NotFoundRequeueWindow time.Duration
+
+	// StuckTerminatingThreshold is how long a deleted stream or consumer may
+	// keep finalizers before it's reported as stuck terminating, with a
+	// warning event every CleanupPeriod and in the stuck terminating gauge.
+	// Zero disables the check.
+	StuckTerminatingThreshold time.Duration
+
+	// SuppressNoopEvents logs reconciles that intentionally change nothing,
+	// Noop and SkipUpdate, at debug level instead of recording events.
+	SuppressNoopEvents bool
+
+	// DeliverSubjectPreflight checks the deliver subject of a push consumer
+	// against the publish permissions in the user credentials before creating
+	// it, and warns when they don't allow it.
+	DeliverSubjectPreflight bool
+
+	// StrictConsumerReadiness only marks a consumer Ready once it's bound to
+	// a subscriber or has delivered messages, instead of as soon as it's
+	// created.
+	StrictConsumerReadiness bool
+
+	// RecordLastAppliedConfig stores the config sent to NATS on each create
+	// or update as the last-applied-config annotation, so operators can diff
+	// it against the spec.
+	RecordLastAppliedConfig bool
+
+	// StreamCacheTTL is how long a stream found to exist in NATS is
+	// remembered, so that reconciles of an unchanged resource within the
+	// TTL skip the LoadStream round trip. Zero disables the cache.
+	StreamCacheTTL time.Duration
+
+	// SecretCacheTTL is how long secrets read for credentials and TLS are
+	// reused before being read again, so changes to them take up to the TTL
+	// to be picked up. Zero disables the cache.
+	SecretCacheTTL time.Duration
+
+	// PauseConfigMap is a ConfigMap watched for a paused key. While it's
+	// "true" all workers idle, making no NATS calls, until it's changed or
+	// the ConfigMap deleted. Unset when Name is empty.
+	PauseConfigMap types.NamespacedName
+
+	// SubjectsFromConfigMaps watches the ConfigMaps of the controller's
+	// namespace, or of all namespaces, so that streams can take subjects from
+	// them with subjectsFrom. Every ConfigMap watched is cached in memory, and
+	// watching them needs RBAC permissions on ConfigMaps.
+	SubjectsFromConfigMaps bool
+
+	// SpecMutator, if set, is applied to every stream and consumer spec
+	// before it's created or updated in NATS.
+	SpecMutator SpecMutator
+
+	// PublishResults publishes the outcome of each reconcile as JSON on
+	// nack.events.<kind>.<namespace>.<name>, best-effort, over the
+	// controller's NATS connection. Not available with CRDConnect.
+	PublishResults bool
+
+	// StatusRefreshInterval is how often the live state of streams and
+	// consumers, like their messages and pending counts, is refreshed into
+	// their status. Reconciles in between leave it as is, while config
+	// changes are still applied right away. Zero disables the live state.
+	StatusRefreshInterval time.Duration
+
+	// AuditOnly makes consumers only be compared with NATS, never created,
+	// updated or deleted. Their InSync condition reports whether the NATS
+	// consumer matches, has drifted or is missing.
+	AuditOnly bool
+
+	// CapacityWarningPercent warns with a NearCapacity event, and flags the
+	// live state, once a stream holds this percentage of its maxBytes. It's
+	// checked whenever the live state is refreshed. Zero disables it.
+	CapacityWarningPercent int
+
+	// JSDomain is the JetStream domain API calls are sent to, for instance
+	// to manage the streams of a leaf node's domain.
+	JSDomain string
+
+	// AutoDiscoverDomain uses the domain of the connected server, as
+	// reported in its JetStream account info, when JSDomain is unset.
+	AutoDiscoverDomain bool
+
+	// LagThreshold is the number of pending messages above which a consumer
+	// has its Lagging condition set. Zero disables the condition.
+	LagThreshold uint64
+
+	// LagSetAfter is how many consecutive reconciles must find a consumer
+	// over the LagThreshold before it's marked lagging, and LagClearAfter
+	// how many must find it within before it's no longer. Values below one
+	// act as one.
+	LagSetAfter   int
+	LagClearAfter int
+
+	// StuckAfter is how many consecutive live state refreshes must find a
+	// consumer redelivering more messages without its ack floor moving
+	// before its Stuck condition is set. Zero disables the condition, which
+	// also needs StatusRefreshInterval.
+	StuckAfter int
+
+	// ManagedByLabel labels reconciled streams and consumers with
+	// app.kubernetes.io/managed-by=nack, unless they already have the label.
+	ManagedByLabel bool
+
+	// ConnectionErrorCooldown is how long, after a resource failed to connect
+	// to its NATS servers, identical failures are neither recorded as events
+	// nor logged again. They're still requeued. Zero reports every failure.
+	ConnectionErrorCooldown time.Duration
+
+	// MaxReconcileDuration bounds how long a single reconcile of a stream or
+	// consumer may take. Kubernetes calls still in flight are cancelled once
+	// it's exceeded and NATS API calls time out by then, at the earliest
+	// after half a second. The resource is marked with a ReconcileTimeout
+	// reason. Zero means no limit.
+	MaxReconcileDuration time.Duration
+
+	// MaxConditionMessageLength caps the length of status condition
+	// messages. Longer messages are truncated and end with an ellipsis, so
+	// that a large NATS error does not bloat the stored object. Zero uses
+	// DefaultMaxConditionMessageLength.
+	MaxConditionMessageLength int
+
+	// ReseedToken is the bearer token requests to ReseedStream must carry.
+	// Without one, reseeding is refused.
+	ReseedToken string
+
 	Recorder record.EventRecorder
 }
 
 type Controller struct {
 	ctx  context.Context
 	opts Options
-	nc   *nats.Conn
-	jm   *jsm.Manager
+
+	// connMu guards nc, jm and domain, set by connect while the admin
+	// endpoints may already read them.
+	connMu sync.RWMutex
+	nc     *nats.Conn
+	jm     *jsm.Manager
 
 	ki              k8styped.CoreV1Interface
 	ji              typed.JetstreamV1beta2Interface
@@ -101,8 +407,74 @@ type Controller struct {
 	cnsSynced cache.InformerSynced
 	cnsQueue  workqueue.RateLimitingInterface
 
+	tmplLister listers.ConsumerTemplateLister
+	tmplSynced cache.InformerSynced
+	tmplQueue  workqueue.RateLimitingInterface
+
+	// cmInformerFactory watches the ConfigMaps streams take subjects from.
+	cmInformerFactory kubeinformers.SharedInformerFactory
+	cmLister          corelisters.ConfigMapLister
+	cmSynced          cache.InformerSynced
+
 	accLister listers.AccountLister
 
+	// strCache remembers streams recently observed in NATS.
+	strCache *streamCache
+
+	// secretCache remembers secrets recently read from the API server.
+	secretCache *secretCache
+
+	// connSem bounds the number of concurrently held NATS connections,
+	// nil when unlimited.
+	connSem chan struct{}
+
+	// clusterLimiter rate limits reconciles per NATS cluster.
+	clusterLimiter *clusterLimiter
+
+	// notFound tracks when queued keys were first missing from the cache.
+	notFound *notFoundTracker
+
+	// forced holds the resources to reconcile once more even though
+	// ReconcileOnChangeOnly would skip them.
+	forced *forcedReconciles
+
+	// stuckTerminating is the number of resources found stuck terminating.
+	stuckTerminating *expvar.Int
+
+	// metrics counts reconciles per namespace.
+	metrics *reconcileMetrics
+
+	// pause idles the workers while the PauseConfigMap says so.
+	pause *pauseSwitch
+
+	// pauseInformerFactory watches the PauseConfigMap, nil when unset.
+	pauseInformerFactory kubeinformers.SharedInformerFactory
+
+	// domain is the JetStream domain c.jm sends its API calls to, if any.
+	domain string
+
+	// results publishes reconcile results, nil unless PublishResults.
+	results resultPublisher
+
+	// acks exports the ack samples of consumers.
+	acks *ackSampler
+
+	// dlq republishes the messages consumers gave up on to their dead
+	// letter subject.
+	dlq *deadLetters
+
+	// connCooldown suppresses repeated connection failures per resource.
+	connCooldown *connCooldown
+
+	// lag tracks the hysteresis of the Lagging condition of consumers.
+	lag *lagTracker
+
+	// outcomes records reconcile results for ReconcileAll, nil otherwise.
+	outcomes *outcomeTracker
+
+	// resolver looks up the ServersFromSRV records.
+	resolver srvResolver
+
 	// cacheDir is where the downloaded TLS certs from the server
 	// will be stored temporarily.
 	cacheDir string
@@ -114,8 +486,12 @@ func NewController(opt Options) *Controller {
 
 	streamInformer := informerFactory.Jetstream().V1beta2().Streams()
 	consumerInformer := informerFactory.Jetstream().V1beta2().Consumers()
+	templateInformer := informerFactory.Jetstream().V1beta2().ConsumerTemplates()
 	accountInformer := informerFactory.Jetstream().V1beta2().Accounts()
 
+	cmInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(opt.KubeIface, 0, kubeinformers.WithNamespace(opt.Namespace))
+	cmInformer := cmInformerFactory.Core().V1().ConfigMaps()
+
 	if opt.Recorder == nil {
 		utilruntime.Must(scheme.AddToScheme(k8sscheme.Scheme))
 		eventBroadcaster := record.NewBroadcaster()
@@ -132,10 +508,36 @@ func NewController(opt Options) *Controller {
 	if opt.NATSClientName == "" {
 		opt.NATSClientName = "jetstream-controller"
 	}
+	if opt.Workers <= 0 {
+		opt.Workers = defaultWorkers()
+	}
+	if opt.MaxConditionMessageLength <= 0 {
+		opt.MaxConditionMessageLength = DefaultMaxConditionMessageLength
+	}
 
 	ji := opt.JetstreamIface.JetstreamV1beta2()
-	streamQueue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Streams")
-	consumerQueue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Consumers")
+	streamQueue := newPriorityRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Streams", func(item interface{}) int {
+		ns, name, err := splitNamespaceName(item)
+		if err != nil {
+			return 0
+		}
+		str, err := streamInformer.Lister().Streams(ns).Get(name)
+		if err != nil {
+			return 0
+		}
+		return annotationPriority(str)
+	})
+	consumerQueue := newPriorityRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Consumers", func(item interface{}) int {
+		ns, name, err := splitNamespaceName(item)
+		if err != nil {
+			return 0
+		}
+		cns, err := consumerInformer.Lister().Consumers(ns).Get(name)
+		if err != nil {
+			return 0
+		}
+		return annotationPriority(cns)
+	})
 
 	streamInformer.Informer().AddEventHandler(eventHandlers(
 		opt.Ctx,
@@ -147,12 +549,23 @@ func NewController(opt Options) *Controller {
 		consumerQueue,
 	))
 
+	templateQueue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ConsumerTemplates")
+	templateInformer.Informer().AddEventHandler(eventHandlers(
+		opt.Ctx,
+		templateQueue,
+	))
+
 	cacheDir, err := os.MkdirTemp(".", "nack")
 	if err != nil {
 		panic(err)
 	}
 
-	return &Controller{
+	var connSem chan struct{}
+	if opt.MaxConcurrentConnections > 0 {
+		connSem = make(chan struct{}, opt.MaxConcurrentConnections)
+	}
+
+	c := &Controller{
 		ctx:  opt.Ctx,
 		opts: opt,
 
@@ -169,58 +582,165 @@ func NewController(opt Options) *Controller {
 		cnsSynced: consumerInformer.Informer().HasSynced,
 		cnsQueue:  consumerQueue,
 
-		accLister: accountInformer.Lister(),
-		cacheDir:  cacheDir,
+		tmplLister: templateInformer.Lister(),
+		tmplSynced: templateInformer.Informer().HasSynced,
+		tmplQueue:  templateQueue,
+
+		cmInformerFactory: cmInformerFactory,
+		cmLister:          cmInformer.Lister(),
+		cmSynced:          cmInformer.Informer().HasSynced,
+
+		accLister:   accountInformer.Lister(),
+		strCache:    newStreamCache(opt.StreamCacheTTL),
+		secretCache: newSecretCache(opt.SecretCacheTTL),
+		connSem:     connSem,
+		cacheDir:    cacheDir,
+
+		clusterLimiter:   newClusterLimiter(opt.ClusterReconcileRate, opt.ClusterReconcileBurst),
+		notFound:         &notFoundTracker{first: make(map[string]time.Time)},
+		forced:           &forcedReconciles{keys: make(map[string]bool)},
+		stuckTerminating: new(expvar.Int),
+		metrics:          newReconcileMetrics(),
+		acks:             newAckSampler(),
+		resolver:         net.DefaultResolver,
+		pause:            &pauseSwitch{},
+		lag:              &lagTracker{streaks: make(map[string]int)},
+		connCooldown:     newConnCooldown(opt.ConnectionErrorCooldown),
+	}
+	c.dlq = newDeadLetters(opt.Ctx, func() jsmClient { return c.jsmClient() })
+	streamInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueConsumerTemplates,
+		UpdateFunc: func(_, next interface{}) { c.enqueueConsumerTemplates(next) },
+		DeleteFunc: c.enqueueConsumerTemplates,
+	})
+	consumerInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, next interface{}) { c.enqueueOwningTemplate(next) },
+		DeleteFunc: c.enqueueOwningTemplate,
+	})
+	consumerInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueConsumerStreams,
+		UpdateFunc: func(_, next interface{}) { c.enqueueConsumerStreams(next) },
+		DeleteFunc: c.enqueueConsumerStreams,
+	})
+	cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueSubjectsFromStreams,
+		UpdateFunc: func(_, next interface{}) { c.enqueueSubjectsFromStreams(next) },
+		DeleteFunc: c.enqueueSubjectsFromStreams,
+	})
+	if opt.PauseConfigMap.Name != "" {
+		c.pauseInformerFactory = newPauseInformerFactory(opt)
+		c.watchPauseConfigMap(c.pauseInformerFactory)
 	}
+	return c
 }
 
-func (c *Controller) Run() error {
-	if !c.opts.CRDConnect {
-		// Connect to NATS.
-		opts := make([]nats.Option, 0)
+// authOptions returns the options authenticating the controller's NATS
+// connection: JWT/NKEYS based credentials, minted JWTs, or a token, if
+// present.
+func (c *Controller) authOptions() ([]nats.Option, error) {
+	switch {
+	case c.opts.NATSCredentials != "":
+		return []nats.Option{nats.UserCredentials(c.opts.NATSCredentials)}, nil
+	case c.opts.NATSNKey != "":
+		opt, err := nats.NkeyOptionFromSeed(c.opts.NATSNKey)
+		if err != nil {
+			return nil, err
+		}
+		return []nats.Option{opt}, nil
+	case c.opts.JWTProvider != nil:
+		return []nats.Option{nats.UserJWT(c.opts.JWTProvider.UserJWT, c.opts.JWTProvider.Sign)}, nil
+	case c.opts.NATSTokenFile != "":
+		opt, err := tokenFileOption(c.opts.NATSTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		return []nats.Option{opt}, nil
+	}
+	return nil, nil
+}
 
-		opts = append(opts, nats.Name(c.opts.NATSClientName))
+// connect sets up the controller's NATS connection and JetStream manager.
+func (c *Controller) connect() error {
+	// Connect to NATS.
+	opts := make([]nats.Option, 0)
 
-		// Use JWT/NKEYS based credentials if present.
-		if c.opts.NATSCredentials != "" {
-			opts = append(opts, nats.UserCredentials(c.opts.NATSCredentials))
-		} else if c.opts.NATSNKey != "" {
-			opt, err := nats.NkeyOptionFromSeed(c.opts.NATSNKey)
-			if err != nil {
-				return nil
-			}
-			opts = append(opts, opt)
-		}
+	opts = append(opts, nats.Name(c.opts.NATSClientName))
 
-		if c.opts.NATSCertificate != "" && c.opts.NATSKey != "" {
-			opts = append(opts, nats.ClientCert(c.opts.NATSCertificate, c.opts.NATSKey))
-		}
+	authOpts, err := c.authOptions()
+	if err != nil {
+		return err
+	}
+	opts = append(opts, authOpts...)
 
-		if c.opts.NATSCA != "" {
-			opts = append(opts, nats.RootCAs(c.opts.NATSCA))
-		}
+	if c.opts.NATSCertificate != "" && c.opts.NATSKey != "" {
+		opts = append(opts, nats.ClientCert(c.opts.NATSCertificate, c.opts.NATSKey))
+	}
+
+	if c.opts.NATSCA != "" {
+		opts = append(opts, nats.RootCAs(c.opts.NATSCA))
+	}
 
-		// Always attempt to have a connection to NATS.
-		opts = append(opts, nats.MaxReconnects(-1))
+	// Always attempt to have a connection to NATS.
+	opts = append(opts, nats.MaxReconnects(-1))
 
-		nc, err := nats.Connect(c.opts.NATSServerURL, opts...)
+	servers := c.opts.NATSServerURL
+	if c.opts.ServersFromSRV != "" {
+		srvServers, err := serversFromSRV(c.ctx, c.resolver, c.opts.ServersFromSRV)
 		if err != nil {
-			return fmt.Errorf("failed to connect to nats: %w", err)
+			return err
 		}
-		c.nc = nc
-		jm, err := jsm.New(c.nc)
-		if err != nil {
+		servers = strings.Join(srvServers, ",")
+		opts = append(opts, nats.SetCustomDialer(&srvDialer{name: c.opts.ServersFromSRV, resolver: c.resolver}))
+	}
+
+	nc, err := nats.Connect(servers, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	jm, domain, err := c.newJsmManager(nc)
+
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	c.nc = nc
+	if err != nil {
+		return err
+	}
+	c.jm = jm
+	c.domain = domain
+
+	return nil
+}
+
+func (c *Controller) Run() error {
+	if !c.opts.CRDConnect {
+		if err := c.connect(); err != nil {
 			return err
 		}
-		c.jm = jm
+
+		if c.opts.PublishResults {
+			c.results = c.nc
+		}
+		c.acks.sub = c.nc
+		c.dlq.conn = c.nc
+	} else {
+		if c.opts.PublishResults {
+			klog.Infof("Not publishing reconcile results: there is no controller NATS connection with CRD connect")
+		}
+		if c.opts.AckSampleMetrics {
+			klog.Infof("Not exporting ack samples: there is no controller NATS connection with CRD connect")
+		}
 	}
 
 	defer utilruntime.HandleCrash()
 
 	defer c.strQueue.ShutDown()
 	defer c.cnsQueue.ShutDown()
+	defer c.tmplQueue.ShutDown()
 
 	c.informerFactory.Start(c.ctx.Done())
+	if c.opts.SubjectsFromConfigMaps {
+		c.cmInformerFactory.Start(c.ctx.Done())
+	}
 
 	if !cache.WaitForCacheSync(c.ctx.Done(), c.strSynced) {
 		return fmt.Errorf("failed to wait for stream cache sync")
@@ -228,11 +748,31 @@ func (c *Controller) Run() error {
 	if !cache.WaitForCacheSync(c.ctx.Done(), c.cnsSynced) {
 		return fmt.Errorf("failed to wait for consumer cache sync")
 	}
+	if !cache.WaitForCacheSync(c.ctx.Done(), c.tmplSynced) {
+		return fmt.Errorf("failed to wait for consumer template cache sync")
+	}
+	if c.opts.SubjectsFromConfigMaps && !cache.WaitForCacheSync(c.ctx.Done(), c.cmSynced) {
+		return fmt.Errorf("failed to wait for configmap cache sync")
+	}
+	if c.pauseInformerFactory != nil {
+		c.pauseInformerFactory.Start(c.ctx.Done())
+		for typ, ok := range c.pauseInformerFactory.WaitForCacheSync(c.ctx.Done()) {
+			if !ok {
+				return fmt.Errorf("failed to wait for %v cache sync", typ)
+			}
+		}
+	}
 
-	go wait.Until(c.runStreamQueue, time.Second, c.ctx.Done())
-	go wait.Until(c.runConsumerQueue, time.Second, c.ctx.Done())
+	for i := 0; i < c.opts.Workers; i++ {
+		go wait.Until(c.runStreamQueue, time.Second, c.ctx.Done())
+		go wait.Until(c.runConsumerQueue, time.Second, c.ctx.Done())
+		go wait.Until(c.runConsumerTemplateQueue, time.Second, c.ctx.Done())
+	}
 	go c.cleanupStreams()
 	go c.cleanupConsumers()
+	if c.opts.StuckTerminatingThreshold > 0 {
+		go c.watchStuckTerminating()
+	}
 
 	<-c.ctx.Done()
 
@@ -259,7 +799,7 @@ func streamsMap(ss []*apis.Stream) map[string]*apis.Stream {
 }
 
 func (c *Controller) cleanupStreams() error {
-	if c.opts.ReadOnly {
+	if c.opts.ReadOnly || c.opts.DisableFinalizers {
 		return nil
 	}
 	tick := time.NewTicker(c.opts.CleanupPeriod)
@@ -272,6 +812,12 @@ func (c *Controller) cleanupStreams() error {
 		case <-c.ctx.Done():
 			return c.ctx.Err()
 		case <-tick.C:
+			// Paused, no NATS calls are made. The streams last seen before
+			// the pause are kept, so the ones deleted meanwhile are still
+			// cleaned up once resumed.
+			if c.pause.isPaused() {
+				continue
+			}
 			streams, err := c.strLister.List(labels.Everything())
 			if err != nil {
 				klog.Infof("failed to list streams for cleanup: %s", err)
@@ -293,7 +839,7 @@ func (c *Controller) cleanupStreams() error {
 						klog.Infof("stream %s/%s was not found anymore, deleting from JetStream", s.Namespace, s.Name)
 						t := k8smeta.NewTime(time.Now())
 						s.DeletionTimestamp = &t
-						if err := c.processStreamObject(s, &realJsmClient{jm: c.jm}); err != nil && !k8serrors.IsNotFound(err) {
+						if err := c.processStreamObject(c.ctx, s, c.jsmClient()); err != nil && !k8serrors.IsNotFound(err) {
 							klog.Infof("failed to delete stream %s/%s: %s", s.Namespace, s.Name, err)
 							continue
 						}
@@ -329,7 +875,7 @@ func consumerMap(cs []*apis.Consumer) map[string]*apis.Consumer {
 }
 
 func (c *Controller) cleanupConsumers() error {
-	if c.opts.ReadOnly {
+	if c.opts.ReadOnly || c.opts.DisableFinalizers || c.opts.AuditOnly {
 		return nil
 	}
 	tick := time.NewTicker(c.opts.CleanupPeriod)
@@ -342,6 +888,12 @@ func (c *Controller) cleanupConsumers() error {
 		case <-c.ctx.Done():
 			return c.ctx.Err()
 		case <-tick.C:
+			// Paused, no NATS calls are made. The consumers last seen before
+			// the pause are kept, so the ones deleted meanwhile are still
+			// cleaned up once resumed.
+			if c.pause.isPaused() {
+				continue
+			}
 			consumers, err := c.cnsLister.List(labels.Everything())
 			if err != nil {
 				klog.Infof("failed to list consumers for cleanup: %s", err)
@@ -363,7 +915,7 @@ func (c *Controller) cleanupConsumers() error {
 						klog.Infof("consumer %s/%s was not found anymore, deleting from JetStream", cns.Namespace, cns.Name)
 						t := k8smeta.NewTime(time.Now())
 						cns.DeletionTimestamp = &t
-						if err := c.processConsumerObject(cns, &realJsmClient{jm: c.jm}); err != nil && !k8serrors.IsNotFound(err) {
+						if err := c.processConsumerObject(c.ctx, cns, c.jsmClient()); err != nil && !k8serrors.IsNotFound(err) {
 							klog.Infof("failed to delete consumer %s/%s: %s", cns.Namespace, cns.Name, err)
 							continue
 						}
@@ -380,18 +932,232 @@ func (c *Controller) cleanupConsumers() error {
 	}
 }
 
+// acquireConn waits for a free NATS connection slot, and returns a func to
+// release it once the connection is closed.
+func (c *Controller) acquireConn(ctx context.Context) (release func(), err error) {
+	if c.connSem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case c.connSem <- struct{}{}:
+		return func() { <-c.connSem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to wait for a nats connection slot: %w", ctx.Err())
+	}
+}
+
+// clusterLimiter is a token bucket rate limiter per NATS cluster.
+type clusterLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// newClusterLimiter returns nil, which never limits, for a non-positive rate.
+func newClusterLimiter(perSecond float64, burst int) *clusterLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &clusterLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// wait blocks until a reconcile against the cluster with the given servers
+// may proceed. The order of the servers doesn't matter, they are sorted in
+// place.
+func (l *clusterLimiter) wait(ctx context.Context, servers []string) error {
+	if l == nil {
+		return nil
+	}
+
+	sort.Strings(servers)
+	key := strings.Join(servers, ",")
+
+	l.mu.Lock()
+	lim, ok := l.limiters[key]
+	if !ok {
+		lim = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[key] = lim
+	}
+	l.mu.Unlock()
+
+	if err := lim.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to wait for nats cluster rate limit: %w", err)
+	}
+	return nil
+}
+
+// clusterServers are the servers a reconcile connects to, used to identify
+// its NATS cluster.
+func (c *Controller) clusterServers(specServers, accServers []string) []string {
+	if !c.opts.CRDConnect && c.opts.ServersFromSRV != "" {
+		return []string{c.opts.ServersFromSRV}
+	}
+	if !c.opts.CRDConnect {
+		return []string{c.opts.NATSServerURL}
+	}
+	servers := make([]string, 0, len(specServers)+len(accServers))
+	servers = append(servers, specServers...)
+	return append(servers, accServers...)
+}
+
+// notFoundTracker remembers when a queued key was first missing from the
+// informer cache.
+type notFoundTracker struct {
+	mu    sync.Mutex
+	first map[string]time.Time
+}
+
+// missed records that key wasn't found at now, and returns when it was first
+// missed.
+func (t *notFoundTracker) missed(key string, now time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first, ok := t.first[key]
+	if !ok {
+		first = now
+		t.first[key] = now
+	}
+	return first
+}
+
+func (t *notFoundTracker) forget(key string) {
+	t.mu.Lock()
+	delete(t.first, key)
+	t.mu.Unlock()
+}
+
+// forcedReconciles remembers the resources whose next reconcile mustn't be
+// skipped by ReconcileOnChangeOnly, such as the consumers a recreated stream
+// lost while they were Ready.
+type forcedReconciles struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (f *forcedReconciles) add(key string) {
+	f.mu.Lock()
+	f.keys[key] = true
+	f.mu.Unlock()
+}
+
+// take reports whether key was forced, and clears it.
+func (f *forcedReconciles) take(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	forced := f.keys[key]
+	delete(f.keys, key)
+	return forced
+}
+
+// requeueNotFound requeues a resource of kind missing from the informer cache
+// while it is within the NotFoundRequeueWindow, and reports whether it did.
+// Once the window has passed the resource is considered deleted.
+func (c *Controller) requeueNotFound(q workqueue.RateLimitingInterface, kind, ns, name string) bool {
+	window := c.opts.NotFoundRequeueWindow
+	if window <= 0 {
+		return false
+	}
+
+	// Streams and consumers may share names, each has its own window.
+	key := outcomeKey(kind, ns, name)
+	first := c.notFound.missed(key, time.Now())
+	if time.Since(first) >= window {
+		c.notFound.forget(key)
+		return false
+	}
+
+	delay := notFoundRequeueDelay
+	if delay > window {
+		delay = window
+	}
+	q.AddAfter(objectKey(ns, name), delay)
+	return true
+}
+
 func (c *Controller) normalEvent(o runtime.Object, reason, message string) {
 	if c.rec != nil {
 		c.rec.Event(o, k8sapi.EventTypeNormal, reason, message)
 	}
 }
 
+// noopEvent records an event for a reconcile that intentionally did nothing,
+// unless those are suppressed.
+func (c *Controller) noopEvent(o runtime.Object, reason, message string) {
+	if c.opts.SuppressNoopEvents {
+		klog.V(4).Infof("%s: %s", reason, message)
+		return
+	}
+	c.normalEvent(o, reason, message)
+}
+
 func (c *Controller) warningEvent(o runtime.Object, reason, message string) {
 	if c.rec != nil {
 		c.rec.Event(o, k8sapi.EventTypeWarning, reason, message)
 	}
 }
 
+// reconcileTimeoutError is the error of a reconcile that didn't finish
+// within the MaxReconcileDuration.
+type reconcileTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *reconcileTimeoutError) Error() string {
+	return fmt.Sprintf("reconcile timed out after %s: %s", e.timeout, e.err)
+}
+
+func (e *reconcileTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// reconcileContext returns the context a single reconcile runs in, cancelled
+// once the MaxReconcileDuration is exceeded.
+func (c *Controller) reconcileContext() (context.Context, context.CancelFunc) {
+	if c.opts.MaxReconcileDuration <= 0 {
+		return context.WithCancel(c.ctx)
+	}
+	return context.WithTimeout(c.ctx, c.opts.MaxReconcileDuration)
+}
+
+// warnReconcileTimeout emits a ReconcileTimeout event if err was caused by
+// the reconcile exceeding the MaxReconcileDuration.
+func (c *Controller) warnReconcileTimeout(o runtime.Object, err error) {
+	var terr *reconcileTimeoutError
+	if errors.As(err, &terr) {
+		c.warningEvent(o, "ReconcileTimeout", fmt.Sprintf("Reconcile did not finish within %s", terr.timeout))
+	}
+}
+
+// waitingForLeader emits a WaitingForLeader event if err was caused by
+// JetStream electing a leader, which is retried until one is elected.
+func (c *Controller) waitingForLeader(o runtime.Object, err error) {
+	if classifyError(err) == errKindNoLeader {
+		c.normalEvent(o, "WaitingForLeader", fmt.Sprintf("JetStream has no leader, retrying: %s", err))
+	}
+}
+
+// warnUnsupportedFeature emits an Unsupported event if err was caused by a
+// spec field the connected server is too old for.
+func (c *Controller) warnUnsupportedFeature(o runtime.Object, err error) {
+	var ferr *unsupportedFeatureError
+	if errors.As(err, &ferr) {
+		c.warningEvent(o, "Unsupported", ferr.Error())
+	}
+}
+
 func splitNamespaceName(item interface{}) (ns string, name string, err error) {
 	defer func() {
 		if err != nil {
@@ -457,10 +1223,16 @@ func processQueueNext(q workqueue.RateLimitingInterface, c jsmClient, process pr
 		return
 	}
 
-	utilruntime.HandleError(err)
+	var quiet *quietError
+	if !errors.As(err, &quiet) {
+		utilruntime.HandleError(err)
+	}
 
-	if q.NumRequeues(item) < maxQueueRetries {
-		// Failed to process item, try again.
+	kind := classifyError(err)
+	if q.NumRequeues(item) < maxQueueRetries || kind == errKindConnectionLost || kind == errKindNoLeader {
+		// Failed to process item, try again. A lost connection or leader
+		// election is always retried, as it isn't a problem with the item
+		// itself.
 		q.AddRateLimited(item)
 		return
 	}
@@ -470,6 +1242,83 @@ func processQueueNext(q workqueue.RateLimitingInterface, c jsmClient, process pr
 	q.Forget(item)
 }
 
+// requeueAfterSuccess schedules another reconcile of o in q after interval,
+// unless it's zero, o is being deleted, or such reconciles would be skipped
+// by ReconcileOnChangeOnly. The queue keeps a single pending requeue per
+// resource.
+func (c *Controller) requeueAfterSuccess(q workqueue.DelayingInterface, o k8smeta.Object, interval time.Duration) {
+	if interval <= 0 || c.opts.ReconcileOnChangeOnly || o.GetDeletionTimestamp() != nil {
+		return
+	}
+	q.AddAfter(objectKey(o.GetNamespace(), o.GetName()), interval)
+}
+
+// unchangedSinceReconcile reports whether ReconcileOnChangeOnly lets a
+// reconcile of the resource with meta, spec and st be skipped: the current
+// generation of its spec was already reconciled successfully, and it isn't
+// being deleted. ReconcileAll still reconciles every resource.
+func (c *Controller) unchangedSinceReconcile(meta k8smeta.Object, spec interface{}, st apis.Status) bool {
+	if !c.opts.ReconcileOnChangeOnly || c.outcomes != nil || meta.GetDeletionTimestamp() != nil {
+		return false
+	}
+	if meta.GetGeneration() != st.ObservedGeneration {
+		return false
+	}
+	if st.ObservedSpecHash != "" && st.ObservedSpecHash != specHash(spec) {
+		return false
+	}
+	for _, cond := range st.Conditions {
+		if cond.Type == readyCondType {
+			return cond.Status == k8sapi.ConditionTrue
+		}
+	}
+	return false
+}
+
+// liveStateDue reports whether the live state in status should be refreshed,
+// because it never was or StatusRefreshInterval has passed since.
+func (c *Controller) liveStateDue(st *apis.LiveState, now time.Time) bool {
+	if c.opts.StatusRefreshInterval <= 0 {
+		return false
+	}
+	if st == nil {
+		return true
+	}
+	refreshed, err := time.Parse(time.RFC3339, st.RefreshedAt)
+	if err != nil {
+		return true
+	}
+	return now.Sub(refreshed) >= c.opts.StatusRefreshInterval
+}
+
+// pausedUntil returns the time until which reconciliation of o is paused,
+// and whether that time is still ahead of now.
+func pausedUntil(o k8smeta.Object, now time.Time) (time.Time, bool, error) {
+	v, ok := o.GetAnnotations()[pauseUntilAnnotation]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	until, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s annotation %q: %w", pauseUntilAnnotation, v, err)
+	}
+
+	return until, now.Before(until), nil
+}
+
+// specHash returns a hash of a resource spec, to tell whether the spec a
+// reconcile acted on is still the latest one.
+func specHash(spec interface{}) string {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return ""
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
 func upsertCondition(cs []apis.Condition, next apis.Condition) []apis.Condition {
 	for i := 0; i < len(cs); i++ {
 		if cs[i].Type != next.Type {
@@ -483,6 +1332,35 @@ func upsertCondition(cs []apis.Condition, next apis.Condition) []apis.Condition
 	return append(cs, next)
 }
 
+// removeCondition returns cs without the condition of type typ.
+func removeCondition(cs []apis.Condition, typ string) []apis.Condition {
+	res := cs[:0]
+	for _, cond := range cs {
+		if cond.Type != typ {
+			res = append(res, cond)
+		}
+	}
+	return res
+}
+
+// truncateMessage shortens msg to at most max bytes, ending it with an
+// ellipsis and without splitting a multi-byte character.
+func truncateMessage(msg string, max int) string {
+	const ellipsis = "..."
+	if max <= 0 || len(msg) <= max {
+		return msg
+	}
+	if max <= len(ellipsis) {
+		return ellipsis[:max]
+	}
+
+	cut := max - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + ellipsis
+}
+
 func shouldEnqueue(prevObj, nextObj interface{}) bool {
 	type crd interface {
 		GetDeletionTimestamp() *k8smeta.Time
@@ -528,3 +1406,13 @@ func eventHandlers(ctx context.Context, q workqueue.RateLimitingInterface) cache
 		},
 	}
 }
+
+// defaultWorkers returns the number of workers to use when none is set, one
+// per usable CPU up to maxDefaultWorkers.
+func defaultWorkers() int {
+	n := goruntime.GOMAXPROCS(0)
+	if n > maxDefaultWorkers {
+		return maxDefaultWorkers
+	}
+	return n
+}
@@ -1,17 +1,25 @@
 package jetstream
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	jsmapi "github.com/nats-io/jsm.go/api"
 	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nats.go"
 
 	k8sapis "k8s.io/api/core/v1"
 	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -152,6 +160,52 @@ func TestProcessQueueNext(t *testing.T) {
 		}
 	})
 
+	t.Run("connection lost", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond)
+		q := workqueue.NewNamedRateLimitingQueue(limiter, "StreamsTest")
+		defer q.ShutDown()
+
+		key := "default/mystream"
+		q.Add(key)
+
+		// Unlike other errors, a lost connection is retried past the
+		// maximum number of retries.
+		for i := 0; i < maxQueueRetries+2; i++ {
+			processQueueNext(q, &mockJsmClient{}, func(ns, name string, c jsmClient) error {
+				return fmt.Errorf("failed to update stream: %w", nats.ErrConnectionClosed)
+			})
+		}
+
+		if got, want := q.NumRequeues(key), maxQueueRetries+2; got != want {
+			t.Error("unexpected number of requeues")
+			t.Fatalf("got=%d; want=%d", got, want)
+		}
+	})
+
+	t.Run("no leader", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond)
+		q := workqueue.NewNamedRateLimitingQueue(limiter, "StreamsTest")
+		defer q.ShutDown()
+
+		key := "default/mystream"
+		q.Add(key)
+
+		// A leader election is retried until a leader is elected too.
+		for i := 0; i < maxQueueRetries+2; i++ {
+			processQueueNext(q, &mockJsmClient{}, func(ns, name string, c jsmClient) error {
+				return fmt.Errorf("failed to load stream: %w", nats.ErrNoResponders)
+			})
+		}
+
+		if got, want := q.NumRequeues(key), maxQueueRetries+2; got != want {
+			t.Fatalf("got=%d; want=%d", got, want)
+		}
+	})
+
 	t.Run("process ok", func(t *testing.T) {
 		t.Parallel()
 
@@ -234,6 +288,62 @@ func TestUpsertCondition(t *testing.T) {
 	}
 }
 
+func TestSetStreamErroredTruncatesMessage(t *testing.T) {
+	t.Parallel()
+
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "orders"},
+		Spec:       apis.StreamSpec{Name: "orders"},
+	}
+	ifc := clientsetfake.NewSimpleClientset(str).JetstreamV1beta2().Streams("default")
+
+	const max = 64
+	got, err := setStreamErrored(context.Background(), str, ifc, errors.New(strings.Repeat("x", max*2)), max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := got.Status.Conditions[0].Message
+	if len(msg) != max {
+		t.Error("unexpected message length")
+		t.Fatalf("got=%d; want=%d", len(msg), max)
+	}
+	if !strings.HasSuffix(msg, "...") {
+		t.Error("unexpected message suffix")
+		t.Fatalf("got=%s; want=...", msg[len(msg)-10:])
+	}
+
+	short := "failed to load stream"
+	got, err = setStreamErrored(context.Background(), got, ifc, errors.New(short), max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := got.Status.Conditions[0].Message; msg != short {
+		t.Error("unexpected message")
+		t.Fatalf("got=%s; want=%s", msg, short)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		msg  string
+		max  int
+		want string
+	}{
+		{msg: "short", max: 10, want: "short"},
+		{msg: "0123456789abc", max: 10, want: "0123456..."},
+		{msg: "héllo wörld", max: 5, want: "h..."},
+		{msg: "anything", max: 0, want: "anything"},
+	}
+	for _, c := range cases {
+		if got := truncateMessage(c.msg, c.max); got != c.want {
+			t.Error("unexpected truncation")
+			t.Fatalf("got=%q; want=%q", got, c.want)
+		}
+	}
+}
+
 func TestShouldEnqueue(t *testing.T) {
 	t.Parallel()
 
@@ -338,3 +448,139 @@ func TestShouldEnqueue(t *testing.T) {
 		})
 	}
 }
+
+func TestAcquireConn(t *testing.T) {
+	t.Parallel()
+
+	t.Run("limit enforced", func(t *testing.T) {
+		t.Parallel()
+
+		limit := 2
+		ctrl := &Controller{connSem: make(chan struct{}, limit)}
+
+		var (
+			mu        sync.Mutex
+			active    int
+			maxActive int
+			wg        sync.WaitGroup
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				release, err := ctrl.acquireConn(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer release()
+
+				mu.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if maxActive > limit {
+			t.Error("too many concurrent connections")
+			t.Fatalf("got=%d; want<=%d", maxActive, limit)
+		}
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := &Controller{connSem: make(chan struct{}, 1)}
+		release, err := ctrl.acquireConn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := ctrl.acquireConn(ctx); err == nil {
+			t.Fatal("unexpected success")
+		}
+	})
+}
+
+func TestClusterLimiter(t *testing.T) {
+	t.Parallel()
+
+	// One reconcile per 50ms, so the 3rd and 4th wait 100ms in total. The
+	// order of the servers doesn't change the cluster.
+	lim := newClusterLimiter(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		servers := []string{"nats://a:4222", "nats://b:4222"}
+		if i%2 == 1 {
+			servers[0], servers[1] = servers[1], servers[0]
+		}
+		if err := lim.wait(ctx, servers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := time.Since(start), 90*time.Millisecond; got < want {
+		t.Fatalf("rate not enforced: got=%s; want>=%s", got, want)
+	}
+
+	// Another cluster has its own budget.
+	start = time.Now()
+	if err := lim.wait(ctx, []string{"nats://c:4222"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := time.Since(start), 40*time.Millisecond; got > want {
+		t.Fatalf("unexpected wait for another cluster: got=%s; want<%s", got, want)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := lim.wait(cancelled, []string{"nats://a:4222", "nats://b:4222"}); err == nil {
+		t.Fatal("expected error waiting with a cancelled context")
+	}
+
+	var unlimited *clusterLimiter
+	if err := unlimited.wait(cancelled, nil); err != nil {
+		t.Fatalf("unexpected error without a limit: %s", err)
+	}
+}
+
+func TestNewControllerWorkers(t *testing.T) {
+	t.Parallel()
+
+	newController := func(workers int) *Controller {
+		return NewController(Options{
+			Ctx:            context.Background(),
+			KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+			JetstreamIface: clientsetfake.NewSimpleClientset(),
+			Recorder:       record.NewFakeRecorder(10),
+			Workers:        workers,
+		})
+	}
+
+	got := newController(0).opts.Workers
+	if got < 1 || got > maxDefaultWorkers {
+		t.Fatalf("got=%d; want between 1 and %d", got, maxDefaultWorkers)
+	}
+	if want := defaultWorkers(); got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+
+	if got, want := newController(3).opts.Workers, 3; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package jetstream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// connectError is a failure to connect to the NATS servers of a resource.
+type connectError struct {
+	servers string
+	err     error
+}
+
+func (e *connectError) Error() string {
+	return fmt.Sprintf("failed to connect to nats-servers(%s): %s", e.servers, e.err)
+}
+
+func (e *connectError) Unwrap() error { return e.err }
+
+// quietError is a reconcile error already reported, which is requeued without
+// being logged again.
+type quietError struct {
+	err error
+}
+
+func (e *quietError) Error() string { return e.err.Error() }
+func (e *quietError) Unwrap() error { return e.err }
+
+// connCooldown tracks, per resource, the last connection failure reported and
+// until when identical ones are suppressed.
+type connCooldown struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]connCooldownEntry
+}
+
+type connCooldownEntry struct {
+	msg   string
+	until time.Time
+}
+
+func newConnCooldown(window time.Duration) *connCooldown {
+	return &connCooldown{
+		window:  window,
+		entries: make(map[string]connCooldownEntry),
+	}
+}
+
+// allow reports whether a connection failure with msg should be reported for
+// key at now, starting a new cooldown if so.
+func (cc *connCooldown) allow(key, msg string, now time.Time) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if e, ok := cc.entries[key]; ok && e.msg == msg && now.Before(e.until) {
+		return false
+	}
+	cc.entries[key] = connCooldownEntry{msg: msg, until: now.Add(cc.window)}
+	return true
+}
+
+func (cc *connCooldown) reset(key string) {
+	cc.mu.Lock()
+	delete(cc.entries, key)
+	cc.mu.Unlock()
+}
+
+// reportConnectError emits a ConnectionFailed event when err is a connection
+// failure, or a ConnectionLost event when the NATS connection dropped, unless
+// an identical one was reported for key within the ConnectionErrorCooldown, in
+// which case err is returned as a quietError so it's requeued without being
+// logged. A successful reconcile ends the cooldown.
+func (c *Controller) reportConnectError(o runtime.Object, key string, err error) error {
+	if err == nil {
+		c.connCooldown.reset(key)
+		return nil
+	}
+
+	var reason, msg string
+	var cerr *connectError
+	switch {
+	case errors.As(err, &cerr):
+		reason, msg = "ConnectionFailed", cerr.Error()
+	case classifyError(err) == errKindConnectionLost:
+		reason, msg = "ConnectionLost", fmt.Sprintf("NATS connection lost, retrying: %s", err)
+	default:
+		return err
+	}
+	if !c.connCooldown.allow(key, msg, time.Now()) {
+		return &quietError{err: err}
+	}
+	c.warningEvent(o, reason, msg)
+	return err
+}
@@ -0,0 +1,94 @@
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nats.go"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReportConnectErrorCooldown(t *testing.T) {
+	t.Parallel()
+
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                     context.Background(),
+		KubeIface:               k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:          clientsetfake.NewSimpleClientset(),
+		Recorder:                rec,
+		ConnectionErrorCooldown: time.Hour,
+	})
+
+	str := &apis.Stream{ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "orders"}}
+	key := outcomeKey("stream", str.Namespace, str.Name)
+	refused := &connectError{servers: "nats://leaf:4222", err: errors.New("connection refused")}
+
+	var quiet *quietError
+	for i := 0; i < 3; i++ {
+		err := ctrl.reportConnectError(str, key, refused)
+		if !errors.Is(err, refused) {
+			t.Fatalf("got=%v; want=%v", err, refused)
+		}
+		if got, want := errors.As(err, &quiet), i > 0; got != want {
+			t.Fatalf("failure %d: got quiet=%v; want=%v", i, got, want)
+		}
+	}
+	if got, want := len(rec.Events), 1; got != want {
+		t.Fatalf("got=%d; want=%d events", got, want)
+	}
+	<-rec.Events
+
+	// A different failure is reported right away.
+	timeout := &connectError{servers: "nats://leaf:4222", err: errors.New("i/o timeout")}
+	if err := ctrl.reportConnectError(str, key, timeout); errors.As(err, &quiet) {
+		t.Fatalf("got=%v; want reported error", err)
+	}
+	if got, want := len(rec.Events), 1; got != want {
+		t.Fatalf("got=%d; want=%d events", got, want)
+	}
+	<-rec.Events
+
+	// Success ends the cooldown.
+	if err := ctrl.reportConnectError(str, key, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctrl.reportConnectError(str, key, timeout); errors.As(err, &quiet) {
+		t.Fatalf("got=%v; want reported error", err)
+	}
+	if got, want := len(rec.Events), 1; got != want {
+		t.Fatalf("got=%d; want=%d events", got, want)
+	}
+
+	<-rec.Events
+
+	// Dropped connections are suppressed the same way.
+	lost := fmt.Errorf("failed to update stream: %w", nats.ErrConnectionClosed)
+	for i := 0; i < 3; i++ {
+		err := ctrl.reportConnectError(str, key, lost)
+		if got, want := errors.As(err, &quiet), i > 0; got != want {
+			t.Fatalf("lost connection %d: got quiet=%v; want=%v", i, got, want)
+		}
+	}
+	if got, want := len(rec.Events), 1; got != want {
+		t.Fatalf("got=%d; want=%d events", got, want)
+	}
+	if got := <-rec.Events; !strings.Contains(got, "ConnectionLost") {
+		t.Fatalf("got=%q; want a ConnectionLost event", got)
+	}
+
+	// Other errors are left alone.
+	other := errors.New("stream not found")
+	if err := ctrl.reportConnectError(str, key, other); err != other {
+		t.Fatalf("got=%v; want=%v", err, other)
+	}
+}
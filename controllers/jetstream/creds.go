@@ -0,0 +1,196 @@
+package jetstream
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretKeyRef refers to a key of a secret in a namespace.
+type SecretKeyRef struct {
+	Namespace string
+	Name      string
+	Key       string
+}
+
+// KubernetesCredentialsSource is the source of Account creds read from a
+// Kubernetes secret, the default.
+const KubernetesCredentialsSource = "kubernetes"
+
+// CredentialsProvider reads the NATS user credentials of Accounts from a
+// secret backend, such as Vault.
+type CredentialsProvider interface {
+	// Credentials returns the creds file of ref, set on an Account in
+	// namespace ns.
+	Credentials(ctx context.Context, ns string, ref *apis.CredsSecret) ([]byte, error)
+}
+
+// secretCredentials reads creds from Kubernetes secrets.
+type secretCredentials struct {
+	c *Controller
+}
+
+func (p secretCredentials) Credentials(ctx context.Context, ns string, ref *apis.CredsSecret) ([]byte, error) {
+	secret, err := p.c.getSecret(ctx, ns, ref.Secret.Name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[ref.File]
+	if !ok {
+		return nil, fmt.Errorf("creds %q not found in secret %q", ref.File, ref.Secret.Name)
+	}
+	return data, nil
+}
+
+// credentialsProvider returns the provider of creds from source, Kubernetes
+// secrets unless one is set for it in CredentialsProviders.
+func (c *Controller) credentialsProvider(source string) (CredentialsProvider, error) {
+	if source == "" {
+		source = KubernetesCredentialsSource
+	}
+	if p, ok := c.opts.CredentialsProviders[source]; ok {
+		return p, nil
+	}
+	if source == KubernetesCredentialsSource {
+		return secretCredentials{c: c}, nil
+	}
+	return nil, fmt.Errorf("no credentials provider for source %q", source)
+}
+
+// getCreds reads the creds of account in ns from their source, and returns
+// the path they're written to in the cache dir.
+func (c *Controller) getCreds(ctx context.Context, ns, account string, ref *apis.CredsSecret) (string, error) {
+	// The file name comes from the Account as is, it mustn't lead out of
+	// the cache dir.
+	if ref.File == "" || ref.File == "." || ref.File == ".." || strings.ContainsAny(ref.File, `/\`) {
+		return "", fmt.Errorf("invalid creds file name %q", ref.File)
+	}
+
+	p, err := c.credentialsProvider(ref.Source)
+	if err != nil {
+		return "", err
+	}
+	data, err := p.Credentials(ctx, ns, ref)
+	if err != nil {
+		return "", err
+	}
+
+	accDir := filepath.Join(c.cacheDir, ns, account)
+	if err := os.MkdirAll(accDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(accDir, ref.File)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// resourceCreds returns the creds file a resource connects with: its own
+// creds when set, otherwise the DefaultCredentialsSecret, unless the resource
+// authenticates some other way.
+func (c *Controller) resourceCreds(own string, otherAuth bool) (string, error) {
+	ref := c.opts.DefaultCredentialsSecret
+	if own != "" || otherAuth || ref.Name == "" {
+		return own, nil
+	}
+
+	secret, err := c.getSecret(c.ctx, ref.Namespace, ref.Name)
+	if err != nil {
+		return "", err
+	}
+	data, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("default credentials %q not found in secret %s/%s", ref.Key, ref.Namespace, ref.Name)
+	}
+
+	// Write the default credentials to the cache dir.
+	dir := filepath.Join(c.cacheDir, "default-creds", ref.Namespace, ref.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, ref.Key)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// getSecret returns the secret ns/name, from the secret cache when it was read
+// within SecretCacheTTL.
+func (c *Controller) getSecret(ctx context.Context, ns, name string) (*k8sapi.Secret, error) {
+	key := objectKey(ns, name)
+	if secret, ok := c.secretCache.get(key); ok {
+		return secret, nil
+	}
+
+	secret, err := c.ki.Secrets(ns).Get(ctx, name, k8smeta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.secretCache.put(key, secret)
+	return secret, nil
+}
+
+// secretCache remembers secrets recently read from the API server, so
+// frequent reconciles of resources sharing credentials don't read them every
+// time. A changed secret is picked up once its entry expires.
+type secretCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]secretCacheEntry
+}
+
+type secretCacheEntry struct {
+	secret  *k8sapi.Secret
+	expires time.Time
+}
+
+func newSecretCache(ttl time.Duration) *secretCache {
+	return &secretCache{
+		ttl:     ttl,
+		entries: make(map[string]secretCacheEntry),
+	}
+}
+
+func (sc *secretCache) get(key string) (*k8sapi.Secret, bool) {
+	if sc.ttl <= 0 {
+		return nil, false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	e, ok := sc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(sc.entries, key)
+		return nil, false
+	}
+	return e.secret, true
+}
+
+func (sc *secretCache) put(key string, secret *k8sapi.Secret) {
+	if sc.ttl <= 0 {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.entries[key] = secretCacheEntry{
+		secret:  secret,
+		expires: time.Now().Add(sc.ttl),
+	}
+}
@@ -0,0 +1,235 @@
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8sapis "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestResourceCreds(t *testing.T) {
+	t.Parallel()
+
+	kc := k8sclientsetfake.NewSimpleClientset(&k8sapis.Secret{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "nats",
+			Name:      "default-creds",
+		},
+		Data: map[string][]byte{
+			"user.creds": []byte("default user"),
+		},
+	})
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      kc,
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+		DefaultCredentialsSecret: SecretKeyRef{
+			Namespace: "nats",
+			Name:      "default-creds",
+			Key:       "user.creds",
+		},
+	})
+	defer os.RemoveAll(ctrl.cacheDir)
+
+	// Without creds of its own, a resource uses the default ones.
+	got, err := ctrl.resourceCreds("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "default user" {
+		t.Fatalf("got=%q; want=%q", data, "default user")
+	}
+
+	// Its own creds override the default ones.
+	if got, err := ctrl.resourceCreds("/etc/nats/own.creds", false); err != nil || got != "/etc/nats/own.creds" {
+		t.Fatalf("got=%q, %v; want=%q", got, err, "/etc/nats/own.creds")
+	}
+
+	// So does authenticating some other way.
+	if got, err := ctrl.resourceCreds("", true); err != nil || got != "" {
+		t.Fatalf("got=%q, %v; want no creds", got, err)
+	}
+}
+
+func TestResourceCredsWithoutDefault(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+	defer os.RemoveAll(ctrl.cacheDir)
+
+	if got, err := ctrl.resourceCreds("", false); err != nil || got != "" {
+		t.Fatalf("got=%q, %v; want no creds", got, err)
+	}
+}
+
+func TestResourceCredsSecretCache(t *testing.T) {
+	t.Parallel()
+
+	kc := k8sclientsetfake.NewSimpleClientset(&k8sapis.Secret{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "nats",
+			Name:      "default-creds",
+		},
+		Data: map[string][]byte{
+			"user.creds": []byte("default user"),
+		},
+	})
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      kc,
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+		SecretCacheTTL: time.Minute,
+		DefaultCredentialsSecret: SecretKeyRef{
+			Namespace: "nats",
+			Name:      "default-creds",
+			Key:       "user.creds",
+		},
+	})
+	defer os.RemoveAll(ctrl.cacheDir)
+
+	secretGets := func() (n int) {
+		for _, a := range kc.Actions() {
+			if a.GetVerb() == "get" && a.GetResource().Resource == "secrets" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Within the TTL the secret is read once.
+	for i := 0; i < 3; i++ {
+		if _, err := ctrl.resourceCreds("", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := secretGets(); got != 1 {
+		t.Fatalf("got=%d; want=1 secret reads", got)
+	}
+
+	// Once it expired, the secret is read again.
+	key := objectKey("nats", "default-creds")
+	ctrl.secretCache.mu.Lock()
+	e := ctrl.secretCache.entries[key]
+	e.expires = time.Now().Add(-time.Second)
+	ctrl.secretCache.entries[key] = e
+	ctrl.secretCache.mu.Unlock()
+	if _, err := ctrl.resourceCreds("", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := secretGets(); got != 2 {
+		t.Fatalf("got=%d; want=2 secret reads", got)
+	}
+}
+
+type stubCredentialsProvider map[string]string
+
+func (p stubCredentialsProvider) Credentials(ctx context.Context, ns string, ref *apis.CredsSecret) ([]byte, error) {
+	creds, ok := p[ns+"/"+ref.Secret.Name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(creds), nil
+}
+
+func TestGetCreds(t *testing.T) {
+	t.Parallel()
+
+	kc := k8sclientsetfake.NewSimpleClientset(&k8sapis.Secret{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "account-creds",
+		},
+		Data: map[string][]byte{
+			"user.creds": []byte("kubernetes user"),
+		},
+	})
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      kc,
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+		CredentialsProviders: map[string]CredentialsProvider{
+			"vault": stubCredentialsProvider{"default/nats/account": "vault user"},
+		},
+	})
+	defer os.RemoveAll(ctrl.cacheDir)
+
+	for _, tc := range []struct {
+		name string
+		ref  apis.CredsSecret
+		want string
+	}{
+		{
+			name: "kubernetes by default",
+			ref:  apis.CredsSecret{File: "user.creds", Secret: apis.SecretRef{Name: "account-creds"}},
+			want: "kubernetes user",
+		},
+		{
+			name: "plugged in provider",
+			ref:  apis.CredsSecret{File: "user.creds", Secret: apis.SecretRef{Name: "nats/account"}, Source: "vault"},
+			want: "vault user",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := ctrl.getCreds(context.Background(), "default", "account", &tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.want {
+				t.Fatalf("got=%q; want=%q", data, tc.want)
+			}
+		})
+	}
+
+	// The creds are only readable by the controller.
+	path, err := ctrl.getCreds(context.Background(), "default", "account",
+		&apis.CredsSecret{File: "user.creds", Secret: apis.SecretRef{Name: "account-creds"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0600 {
+		t.Fatalf("got=%o; want=600", got)
+	}
+
+	// An unknown source fails rather than falling back to Kubernetes.
+	ref := &apis.CredsSecret{File: "user.creds", Secret: apis.SecretRef{Name: "account-creds"}, Source: "aws"}
+	if _, err := ctrl.getCreds(context.Background(), "default", "account", ref); err == nil {
+		t.Fatal("got nil error; want no provider for source aws")
+	}
+
+	// File names leading out of the cache dir are refused.
+	for _, file := range []string{"../../user.creds", "/etc/passwd", ".."} {
+		ref := &apis.CredsSecret{File: file, Secret: apis.SecretRef{Name: "nats/account"}, Source: "vault"}
+		if _, err := ctrl.getCreds(context.Background(), "default", "account", ref); err == nil {
+			t.Fatalf("got nil error; want creds file %q refused", file)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	klog "k8s.io/klog/v2"
+)
+
+// DiffStreamPath is the admin endpoint path prefix serving stream diffs, as
+// in GET /diff/stream/<namespace>/<name>.
+const DiffStreamPath = "/diff/stream/"
+
+// fieldDiff is a managed field whose value in NATS differs from the spec.
+type fieldDiff struct {
+	Field   string      `json:"field"`
+	Current interface{} `json:"current"`
+	Desired interface{} `json:"desired"`
+}
+
+// streamDiff is the difference between a Stream and its stream in NATS.
+type streamDiff struct {
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Stream    string      `json:"stream"`
+	Exists    bool        `json:"exists"`
+	Changes   []fieldDiff `json:"changes"`
+}
+
+// DiffStream serves the changes reconciling a Stream would apply to its
+// stream in NATS, without applying them. Not available with CRDConnect.
+func (c *Controller) DiffStream(w http.ResponseWriter, r *http.Request) {
+	if c.opts.CRDConnect {
+		http.Error(w, "stream diffs need the controller's NATS connection", http.StatusNotImplemented)
+		return
+	}
+	jsmc := c.jsmClient()
+	if jsmc.jm == nil {
+		http.Error(w, "not connected to NATS yet", http.StatusServiceUnavailable)
+		return
+	}
+	c.serveStreamDiff(w, r, jsmc)
+}
+
+func (c *Controller) serveStreamDiff(w http.ResponseWriter, r *http.Request, jsmc jsmClient) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, DiffStreamPath), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	ns, name := parts[0], parts[1]
+
+	str, err := c.strLister.Streams(ns).Get(name)
+	if k8serrors.IsNotFound(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Diff the spec a reconcile would apply: mutated, with the subjects of
+	// subjectsFrom and without duplicates.
+	spec, err := c.mutateStreamSpec(str)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if spec.Subjects, _, err = c.subjectsFrom(ns, spec); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	spec.Subjects, _ = dedupeSubjects(spec.Subjects)
+
+	diff, err := computeStreamDiff(r.Context(), jsmc, spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	diff.Namespace, diff.Name = ns, name
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		klog.Infof("failed to write stream diff: %s", err)
+	}
+}
+
+// computeStreamDiff returns the managed fields an update of the stream to
+// spec would change.
+func computeStreamDiff(ctx context.Context, c jsmClient, spec apis.StreamSpec) (streamDiff, error) {
+	diff := streamDiff{Stream: spec.Name, Changes: []fieldDiff{}}
+
+	desired, err := streamSpecToConfig(spec)
+	if err != nil {
+		return diff, err
+	}
+
+	var apierr jsmapi.ApiError
+	js, err := c.LoadStream(ctx, spec.Name)
+	if errors.As(err, &apierr) && apierr.NotFoundError() {
+		return diff, nil
+	} else if err != nil {
+		return diff, err
+	}
+	diff.Exists = true
+
+	current := js.Configuration()
+	if desired.Placement == nil {
+		desired.Placement = current.Placement
+	}
+	diff.Changes = diffStreamConfig(current, desired)
+	return diff, nil
+}
+
+// diffStreamConfig returns the managed fields of desired that differ from
+// current, as mergeStreamConfig would apply them.
+func diffStreamConfig(current, desired jsmapi.StreamConfig) []fieldDiff {
+	cv, dv := reflect.ValueOf(current), reflect.ValueOf(desired)
+
+	diffs := []fieldDiff{}
+	for _, name := range managedStreamFields {
+		cf, df := cv.FieldByName(name), dv.FieldByName(name)
+		if fieldsEqual(cf, df) {
+			continue
+		}
+		diffs = append(diffs, fieldDiff{Field: name, Current: cf.Interface(), Desired: df.Interface()})
+	}
+	return diffs
+}
@@ -0,0 +1,159 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestServeStreamDiff(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	err := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "orders"},
+		Spec: apis.StreamSpec{
+			Name:     "orders",
+			Subjects: []string{"orders.>"},
+			Storage:  "memory",
+			MaxMsgs:  1000,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := &mockStream{config: jsmapi.StreamConfig{
+		Name:     "orders",
+		Subjects: []string{"orders.>"},
+		Storage:  jsmapi.MemoryStorage,
+		MaxMsgs:  100,
+	}}
+	jsmc := &mockJsmClient{loadStream: ms}
+
+	rr := httptest.NewRecorder()
+	ctrl.serveStreamDiff(rr, httptest.NewRequest(http.MethodGet, "/diff/stream/default/orders", nil), jsmc)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("got=%d; want=%d: %s", got, want, rr.Body)
+	}
+
+	var diff struct {
+		Stream  string `json:"stream"`
+		Exists  bool   `json:"exists"`
+		Changes []struct {
+			Field   string  `json:"field"`
+			Current float64 `json:"current"`
+			Desired float64 `json:"desired"`
+		} `json:"changes"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Exists || diff.Stream != "orders" {
+		t.Fatalf("got=%+v; want existing stream orders", diff)
+	}
+	if len(diff.Changes) != 1 {
+		t.Fatalf("got=%+v; want one change", diff.Changes)
+	}
+	if got := diff.Changes[0]; got.Field != "MaxMsgs" || got.Current != 100 || got.Desired != 1000 {
+		t.Fatalf("got=%+v; want MaxMsgs from 100 to 1000", got)
+	}
+	if ms.updatedConfig != nil {
+		t.Fatalf("got=%+v; want stream left unchanged", ms.updatedConfig)
+	}
+
+	t.Run("missing stream", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		ctrl.serveStreamDiff(rr, httptest.NewRequest(http.MethodGet, "/diff/stream/default/orders", nil),
+			&mockJsmClient{loadStreamErr: jsmapi.ApiError{Code: 404}})
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("got=%d; want=%d", got, want)
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&diff); err != nil {
+			t.Fatal(err)
+		}
+		if diff.Exists {
+			t.Fatalf("got=%+v; want missing stream", diff)
+		}
+	})
+
+	t.Run("unknown resource", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		ctrl.serveStreamDiff(rr, httptest.NewRequest(http.MethodGet, "/diff/stream/default/other", nil), jsmc)
+		if got, want := rr.Code, http.StatusNotFound; got != want {
+			t.Fatalf("got=%d; want=%d", got, want)
+		}
+	})
+}
+
+func TestServeStreamDiffResolvedSpec(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+		SpecMutator:    replicasMutator{},
+	})
+
+	err := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "orders"},
+		Spec: apis.StreamSpec{
+			Name:     "orders",
+			Subjects: []string{"orders.>", "orders.>"},
+			Storage:  "memory",
+			Replicas: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The duplicate subject is dropped and the mutator applied, as a
+	// reconcile would, leaving only the replicas to change.
+	jsmc := &mockJsmClient{loadStream: &mockStream{config: jsmapi.StreamConfig{
+		Name:     "orders",
+		Subjects: []string{"orders.>"},
+		Storage:  jsmapi.MemoryStorage,
+		Replicas: 1,
+	}}}
+	rr := httptest.NewRecorder()
+	ctrl.serveStreamDiff(rr, httptest.NewRequest(http.MethodGet, "/diff/stream/default/orders", nil), jsmc)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("got=%d; want=%d: %s", got, want, rr.Body)
+	}
+
+	var diff struct {
+		Changes []struct {
+			Field   string  `json:"field"`
+			Current float64 `json:"current"`
+			Desired float64 `json:"desired"`
+		} `json:"changes"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changes) != 1 {
+		t.Fatalf("got=%+v; want one change", diff.Changes)
+	}
+	if got := diff.Changes[0]; got.Field != "Replicas" || got.Current != 1 || got.Desired != 3 {
+		t.Fatalf("got=%+v; want Replicas from 1 to 3", got)
+	}
+}
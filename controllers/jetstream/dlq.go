@@ -0,0 +1,205 @@
+package jetstream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	jsadvisory "github.com/nats-io/jsm.go/api/jetstream/advisory"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	"github.com/nats-io/nats.go"
+	klog "k8s.io/klog/v2"
+)
+
+// Headers set on the messages republished to a dead letter subject.
+const (
+	deadLetterStreamHeader     = "Nats-Dead-Letter-Stream"
+	deadLetterConsumerHeader   = "Nats-Dead-Letter-Consumer"
+	deadLetterSubjectHeader    = "Nats-Dead-Letter-Subject"
+	deadLetterSequenceHeader   = "Nats-Dead-Letter-Sequence"
+	deadLetterDeliveriesHeader = "Nats-Dead-Letter-Deliveries"
+)
+
+// deadLetterConn subscribes to max deliveries advisories and publishes the
+// messages exhausting them, as done by nats.Conn.
+type deadLetterConn interface {
+	Subscribe(subject string, cb nats.MsgHandler) (*nats.Subscription, error)
+	PublishMsg(m *nats.Msg) error
+}
+
+// deadLetters republishes the messages consumers gave up on to their dead
+// letter subject. NATS servers only publish an advisory when a message
+// reaches the max deliveries of a consumer, the message itself stays in the
+// stream, so the controller reads it back by sequence and republishes it.
+type deadLetters struct {
+	// conn subscribes and publishes, nil unless the controller has its own
+	// NATS connection.
+	conn deadLetterConn
+
+	// ctx and jsmc read the messages back from their stream.
+	ctx  context.Context
+	jsmc func() jsmClient
+
+	mu   sync.Mutex
+	subs map[string]deadLetterSub
+}
+
+type deadLetterSub struct {
+	sub  *nats.Subscription
+	dest string
+}
+
+func newDeadLetters(ctx context.Context, jsmc func() jsmClient) *deadLetters {
+	return &deadLetters{
+		ctx:  ctx,
+		jsmc: jsmc,
+		subs: make(map[string]deadLetterSub),
+	}
+}
+
+// maxDeliveriesSubject returns the subject the server publishes the max
+// deliveries advisories of a consumer on.
+func maxDeliveriesSubject(stream, consumer string) string {
+	return fmt.Sprintf("%s.%s.%s", jsmapi.JSAdvisoryConsumerMaxDeliveryExceedPre, stream, consumer)
+}
+
+// watch subscribes to the max deliveries advisories of the consumer at key,
+// republishing the messages they're about to dest, unless it already is.
+func (d *deadLetters) watch(key, stream, consumer, dest string) error {
+	subject := maxDeliveriesSubject(stream, consumer)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if old, ok := d.subs[key]; ok {
+		if old.sub.Subject == subject && old.dest == dest {
+			return nil
+		}
+		if err := old.sub.Unsubscribe(); err != nil {
+			klog.V(4).Infof("failed to unsubscribe from max deliveries of consumer %s: %s", key, err)
+		}
+		delete(d.subs, key)
+	}
+
+	sub, err := d.conn.Subscribe(subject, func(m *nats.Msg) {
+		if err := d.republish(m.Data, dest); err != nil {
+			klog.Infof("failed to republish dead letter of consumer %s to %q: %s", key, dest, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	d.subs[key] = deadLetterSub{sub: sub, dest: dest}
+	return nil
+}
+
+// forget unsubscribes from the max deliveries advisories of the consumer at
+// key.
+func (d *deadLetters) forget(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	old, ok := d.subs[key]
+	if !ok {
+		return
+	}
+	if err := old.sub.Unsubscribe(); err != nil {
+		klog.V(4).Infof("failed to unsubscribe from max deliveries of consumer %s: %s", key, err)
+	}
+	delete(d.subs, key)
+}
+
+// republish publishes the message a max deliveries advisory is about to
+// dest, with its original headers and ones telling where it came from.
+func (d *deadLetters) republish(data []byte, dest string) error {
+	var adv jsadvisory.ConsumerDeliveryExceededAdvisoryV1
+	if err := json.Unmarshal(data, &adv); err != nil {
+		return fmt.Errorf("invalid advisory: %w", err)
+	}
+
+	js, err := d.jsmc().LoadStream(d.ctx, adv.Stream)
+	if err != nil {
+		return err
+	}
+	sm, err := js.ReadMessage(adv.StreamSeq)
+	if err != nil {
+		return fmt.Errorf("failed to read message %d: %w", adv.StreamSeq, err)
+	}
+
+	msg := nats.NewMsg(dest)
+	msg.Data = sm.Data
+	if len(sm.Header) > 0 {
+		hdr, err := decodeStoredHeader(sm.Header)
+		if err != nil {
+			return fmt.Errorf("invalid headers of message %d: %w", adv.StreamSeq, err)
+		}
+		msg.Header = hdr
+	}
+	msg.Header.Set(deadLetterStreamHeader, adv.Stream)
+	msg.Header.Set(deadLetterConsumerHeader, adv.Consumer)
+	msg.Header.Set(deadLetterSubjectHeader, sm.Subject)
+	msg.Header.Set(deadLetterSequenceHeader, strconv.FormatUint(adv.StreamSeq, 10))
+	msg.Header.Set(deadLetterDeliveriesHeader, strconv.FormatUint(adv.Deliveries, 10))
+	return d.conn.PublishMsg(msg)
+}
+
+// decodeStoredHeader decodes the headers of a stored message, in the
+// NATS/1.0 wire format.
+func decodeStoredHeader(b []byte) (nats.Header, error) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	if _, err := tp.ReadLine(); err != nil {
+		return nil, err
+	}
+	h, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	return nats.Header(h), nil
+}
+
+// validateDeadLetterSubject returns an error when the dead letter subject of
+// spec can't be published to.
+func validateDeadLetterSubject(spec apis.ConsumerSpec) error {
+	subj := spec.DeadLetterSubject
+	if subj == "" {
+		return nil
+	}
+	err := validateSubject(subj)
+	if err == nil && strings.ContainsAny(subj, "*>") {
+		err = errors.New("wildcards can't be published to")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid dead letter subject %q: %w", subj, err)
+	}
+	return nil
+}
+
+// deadLettersEnabled returns whether the messages cns gives up on should be
+// republished: it needs both maxDeliver and a dead letter subject.
+func deadLettersEnabled(cns *apis.Consumer) bool {
+	return cns.Spec.DeadLetterSubject != "" && cns.Spec.MaxDeliver > 0 && cns.DeletionTimestamp == nil
+}
+
+// syncDeadLetters subscribes to or unsubscribes from the max deliveries
+// advisories of cns after it's reconciled. It's best-effort, failures are
+// only logged.
+func (c *Controller) syncDeadLetters(cns *apis.Consumer) {
+	if c.dlq.conn == nil {
+		return
+	}
+	key := objectKey(cns.Namespace, cns.Name)
+	durable, err := resolveDurableName(cns)
+	if err != nil || !deadLettersEnabled(cns) {
+		c.dlq.forget(key)
+		return
+	}
+	if err := c.dlq.watch(key, cns.Spec.StreamName, durable, cns.Spec.DeadLetterSubject); err != nil {
+		klog.Infof("failed to subscribe to max deliveries of consumer %s: %s", key, err)
+	}
+}
@@ -0,0 +1,194 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	jsadvisory "github.com/nats-io/jsm.go/api/jetstream/advisory"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nats.go"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+type mockDeadLetterConn struct {
+	handlers  map[string]nats.MsgHandler
+	published []*nats.Msg
+}
+
+func (m *mockDeadLetterConn) Subscribe(subject string, cb nats.MsgHandler) (*nats.Subscription, error) {
+	m.handlers[subject] = cb
+	return &nats.Subscription{Subject: subject}, nil
+}
+
+func (m *mockDeadLetterConn) PublishMsg(msg *nats.Msg) error {
+	m.published = append(m.published, msg)
+	return nil
+}
+
+func TestDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+	conn := &mockDeadLetterConn{handlers: make(map[string]nats.MsgHandler)}
+	ctrl.dlq.conn = conn
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{
+			msgs: map[uint64]*jsmapi.StoredMsg{
+				7: {
+					Subject:  "orders.created",
+					Sequence: 7,
+					Header:   []byte("NATS/1.0\r\nOrder-Id: 42\r\n\r\n"),
+					Data:     []byte("order 42"),
+				},
+			},
+		},
+	}
+	ctrl.dlq.jsmc = func() jsmClient { return jsmc }
+
+	cns := &apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "my-consumer"},
+		Spec: apis.ConsumerSpec{
+			StreamName:        "orders",
+			DurableName:       "processor",
+			MaxDeliver:        3,
+			DeadLetterSubject: "dlq.orders",
+		},
+	}
+	ctrl.syncDeadLetters(cns)
+
+	handler := conn.handlers["$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES.orders.processor"]
+	if handler == nil {
+		t.Fatalf("got subscriptions %v; want the max deliveries advisories of the consumer", conn.handlers)
+	}
+	data, err := json.Marshal(jsadvisory.ConsumerDeliveryExceededAdvisoryV1{
+		Stream:     "orders",
+		Consumer:   "processor",
+		StreamSeq:  7,
+		Deliveries: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler(&nats.Msg{Data: data})
+
+	if len(conn.published) != 1 {
+		t.Fatalf("got=%d; want 1 dead letter published", len(conn.published))
+	}
+	got := conn.published[0]
+	if got.Subject != "dlq.orders" || string(got.Data) != "order 42" {
+		t.Fatalf("got subject=%q data=%q; want the message on dlq.orders", got.Subject, got.Data)
+	}
+	for k, want := range map[string]string{
+		"Order-Id":                 "42",
+		deadLetterStreamHeader:     "orders",
+		deadLetterConsumerHeader:   "processor",
+		deadLetterSubjectHeader:    "orders.created",
+		deadLetterSequenceHeader:   "7",
+		deadLetterDeliveriesHeader: "3",
+	} {
+		if got := got.Header.Get(k); got != want {
+			t.Errorf("header %s: got=%q; want=%q", k, got, want)
+		}
+	}
+
+	// A message no longer in the stream is skipped.
+	data, err = json.Marshal(jsadvisory.ConsumerDeliveryExceededAdvisoryV1{Stream: "orders", Consumer: "processor", StreamSeq: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler(&nats.Msg{Data: data})
+	if len(conn.published) != 1 {
+		t.Fatalf("got=%d; want no dead letter for a missing message", len(conn.published))
+	}
+
+	// Without maxDeliver messages are never exhausted.
+	cns.Spec.MaxDeliver = 0
+	ctrl.syncDeadLetters(cns)
+	if _, ok := ctrl.dlq.subs["default/my-consumer"]; ok {
+		t.Fatal("got a max deliveries subscription; want it dropped")
+	}
+}
+
+func TestDeadLettersOnChangeOnly(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        clientsetfake.NewSimpleClientset(),
+		Recorder:              record.NewFakeRecorder(10),
+		ReconcileOnChangeOnly: true,
+	})
+	conn := &mockDeadLetterConn{handlers: make(map[string]nats.MsgHandler)}
+	ctrl.dlq.conn = conn
+
+	ns, name := "default", "my-consumer"
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			StreamName:        "orders",
+			DurableName:       "processor",
+			MaxDeliver:        3,
+			DeadLetterSubject: "dlq.orders",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+				Conditions: []apis.Condition{{
+					Type:   readyCondType,
+					Status: k8sapi.ConditionTrue,
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the Ready consumer is skipped, but its exhausted
+	// messages are still republished.
+	jsmc := &mockJsmClient{}
+	if err := ctrl.processConsumer(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if jsmc.newConsumers != 0 {
+		t.Fatalf("got %d consumers created; want the consumer skipped", jsmc.newConsumers)
+	}
+	if conn.handlers["$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES.orders.processor"] == nil {
+		t.Fatalf("got subscriptions %v; want the max deliveries advisories of the skipped consumer", conn.handlers)
+	}
+}
+
+func TestValidateDeadLetterSubject(t *testing.T) {
+	t.Parallel()
+
+	for subj, valid := range map[string]bool{
+		"":            true,
+		"dlq.orders":  true,
+		"dlq.*":       false,
+		"dlq.>":       false,
+		"dlq..orders": false,
+	} {
+		err := validateDeadLetterSubject(apis.ConsumerSpec{DeadLetterSubject: subj})
+		if got := err == nil; got != valid {
+			t.Errorf("%q: got err=%v; want valid=%t", subj, err, valid)
+		}
+	}
+}
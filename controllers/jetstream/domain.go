@@ -0,0 +1,59 @@
+package jetstream
+
+import (
+	"fmt"
+
+	"github.com/nats-io/jsm.go"
+	jsmapi "github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats.go"
+	klog "k8s.io/klog/v2"
+)
+
+// accountInfoer returns the JetStream account info, as done by jsm.Manager.
+type accountInfoer interface {
+	JetStreamAccountInfo() (*jsmapi.JetStreamAccountStats, error)
+}
+
+// discoverDomain returns the JetStream domain of the server the account info
+// is requested from, empty when it has none.
+func discoverDomain(a accountInfoer) (string, error) {
+	info, err := a.JetStreamAccountInfo()
+	if err != nil {
+		return "", fmt.Errorf("failed to discover JetStream domain: %w", err)
+	}
+	return info.Domain, nil
+}
+
+// newJsmManager returns a JetStream manager for nc that sends its API calls
+// to the JSDomain or, if unset and AutoDiscoverDomain is on, to the domain of
+// the connected server. It also returns the domain used.
+func (c *Controller) newJsmManager(nc *nats.Conn) (*jsm.Manager, string, error) {
+	domain := c.opts.JSDomain
+	if domain == "" && c.opts.AutoDiscoverDomain {
+		jm, err := jsm.New(nc)
+		if err != nil {
+			return nil, "", err
+		}
+		if domain, err = discoverDomain(jm); err != nil {
+			return nil, "", err
+		}
+		if domain == "" {
+			return jm, "", nil
+		}
+		klog.V(2).Infof("discovered JetStream domain %q on %s", domain, nc.ConnectedUrlRedacted())
+	}
+	if domain == "" {
+		jm, err := jsm.New(nc)
+		return jm, "", err
+	}
+
+	jm, err := jsm.New(nc, jsm.WithDomain(domain))
+	return jm, domain, err
+}
+
+// jsmClient returns a client using the controller's NATS connection.
+func (c *Controller) jsmClient() *realJsmClient {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return &realJsmClient{nc: c.nc, jm: c.jm, domain: c.domain}
+}
@@ -0,0 +1,146 @@
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nats.go"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+type mockAccountInfoer struct {
+	info *jsmapi.JetStreamAccountStats
+	err  error
+}
+
+func (m *mockAccountInfoer) JetStreamAccountInfo() (*jsmapi.JetStreamAccountStats, error) {
+	return m.info, m.err
+}
+
+func TestDiscoverDomain(t *testing.T) {
+	t.Parallel()
+
+	t.Run("leaf node with a domain", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := discoverDomain(&mockAccountInfoer{info: &jsmapi.JetStreamAccountStats{Domain: "leaf"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "leaf"; got != want {
+			t.Fatalf("got=%q; want=%q", got, want)
+		}
+	})
+
+	t.Run("no domain", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := discoverDomain(&mockAccountInfoer{info: &jsmapi.JetStreamAccountStats{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "" {
+			t.Fatalf("got=%q; want no domain", got)
+		}
+	})
+
+	t.Run("account info failure", func(t *testing.T) {
+		t.Parallel()
+
+		wantErr := errors.New("no responders")
+		if _, err := discoverDomain(&mockAccountInfoer{err: wantErr}); !errors.Is(err, wantErr) {
+			t.Fatalf("got=%v; want=%v", err, wantErr)
+		}
+	})
+}
+
+func TestProcessStreamRecordsDomain(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:                context.Background(),
+		KubeIface:          k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:     jc,
+		Recorder:           record.NewFakeRecorder(10),
+		AutoDiscoverDomain: true,
+	})
+
+	ns, name := "default", "orders"
+	err := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			Storage: "memory",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		got = obj.Status.Domain
+		return true, obj, nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		domain:        "leaf",
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if want := "leaf"; got != want {
+		t.Fatalf("got=%q; want=%q", got, want)
+	}
+}
+
+func TestJsmClientServerVersion(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	nc, err := nats.Connect(serveFakeNATS(t, "2.8.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	jm, err := jsm.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl.nc, ctrl.jm = nc, jm
+
+	// Without CRDConnect, resources are reconciled with the controller's own
+	// connection, whose server version gates newer features.
+	jsmc := ctrl.jsmClient()
+	if got, want := jsmc.ServerVersion(), "2.8.4"; got != want {
+		t.Fatalf("got=%q; want=%q", got, want)
+	}
+	err = createStream(context.Background(), jsmc, apis.StreamSpec{Name: "orders", AllowDirect: true})
+	var ferr *unsupportedFeatureError
+	if !errors.As(err, &ferr) || ferr.field != "allowDirect" {
+		t.Fatalf("got=%v; want allowDirect rejected by the 2.8.4 server", err)
+	}
+}
@@ -0,0 +1,92 @@
+package jetstream
+
+import (
+	"errors"
+	"strings"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats.go"
+)
+
+// errorKind classifies errors returned while talking to NATS, so that
+// reconciles can report well known failures precisely instead of as a
+// generic error.
+type errorKind int
+
+const (
+	errKindUnknown errorKind = iota
+
+	// errKindPermissionDenied is a publish or subscribe permissions
+	// violation for the connected user.
+	errKindPermissionDenied
+
+	// errKindJetStreamNotEnabled means JetStream isn't enabled on the server
+	// or for the connected account.
+	errKindJetStreamNotEnabled
+
+	// errKindWorkQueueConflict means a consumer's filter overlaps another
+	// consumer's on a workqueue retention stream.
+	errKindWorkQueueConflict
+
+	// errKindConnectionLost means the NATS connection was closed or is
+	// reconnecting, typically because it dropped mid-reconcile.
+	errKindConnectionLost
+
+	// errKindNoLeader means JetStream couldn't answer because its meta,
+	// stream or consumer leader is being elected, which resolves by itself.
+	errKindNoLeader
+)
+
+// JetStream API error codes, see the server's errors.json.
+const (
+	jsErrCodeClusterNotAvail      = 10008
+	jsErrCodeClusterNotLeader     = 10009
+	jsErrCodeNotEnabledForAccount = 10039
+	jsErrCodeNotEnabled           = 10076
+	jsErrCodeWQMultipleUnfiltered = 10099
+	jsErrCodeWQConsumerNotUnique  = 10100
+)
+
+func classifyError(err error) errorKind {
+	if err == nil {
+		return errKindUnknown
+	}
+
+	var apierr jsmapi.ApiError
+	if errors.As(err, &apierr) {
+		switch apierr.ErrCode {
+		case jsErrCodeNotEnabledForAccount, jsErrCodeNotEnabled:
+			return errKindJetStreamNotEnabled
+		case jsErrCodeWQMultipleUnfiltered, jsErrCodeWQConsumerNotUnique:
+			return errKindWorkQueueConflict
+		case jsErrCodeClusterNotAvail, jsErrCodeClusterNotLeader:
+			return errKindNoLeader
+		}
+	}
+	if errors.Is(err, nats.ErrJetStreamNotEnabled) {
+		return errKindJetStreamNotEnabled
+	}
+	if errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrConnectionDraining) ||
+		errors.Is(err, nats.ErrConnectionReconnecting) || errors.Is(err, nats.ErrDisconnected) {
+		return errKindConnectionLost
+	}
+	if errors.Is(err, nats.ErrNoResponders) {
+		return errKindNoLeader
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "permissions violation"),
+		strings.Contains(msg, "permission denied"):
+		return errKindPermissionDenied
+	case strings.Contains(msg, "jetstream not enabled"):
+		return errKindJetStreamNotEnabled
+	case strings.Contains(msg, "connection closed"):
+		return errKindConnectionLost
+	case strings.Contains(msg, "no responders"),
+		strings.Contains(msg, "no leader"):
+		return errKindNoLeader
+	default:
+		return errKindUnknown
+	}
+}
@@ -0,0 +1,39 @@
+package jetstream
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats.go"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want errorKind
+	}{
+		{"nil", nil, errKindUnknown},
+		{"generic", errors.New("boom"), errKindUnknown},
+		{"permissions violation", errors.New(`nats: Permissions Violation for Subscription to "foo"`), errKindPermissionDenied},
+		{"not enabled for account", fmt.Errorf("failed: %w", jsmapi.ApiError{Code: 503, ErrCode: 10039, Description: "jetstream not enabled for account"}), errKindJetStreamNotEnabled},
+		{"not enabled", jsmapi.ApiError{Code: 503, ErrCode: 10076, Description: "jetstream not enabled"}, errKindJetStreamNotEnabled},
+		{"nats.go not enabled", nats.ErrJetStreamNotEnabled, errKindJetStreamNotEnabled},
+		{"workqueue not unique", jsmapi.ApiError{Code: 400, ErrCode: 10100, Description: "filtered consumer not unique on workqueue stream"}, errKindWorkQueueConflict},
+		{"workqueue unfiltered", jsmapi.ApiError{Code: 400, ErrCode: 10099, Description: "multiple non-filtered consumers not allowed on workqueue stream"}, errKindWorkQueueConflict},
+		{"connection closed", fmt.Errorf("failed to update stream: %w", nats.ErrConnectionClosed), errKindConnectionLost},
+		{"reconnecting", nats.ErrConnectionReconnecting, errKindConnectionLost},
+		{"no responders", fmt.Errorf("failed to load stream: %w", nats.ErrNoResponders), errKindNoLeader},
+		{"cluster not available", jsmapi.ApiError{Code: 503, ErrCode: 10008, Description: "JetStream system temporarily unavailable"}, errKindNoLeader},
+		{"not leader", jsmapi.ApiError{Code: 500, ErrCode: 10009, Description: "JetStream cluster can not handle request"}, errKindNoLeader},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("%s: got=%d; want=%d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package jetstream
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// Export writes every Stream and Consumer the controller manages to w as a
+// multi-document YAML manifest. Status is kept for reference, while server
+// assigned metadata is dropped so the manifest can be applied to another
+// cluster. It syncs the informers itself, so Run doesn't have to be called.
+func (c *Controller) Export(w io.Writer) error {
+	c.informerFactory.Start(c.ctx.Done())
+
+	if !cache.WaitForCacheSync(c.ctx.Done(), c.strSynced) {
+		return fmt.Errorf("failed to wait for stream cache sync")
+	}
+	if !cache.WaitForCacheSync(c.ctx.Done(), c.cnsSynced) {
+		return fmt.Errorf("failed to wait for consumer cache sync")
+	}
+
+	return c.writeManifest(w)
+}
+
+func (c *Controller) writeManifest(w io.Writer) error {
+	streams, err := c.strLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		return objectKey(streams[i].Namespace, streams[i].Name) < objectKey(streams[j].Namespace, streams[j].Name)
+	})
+
+	consumers, err := c.cnsLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list consumers: %w", err)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return objectKey(consumers[i].Namespace, consumers[i].Name) < objectKey(consumers[j].Namespace, consumers[j].Name)
+	})
+
+	for _, s := range streams {
+		s = s.DeepCopy()
+		s.TypeMeta.APIVersion = apis.SchemeGroupVersion.String()
+		s.TypeMeta.Kind = "Stream"
+		s.ObjectMeta.ResourceVersion = ""
+		s.ObjectMeta.UID = ""
+		s.ObjectMeta.ManagedFields = nil
+
+		if err := writeManifestDocument(w, s); err != nil {
+			return err
+		}
+	}
+	for _, cns := range consumers {
+		cns = cns.DeepCopy()
+		cns.TypeMeta.APIVersion = apis.SchemeGroupVersion.String()
+		cns.TypeMeta.Kind = "Consumer"
+		cns.ObjectMeta.ResourceVersion = ""
+		cns.ObjectMeta.UID = ""
+		cns.ObjectMeta.ManagedFields = nil
+
+		if err := writeManifestDocument(w, cns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeManifestDocument(w io.Writer, o interface{}) error {
+	b, err := yaml.Marshal(o)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", b)
+	return err
+}
+
+func objectKey(ns, name string) string {
+	return fmt.Sprintf("%s/%s", ns, name)
+}
@@ -0,0 +1,95 @@
+package jetstream
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWriteManifest(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+	})
+
+	streams := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for _, name := range []string{"orders", "events"} {
+		err := streams.Add(&apis.Stream{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:       "default",
+				Name:            name,
+				UID:             "1234",
+				ResourceVersion: "42",
+			},
+			Spec: apis.StreamSpec{
+				Name:     name,
+				Subjects: []string{name + ".*"},
+			},
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	consumers := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore()
+	err := consumers.Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "orders-worker",
+		},
+		Spec: apis.ConsumerSpec{
+			StreamName:  "orders",
+			DurableName: "worker",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ctrl.writeManifest(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	docs := strings.Split(strings.TrimPrefix(buf.String(), "---\n"), "---\n")
+	if got, want := len(docs), 3; got != want {
+		t.Fatalf("got=%d documents; want=%d", got, want)
+	}
+
+	var first apis.Stream
+	if err := yaml.Unmarshal([]byte(docs[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Kind != "Stream" || first.APIVersion != "jetstream.nats.io/v1beta2" {
+		t.Fatalf("unexpected type: %s %s", first.APIVersion, first.Kind)
+	}
+	if first.Name != "events" {
+		t.Fatalf("got=%s; want=%s", first.Name, "events")
+	}
+	if first.UID != "" || first.ResourceVersion != "" {
+		t.Fatalf("unexpected server assigned metadata: %q %q", first.UID, first.ResourceVersion)
+	}
+	if first.Status.ObservedGeneration != 1 {
+		t.Fatalf("unexpected status: %+v", first.Status)
+	}
+
+	var last apis.Consumer
+	if err := yaml.Unmarshal([]byte(docs[2]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Kind != "Consumer" || last.Spec.DurableName != "worker" {
+		t.Fatalf("unexpected consumer: %s %+v", last.Kind, last.Spec)
+	}
+}
@@ -0,0 +1,103 @@
+package jetstream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+)
+
+// streamFeatures are the stream spec fields that need a minimum NATS server
+// version. Older servers either reject or silently ignore them.
+var streamFeatures = []struct {
+	field      string
+	minVersion string
+	used       func(spec apis.StreamSpec) bool
+}{
+	{"allowDirect", "2.9.0", func(s apis.StreamSpec) bool { return s.AllowDirect }},
+	{"republish", "2.9.0", func(s apis.StreamSpec) bool { return s.Republish != nil }},
+}
+
+// consumerFeatures are the consumer spec fields that need a minimum NATS
+// server version.
+var consumerFeatures = []struct {
+	field      string
+	minVersion string
+	used       func(spec apis.ConsumerSpec) bool
+}{
+	{"maxRequestBatch", "2.7.0", func(s apis.ConsumerSpec) bool { return s.MaxRequestBatch != 0 }},
+	{"maxRequestExpires", "2.7.0", func(s apis.ConsumerSpec) bool { return s.MaxRequestExpires != "" }},
+	{"backoff", "2.7.1", func(s apis.ConsumerSpec) bool { return len(s.BackOff) > 0 }},
+	{"maxRequestMaxBytes", "2.8.3", func(s apis.ConsumerSpec) bool { return s.MaxRequestMaxBytes != 0 }},
+}
+
+// unsupportedFeatureError is returned when a spec sets a field the connected
+// server is too old to support.
+type unsupportedFeatureError struct {
+	field         string
+	minVersion    string
+	serverVersion string
+}
+
+func (e *unsupportedFeatureError) Error() string {
+	return fmt.Sprintf("feature %q not supported by server version %s, requires %s or later",
+		e.field, e.serverVersion, e.minVersion)
+}
+
+func checkStreamFeatures(serverVersion string, spec apis.StreamSpec) error {
+	for _, f := range streamFeatures {
+		if f.used(spec) && !versionAtLeast(serverVersion, f.minVersion) {
+			return &unsupportedFeatureError{field: f.field, minVersion: f.minVersion, serverVersion: serverVersion}
+		}
+	}
+	return nil
+}
+
+func checkConsumerFeatures(serverVersion string, spec apis.ConsumerSpec) error {
+	for _, f := range consumerFeatures {
+		if f.used(spec) && !versionAtLeast(serverVersion, f.minVersion) {
+			return &unsupportedFeatureError{field: f.field, minVersion: f.minVersion, serverVersion: serverVersion}
+		}
+	}
+	return nil
+}
+
+// versionAtLeast reports whether version is at least min. An unknown or
+// unparsable version is assumed to be new enough, leaving the server to
+// reject anything it doesn't support.
+func versionAtLeast(version, min string) bool {
+	have, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+	want, _ := parseVersion(min)
+
+	for i := range have {
+		if have[i] != want[i] {
+			return have[i] > want[i]
+		}
+	}
+	return true
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parsed [3]int
+
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
@@ -0,0 +1,45 @@
+package jetstream
+
+import (
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version string
+		min     string
+		want    bool
+	}{
+		{"2.9.0", "2.9.0", true},
+		{"2.10.1", "2.9.0", true},
+		{"2.8.4", "2.9.0", false},
+		{"2.9.0-beta.2", "2.9.0", true},
+		{"v2.7.0", "2.7.1", false},
+		{"", "2.9.0", true},
+		{"unknown", "2.9.0", true},
+	}
+	for _, tt := range tests {
+		if got := versionAtLeast(tt.version, tt.min); got != tt.want {
+			t.Errorf("versionAtLeast(%q, %q): got=%t; want=%t", tt.version, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestCheckConsumerFeatures(t *testing.T) {
+	t.Parallel()
+
+	spec := apis.ConsumerSpec{BackOff: []string{"1s", "5s"}}
+	if err := checkConsumerFeatures("2.7.1", spec); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := checkConsumerFeatures("2.7.0", spec)
+	want := `feature "backoff" not supported by server version 2.7.0, requires 2.7.1 or later`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got=%v; want=%s", err, want)
+	}
+}
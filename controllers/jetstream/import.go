@@ -0,0 +1,340 @@
+package jetstream
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	klog "k8s.io/klog/v2"
+)
+
+// natsInventory lists the configs of the streams and consumers in NATS.
+type natsInventory interface {
+	StreamConfigs() ([]jsmapi.StreamConfig, error)
+	ConsumerConfigs(stream string) ([]jsmapi.ConsumerConfig, error)
+}
+
+type jsmInventory struct {
+	jm *jsm.Manager
+}
+
+func (i jsmInventory) StreamConfigs() ([]jsmapi.StreamConfig, error) {
+	streams, err := i.jm.Streams(nil)
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]jsmapi.StreamConfig, 0, len(streams))
+	for _, s := range streams {
+		configs = append(configs, s.Configuration())
+	}
+	return configs, nil
+}
+
+func (i jsmInventory) ConsumerConfigs(stream string) ([]jsmapi.ConsumerConfig, error) {
+	consumers, err := i.jm.Consumers(stream)
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]jsmapi.ConsumerConfig, 0, len(consumers))
+	for _, cn := range consumers {
+		configs = append(configs, cn.Configuration())
+	}
+	return configs, nil
+}
+
+// Import connects to NATS and writes a Stream and Consumer to w, as a
+// multi-document YAML manifest in namespace, for every stream and durable
+// consumer found there. Each resource has the adopt annotation, marking it as
+// taking over an existing stream or consumer.
+func (c *Controller) Import(w io.Writer, namespace string) error {
+	if c.opts.CRDConnect {
+		return fmt.Errorf("importing needs the controller's NATS connection")
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	defer c.nc.Close()
+
+	return writeImportManifest(w, jsmInventory{jm: c.jm}, namespace)
+}
+
+// importedStream is a stream found in NATS along with its durable consumers.
+type importedStream struct {
+	config    jsmapi.StreamConfig
+	consumers []jsmapi.ConsumerConfig
+}
+
+func writeImportManifest(w io.Writer, inv natsInventory, namespace string) error {
+	configs, err := inv.StreamConfigs()
+	if err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+
+	// The consumers are all listed first, so that resource names they'd
+	// share can be told apart.
+	streams := make([]importedStream, 0, len(configs))
+	names := make(map[string]int)
+	for _, cfg := range configs {
+		all, err := inv.ConsumerConfigs(cfg.Name)
+		if err != nil {
+			return fmt.Errorf("failed to list consumers of stream %q: %w", cfg.Name, err)
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Durable < all[j].Durable })
+
+		consumers := make([]jsmapi.ConsumerConfig, 0, len(all))
+		for _, ccfg := range all {
+			if ccfg.Durable == "" {
+				klog.V(2).Infof("skipping ephemeral consumer %q of stream %q", ccfg.Name, cfg.Name)
+				continue
+			}
+			consumers = append(consumers, ccfg)
+			names[joinedResourceName(cfg.Name, ccfg.Durable)]++
+		}
+		streams = append(streams, importedStream{config: cfg, consumers: consumers})
+	}
+
+	for _, s := range streams {
+		cfg := s.config
+		str := &apis.Stream{
+			TypeMeta: k8smeta.TypeMeta{
+				APIVersion: apis.SchemeGroupVersion.String(),
+				Kind:       "Stream",
+			},
+			ObjectMeta: importedObjectMeta(namespace, resourceName(cfg.Name)),
+			Spec:       streamConfigToSpec(cfg),
+		}
+		if err := writeManifestDocument(w, str); err != nil {
+			return err
+		}
+
+		for _, ccfg := range s.consumers {
+			// Stream a-b with consumer c and stream a with consumer b-c
+			// would both be named a-b-c.
+			name := joinedResourceName(cfg.Name, ccfg.Durable)
+			if names[name] > 1 {
+				name = hashedResourceName(cfg.Name+"-"+ccfg.Durable, cfg.Name+"/"+ccfg.Durable)
+			}
+			cns := &apis.Consumer{
+				TypeMeta: k8smeta.TypeMeta{
+					APIVersion: apis.SchemeGroupVersion.String(),
+					Kind:       "Consumer",
+				},
+				ObjectMeta: importedObjectMeta(namespace, name),
+				Spec:       consumerConfigToSpec(cfg.Name, ccfg),
+			}
+			if err := writeManifestDocument(w, cns); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func importedObjectMeta(namespace, name string) k8smeta.ObjectMeta {
+	return k8smeta.ObjectMeta{
+		Namespace:   namespace,
+		Name:        name,
+		Annotations: map[string]string{adoptAnnotation: "true"},
+	}
+}
+
+// resourceName turns a NATS name into a valid Kubernetes resource name. Names
+// that had to be changed get a hash of the original appended, so that names
+// only differing in case or invalid characters don't collide.
+func resourceName(name string) string {
+	if len(validation.IsDNS1123Subdomain(name)) == 0 {
+		return name
+	}
+	return hashedResourceName(name, name)
+}
+
+// joinedResourceName is the resource name of something named after both
+// prefix and name, like a consumer after its stream.
+func joinedResourceName(prefix, name string) string {
+	return resourceName(prefix + "-" + name)
+}
+
+// hashedResourceName turns name into a valid Kubernetes resource name with a
+// hash of key appended, key telling apart the names that would otherwise be
+// the same.
+func hashedResourceName(name, key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+
+	sanitized := strings.Trim(b.String(), "-.")
+	if max := validation.DNS1123SubdomainMaxLength - len(suffix); len(sanitized) > max {
+		sanitized = strings.TrimRight(sanitized[:max], "-.")
+	}
+	if sanitized == "" {
+		return strings.TrimPrefix(suffix, "-")
+	}
+	return sanitized + suffix
+}
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// streamConfigToSpec is the inverse of streamSpecToConfig.
+func streamConfigToSpec(cfg jsmapi.StreamConfig) apis.StreamSpec {
+	spec := apis.StreamSpec{
+		Name:              cfg.Name,
+		Description:       cfg.Description,
+		Subjects:          cfg.Subjects,
+		MaxConsumers:      cfg.MaxConsumers,
+		MaxMsgs:           int(cfg.MaxMsgs),
+		MaxMsgsPerSubject: int(cfg.MaxMsgsPer),
+		MaxBytes:          int(cfg.MaxBytes),
+		MaxAge:            durationString(cfg.MaxAge),
+		MaxMsgSize:        int(cfg.MaxMsgSize),
+		Replicas:          cfg.Replicas,
+		NoAck:             cfg.NoAck,
+		DuplicateWindow:   durationString(cfg.Duplicates),
+		AllowDirect:       cfg.AllowDirect,
+		DenyDelete:        cfg.DenyDelete,
+		AllowRollup:       cfg.RollupAllowed,
+	}
+
+	switch cfg.Retention {
+	case jsmapi.InterestPolicy:
+		spec.Retention = "interest"
+	case jsmapi.WorkQueuePolicy:
+		spec.Retention = "workqueue"
+	default:
+		spec.Retention = "limits"
+	}
+	switch cfg.Storage {
+	case jsmapi.FileStorage:
+		spec.Storage = "file"
+	default:
+		spec.Storage = "memory"
+	}
+	switch cfg.Discard {
+	case jsmapi.DiscardNew:
+		spec.Discard = "new"
+	default:
+		spec.Discard = "old"
+	}
+
+	if rp := cfg.RePublish; rp != nil {
+		spec.Republish = &apis.RePublish{
+			Source:      rp.Source,
+			Destination: rp.Destination,
+			HeadersOnly: rp.HeadersOnly,
+		}
+	}
+	if cfg.Mirror != nil {
+		spec.Mirror = streamSourceToSpec(cfg.Mirror)
+	}
+	if p := cfg.Placement; p != nil {
+		spec.Placement = &apis.StreamPlacement{
+			Cluster: p.Cluster,
+			Tags:    p.Tags,
+		}
+	}
+	for _, ss := range cfg.Sources {
+		spec.Sources = append(spec.Sources, streamSourceToSpec(ss))
+	}
+
+	return spec
+}
+
+// streamSourceToSpec is the inverse of getStreamSource.
+func streamSourceToSpec(ss *jsmapi.StreamSource) *apis.StreamSource {
+	spec := &apis.StreamSource{
+		Name:          ss.Name,
+		FilterSubject: ss.FilterSubject,
+		OptStartSeq:   int(ss.OptStartSeq),
+	}
+	if ss.OptStartTime != nil {
+		spec.OptStartTime = ss.OptStartTime.UTC().Format(time.RFC3339)
+	}
+	if ss.External != nil {
+		spec.ExternalAPIPrefix = ss.External.ApiPrefix
+		spec.ExternalDeliverPrefix = ss.External.DeliverPrefix
+	}
+	return spec
+}
+
+// consumerConfigToSpec is the inverse of consumerSpecToOpts.
+func consumerConfigToSpec(stream string, cfg jsmapi.ConsumerConfig) apis.ConsumerSpec {
+	spec := apis.ConsumerSpec{
+		StreamName:         stream,
+		DurableName:        cfg.Durable,
+		Description:        cfg.Description,
+		DeliverSubject:     cfg.DeliverSubject,
+		DeliverGroup:       cfg.DeliverGroup,
+		FilterSubject:      cfg.FilterSubject,
+		FlowControl:        cfg.FlowControl,
+		HeartbeatInterval:  durationString(cfg.Heartbeat),
+		AckWait:            durationString(cfg.AckWait),
+		MaxAckPending:      cfg.MaxAckPending,
+		MaxDeliver:         cfg.MaxDeliver,
+		MaxWaiting:         cfg.MaxWaiting,
+		MaxRequestBatch:    cfg.MaxRequestBatch,
+		MaxRequestExpires:  durationString(cfg.MaxRequestExpires),
+		MaxRequestMaxBytes: cfg.MaxRequestMaxBytes,
+		RateLimitBps:       int(cfg.RateLimit),
+		SampleFreq:         strings.TrimSuffix(cfg.SampleFrequency, "%"),
+		HeadersOnly:        cfg.HeadersOnly,
+		Replicas:           cfg.Replicas,
+		MemStorage:         cfg.MemoryStorage,
+	}
+	for _, b := range cfg.BackOff {
+		spec.BackOff = append(spec.BackOff, b.String())
+	}
+
+	switch cfg.DeliverPolicy {
+	case jsmapi.DeliverAll:
+		spec.DeliverPolicy = "all"
+	case jsmapi.DeliverLast:
+		spec.DeliverPolicy = "last"
+	case jsmapi.DeliverNew:
+		spec.DeliverPolicy = "new"
+	case jsmapi.DeliverByStartSequence:
+		spec.DeliverPolicy = "byStartSequence"
+		spec.OptStartSeq = int(cfg.OptStartSeq)
+	case jsmapi.DeliverByStartTime:
+		spec.DeliverPolicy = "byStartTime"
+		if cfg.OptStartTime != nil {
+			spec.OptStartTime = cfg.OptStartTime.UTC().Format(time.RFC3339)
+		}
+	}
+	switch cfg.AckPolicy {
+	case jsmapi.AckNone:
+		spec.AckPolicy = "none"
+	case jsmapi.AckAll:
+		spec.AckPolicy = "all"
+	case jsmapi.AckExplicit:
+		spec.AckPolicy = "explicit"
+	}
+	switch cfg.ReplayPolicy {
+	case jsmapi.ReplayInstant:
+		spec.ReplayPolicy = "instant"
+	case jsmapi.ReplayOriginal:
+		spec.ReplayPolicy = "original"
+	}
+
+	return spec
+}
@@ -0,0 +1,185 @@
+package jetstream
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+type mockInventory struct {
+	streams   []jsmapi.StreamConfig
+	consumers map[string][]jsmapi.ConsumerConfig
+}
+
+func (m *mockInventory) StreamConfigs() ([]jsmapi.StreamConfig, error) {
+	return m.streams, nil
+}
+
+func (m *mockInventory) ConsumerConfigs(stream string) ([]jsmapi.ConsumerConfig, error) {
+	return m.consumers[stream], nil
+}
+
+func TestWriteImportManifest(t *testing.T) {
+	t.Parallel()
+
+	orders := jsmapi.StreamConfig{
+		Name:       "ORDERS",
+		Subjects:   []string{"orders.>"},
+		Retention:  jsmapi.WorkQueuePolicy,
+		Storage:    jsmapi.FileStorage,
+		Discard:    jsmapi.DiscardNew,
+		MaxAge:     24 * time.Hour,
+		Duplicates: time.Minute,
+		MaxMsgSize: -1,
+		Replicas:   3,
+	}
+	worker := jsmapi.ConsumerConfig{
+		Durable:       "worker",
+		AckPolicy:     jsmapi.AckExplicit,
+		AckWait:       30 * time.Second,
+		DeliverPolicy: jsmapi.DeliverAll,
+		ReplayPolicy:  jsmapi.ReplayInstant,
+		MaxAckPending: 100,
+		FilterSubject: "orders.new",
+	}
+	inv := &mockInventory{
+		streams: []jsmapi.StreamConfig{orders, {Name: "audit", Storage: jsmapi.MemoryStorage}},
+		consumers: map[string][]jsmapi.ConsumerConfig{
+			"ORDERS": {worker, {Name: "ephemeral", AckPolicy: jsmapi.AckNone}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeImportManifest(&buf, inv, "nats"); err != nil {
+		t.Fatal(err)
+	}
+
+	docs := strings.Split(strings.TrimPrefix(buf.String(), "---\n"), "---\n")
+	if got, want := len(docs), 3; got != want {
+		t.Fatalf("got=%d; want=%d documents:\n%s", got, want, buf.String())
+	}
+
+	// Each stream is followed by its consumers.
+	var ordersStr, audit apis.Stream
+	var workerCns apis.Consumer
+	if err := yaml.Unmarshal([]byte(docs[0]), &ordersStr); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(docs[1]), &workerCns); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(docs[2]), &audit); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := audit.Name, "audit"; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+	for _, meta := range []struct{ kind, got, name, ns string }{
+		{"Stream", ordersStr.Kind, ordersStr.Name, ordersStr.Namespace},
+		{"Consumer", workerCns.Kind, workerCns.Name, workerCns.Namespace},
+	} {
+		if meta.got != meta.kind {
+			t.Fatalf("got=%s; want=%s", meta.got, meta.kind)
+		}
+		if errs := validation.IsDNS1123Subdomain(meta.name); len(errs) > 0 {
+			t.Fatalf("invalid %s name %q: %v", meta.kind, meta.name, errs)
+		}
+		if meta.ns != "nats" {
+			t.Fatalf("got=%s; want=nats", meta.ns)
+		}
+	}
+	if ordersStr.Annotations[adoptAnnotation] != "true" || workerCns.Annotations[adoptAnnotation] != "true" {
+		t.Fatalf("got=%v, %v; want adopt annotations", ordersStr.Annotations, workerCns.Annotations)
+	}
+
+	// The specs map back to the configs they were imported from.
+	cfg, err := streamSpecToConfig(ordersStr.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, changed := mergeStreamConfig(orders, cfg); len(changed) > 0 {
+		t.Fatalf("got changed stream fields %v; want none", changed)
+	}
+	if workerCns.Spec.StreamName != "ORDERS" {
+		t.Fatalf("got=%s; want=ORDERS", workerCns.Spec.StreamName)
+	}
+	ccfg, err := consumerSpecToConfig(workerCns.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ccfg.Durable != worker.Durable || ccfg.AckPolicy != worker.AckPolicy || ccfg.AckWait != worker.AckWait ||
+		ccfg.DeliverPolicy != worker.DeliverPolicy || ccfg.ReplayPolicy != worker.ReplayPolicy ||
+		ccfg.MaxAckPending != worker.MaxAckPending || ccfg.FilterSubject != worker.FilterSubject {
+		t.Fatalf("got=%+v; want=%+v", ccfg, worker)
+	}
+}
+
+func TestWriteImportManifestNameCollision(t *testing.T) {
+	t.Parallel()
+
+	// Both consumers would be named a-b-c, the one of b-d doesn't collide.
+	inv := &mockInventory{
+		streams: []jsmapi.StreamConfig{{Name: "a-b"}, {Name: "a"}, {Name: "b"}},
+		consumers: map[string][]jsmapi.ConsumerConfig{
+			"a-b": {{Durable: "c"}},
+			"a":   {{Durable: "b-c"}},
+			"b":   {{Durable: "d"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeImportManifest(&buf, inv, "nats"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]string)
+	for _, doc := range strings.Split(strings.TrimPrefix(buf.String(), "---\n"), "---\n") {
+		var cns apis.Consumer
+		if err := yaml.Unmarshal([]byte(doc), &cns); err != nil {
+			t.Fatal(err)
+		}
+		if cns.Kind != "Consumer" {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(cns.Name); len(errs) > 0 {
+			t.Fatalf("invalid consumer name %q: %v", cns.Name, errs)
+		}
+		if other, ok := names[cns.Name]; ok {
+			t.Fatalf("consumers %s and %s/%s are both named %q", other, cns.Spec.StreamName, cns.Spec.DurableName, cns.Name)
+		}
+		names[cns.Name] = cns.Spec.StreamName + "/" + cns.Spec.DurableName
+	}
+	if len(names) != 3 {
+		t.Fatalf("got=%v; want 3 consumers", names)
+	}
+	if names["a-b-c"] != "" {
+		t.Fatalf("got a-b-c for %s; want colliding names hash-suffixed", names["a-b-c"])
+	}
+	if names["b-d"] != "b/d" {
+		t.Fatalf("got=%v; want b/d kept as b-d", names)
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"orders", "ORDERS", "orders_v2", "_tmp_", strings.Repeat("x", 300)} {
+		got := resourceName(name)
+		if errs := validation.IsDNS1123Subdomain(got); len(errs) > 0 {
+			t.Fatalf("resourceName(%q)=%q is invalid: %v", name, got, errs)
+		}
+	}
+	if got := resourceName("orders"); got != "orders" {
+		t.Fatalf("got=%s; want valid names kept as is", got)
+	}
+	if resourceName("ORDERS") == resourceName("orders") {
+		t.Fatal("names only differing in case collide")
+	}
+}
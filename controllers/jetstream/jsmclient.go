@@ -2,6 +2,7 @@ package jetstream
 
 import (
 	"context"
+	"time"
 
 	"github.com/nats-io/jsm.go"
 	jsmapi "github.com/nats-io/jsm.go/api"
@@ -12,6 +13,14 @@ type jsmClient interface {
 	Connect(servers string, opts ...nats.Option) error
 	Close()
 
+	// ServerVersion is the version of the connected NATS server, or empty
+	// if it isn't known.
+	ServerVersion() string
+
+	// Domain is the JetStream domain API calls are sent to, empty for the
+	// one of the connected server.
+	Domain() string
+
 	LoadStream(ctx context.Context, name string) (jsmStream, error)
 	NewStream(ctx context.Context, name string, opts []jsm.StreamOption) (jsmStream, error)
 
@@ -20,11 +29,16 @@ type jsmClient interface {
 }
 
 type jsmStream interface {
+	Configuration() jsmapi.StreamConfig
+	LatestInformation() (*jsmapi.StreamInfo, error)
 	UpdateConfiguration(cnf jsmapi.StreamConfig, opts ...jsm.StreamOption) error
+	ConsumerNames() ([]string, error)
+	ReadMessage(seq uint64) (*jsmapi.StoredMsg, error)
 	Delete() error
 }
 
 type jsmConsumer interface {
+	LatestState() (jsmapi.ConsumerInfo, error)
 	UpdateConfiguration(opts ...jsm.ConsumerOption) error
 	Delete() error
 }
@@ -33,9 +47,14 @@ type jsmDeleter interface {
 	Delete() error
 }
 
+// defaultJsmTimeout is the timeout of the API calls of a jsm.Manager made
+// without jsm.WithTimeout.
+const defaultJsmTimeout = 5 * time.Second
+
 type realJsmClient struct {
-	nc *nats.Conn
-	jm *jsm.Manager
+	nc     *nats.Conn
+	jm     *jsm.Manager
+	domain string
 }
 
 func (c *realJsmClient) Connect(servers string, opts ...nats.Option) error {
@@ -58,18 +77,66 @@ func (c *realJsmClient) Close() {
 	_ = c.nc.Drain()
 }
 
-func (c *realJsmClient) LoadStream(_ context.Context, name string) (jsmStream, error) {
-	return c.jm.LoadStream(name)
+// ServerVersion returns the version the server announced when the connection
+// was established, so it's fetched once per connection.
+func (c *realJsmClient) ServerVersion() string {
+	if c.nc == nil {
+		return ""
+	}
+	return c.nc.ConnectedServerVersion()
+}
+
+func (c *realJsmClient) Domain() string {
+	return c.domain
+}
+
+// manager returns the manager to make API calls with under ctx. jsm.go
+// takes no context, so when the deadline of ctx is sooner than the default
+// timeout of API calls, a manager timing them out by then is returned.
+func (c *realJsmClient) manager(ctx context.Context) (*jsm.Manager, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok || c.nc == nil || time.Until(deadline) >= defaultJsmTimeout {
+		return c.jm, nil
+	}
+
+	opts := []jsm.Option{jsm.WithTimeout(time.Until(deadline))}
+	if c.domain != "" {
+		opts = append(opts, jsm.WithDomain(c.domain))
+	}
+	return jsm.New(c.nc, opts...)
+}
+
+func (c *realJsmClient) LoadStream(ctx context.Context, name string) (jsmStream, error) {
+	jm, err := c.manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return jm.LoadStream(name)
 }
 
-func (c *realJsmClient) NewStream(_ context.Context, name string, opts []jsm.StreamOption) (jsmStream, error) {
-	return c.jm.NewStream(name, opts...)
+func (c *realJsmClient) NewStream(ctx context.Context, name string, opts []jsm.StreamOption) (jsmStream, error) {
+	jm, err := c.manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return jm.NewStream(name, opts...)
 }
 
-func (c *realJsmClient) LoadConsumer(_ context.Context, stream, consumer string) (jsmConsumer, error) {
-	return c.jm.LoadConsumer(stream, consumer)
+func (c *realJsmClient) LoadConsumer(ctx context.Context, stream, consumer string) (jsmConsumer, error) {
+	jm, err := c.manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return jm.LoadConsumer(stream, consumer)
 }
 
-func (c *realJsmClient) NewConsumer(_ context.Context, stream string, opts []jsm.ConsumerOption) (jsmConsumer, error) {
-	return c.jm.NewConsumer(stream, opts...)
+func (c *realJsmClient) NewConsumer(ctx context.Context, stream string, opts []jsm.ConsumerOption) (jsmConsumer, error) {
+	jm, err := c.manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return jm.NewConsumer(stream, opts...)
 }
@@ -1,7 +1,14 @@
 package jetstream
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
 
 	"github.com/nats-io/jsm.go"
 	jsmapi "github.com/nats-io/jsm.go/api"
@@ -9,41 +16,102 @@ import (
 )
 
 type mockStream struct {
-	deleteErr error
+	config        jsmapi.StreamConfig
+	updatedConfig *jsmapi.StreamConfig
+	updateErr     error
+	info          *jsmapi.StreamInfo
+	infoErr       error
+	consumerNames []string
+	msgs          map[uint64]*jsmapi.StoredMsg
+	deleteErr     error
+	deleted       bool
+}
+
+func (m *mockStream) LatestInformation() (*jsmapi.StreamInfo, error) {
+	if m.info == nil && m.infoErr == nil {
+		return &jsmapi.StreamInfo{Config: m.config}, nil
+	}
+	return m.info, m.infoErr
+}
+
+func (m *mockStream) Configuration() jsmapi.StreamConfig {
+	cfg := m.config
+	if cfg.Name == "" {
+		// Streams without a config of their own are the memory streams
+		// most tests declare.
+		cfg.Storage = jsmapi.MemoryStorage
+	}
+	return cfg
 }
 
 func (m *mockStream) UpdateConfiguration(cnf jsmapi.StreamConfig, opts ...jsm.StreamOption) error {
-	return nil
+	m.updatedConfig = &cnf
+	return m.updateErr
+}
+
+func (m *mockStream) ConsumerNames() ([]string, error) {
+	return m.consumerNames, nil
+}
+
+func (m *mockStream) ReadMessage(seq uint64) (*jsmapi.StoredMsg, error) {
+	msg, ok := m.msgs[seq]
+	if !ok {
+		return nil, jsmapi.ApiError{Code: 404, Description: "no message found"}
+	}
+	return msg, nil
 }
 
 func (m *mockStream) Delete() error {
+	m.deleted = true
 	return m.deleteErr
 }
 
 type mockConsumer struct {
-	deleteErr error
+	state       jsmapi.ConsumerInfo
+	stateErr    error
+	stateCalls  int
+	updatedOpts []jsm.ConsumerOption
+	deleteErr   error
+	deleted     bool
+}
+
+func (m *mockConsumer) LatestState() (jsmapi.ConsumerInfo, error) {
+	m.stateCalls++
+	return m.state, m.stateErr
 }
 
 func (m *mockConsumer) UpdateConfiguration(opts ...jsm.ConsumerOption) error {
+	m.updatedOpts = opts
 	return nil
 }
 
 func (m *mockConsumer) Delete() error {
+	m.deleted = true
 	return m.deleteErr
 }
 
 type mockJsmClient struct {
-	connectErr error
+	connectErr    error
+	serverVersion string
+	domain        string
 
-	loadStream    jsmStream
-	loadStreamErr error
-	newStream     jsmStream
-	newStreamErr  error
+	loadStreamCalls int
+	loadStream      jsmStream
+	loadStreamErr   error
+	newStream       jsmStream
+	newStreamErr    error
+	newStreamOpts   []jsm.StreamOption
 
 	loadConsumer    jsmConsumer
 	loadConsumerErr error
 	newConsumer     jsmConsumer
 	newConsumerErr  error
+	newConsumerOpts []jsm.ConsumerOption
+	newConsumers    int
+
+	// consumers, when set, are loaded by name instead of loadConsumer, and
+	// any other one isn't found.
+	consumers map[string]jsmConsumer
 }
 
 func (c *mockJsmClient) Connect(servers string, opts ...nats.Option) error {
@@ -52,18 +120,102 @@ func (c *mockJsmClient) Connect(servers string, opts ...nats.Option) error {
 
 func (c *mockJsmClient) Close() {}
 
+func (c *mockJsmClient) ServerVersion() string {
+	return c.serverVersion
+}
+
+func (c *mockJsmClient) Domain() string {
+	return c.domain
+}
+
 func (c *mockJsmClient) LoadStream(ctx context.Context, name string) (jsmStream, error) {
+	c.loadStreamCalls++
+	if c.loadStream == nil && c.loadStreamErr == nil {
+		return &mockStream{}, nil
+	}
 	return c.loadStream, c.loadStreamErr
 }
 
 func (c *mockJsmClient) NewStream(ctx context.Context, name string, opt []jsm.StreamOption) (jsmStream, error) {
+	c.newStreamOpts = opt
 	return c.newStream, c.newStreamErr
 }
 
 func (c *mockJsmClient) LoadConsumer(ctx context.Context, stream, consumer string) (jsmConsumer, error) {
+	if c.consumers != nil {
+		if cn, ok := c.consumers[consumer]; ok {
+			return cn, nil
+		}
+		return nil, jsmapi.ApiError{Code: 404}
+	}
 	return c.loadConsumer, c.loadConsumerErr
 }
 
 func (c *mockJsmClient) NewConsumer(ctx context.Context, stream string, opts []jsm.ConsumerOption) (jsmConsumer, error) {
+	c.newConsumerOpts = opts
+	c.newConsumers++
 	return c.newConsumer, c.newConsumerErr
 }
+
+// serveFakeNATS accepts NATS connections on a local port, announcing version
+// in its INFO and answering pings, and returns its URL.
+func serveFakeNATS(t *testing.T, version string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"version\":%q,\"proto\":1,\"headers\":true,\"max_payload\":1048576}\r\n", version)
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if strings.HasPrefix(line, "PING") {
+						if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+	return "nats://" + ln.Addr().String()
+}
+
+func TestRealJsmClientDeadline(t *testing.T) {
+	t.Parallel()
+
+	// The fake server never answers API calls.
+	nc, err := nats.Connect(serveFakeNATS(t, "2.9.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	jm, err := jsm.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsmc := &realJsmClient{nc: nc, jm: jm}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := jsmc.LoadStream(ctx, "orders"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got=%v; want=%v", err, context.DeadlineExceeded)
+	}
+	if took := time.Since(start); took > 3*time.Second {
+		t.Fatalf("got the call timing out after %s; want it bounded by the 1s deadline", took)
+	}
+}
@@ -0,0 +1,139 @@
+package jetstream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	k8sapi "k8s.io/api/core/v1"
+)
+
+// lagTracker counts, per consumer, the consecutive reconciles that observed
+// a lag disagreeing with its Lagging condition.
+type lagTracker struct {
+	mu      sync.Mutex
+	streaks map[string]int
+}
+
+// observe records whether the consumer at key is over the lag threshold and
+// returns whether it's lagging now, given whether it was. It only flips once
+// setAfter consecutive observations are over the threshold, or clearAfter
+// are under it, so a spiky consumer doesn't flap.
+func (t *lagTracker) observe(key string, over, lagging bool, setAfter, clearAfter int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if over == lagging {
+		delete(t.streaks, key)
+		return lagging
+	}
+	need := setAfter
+	if lagging {
+		need = clearAfter
+	}
+	t.streaks[key]++
+	if t.streaks[key] < need {
+		return lagging
+	}
+	delete(t.streaks, key)
+	return over
+}
+
+func (t *lagTracker) forget(key string) {
+	t.mu.Lock()
+	delete(t.streaks, key)
+	t.mu.Unlock()
+}
+
+// updateLagging sets the Lagging condition of cns from its number of pending
+// messages, with the hysteresis of LagSetAfter and LagClearAfter, and records
+// an event when it changes.
+func (c *Controller) updateLagging(cns *apis.Consumer, numPending uint64) {
+	lagging := false
+	var prev *apis.Condition
+	for i := range cns.Status.Conditions {
+		if cns.Status.Conditions[i].Type == laggingCondType {
+			prev = &cns.Status.Conditions[i]
+			lagging = prev.Status == k8sapi.ConditionTrue
+		}
+	}
+
+	over := numPending > c.opts.LagThreshold
+	now := c.lag.observe(objectKey(cns.Namespace, cns.Name), over, lagging, c.opts.LagSetAfter, c.opts.LagClearAfter)
+	if prev != nil && now == lagging {
+		return
+	}
+
+	cond := apis.Condition{
+		Type:               laggingCondType,
+		Status:             k8sapi.ConditionFalse,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "CaughtUp",
+		Message:            fmt.Sprintf("%d messages pending, within the threshold of %d", numPending, c.opts.LagThreshold),
+	}
+	if now {
+		cond.Status = k8sapi.ConditionTrue
+		cond.Reason = "Lagging"
+		cond.Message = fmt.Sprintf("%d messages pending, over the threshold of %d", numPending, c.opts.LagThreshold)
+		c.warningEvent(cns, "Lagging", fmt.Sprintf("Consumer %q is lagging: %s", cns.Spec.DurableName, cond.Message))
+	} else if lagging {
+		c.normalEvent(cns, "CaughtUp", fmt.Sprintf("Consumer %q caught up: %s", cns.Spec.DurableName, cond.Message))
+	}
+	cns.Status.Conditions = upsertCondition(cns.Status.Conditions, cond)
+}
+
+// updateStuck sets the Stuck condition of cns by comparing its freshly
+// refreshed live state with the previous one, prev. A refresh that finds more
+// redeliveries while the ack floor stayed put extends the streak, one that
+// finds the ack floor moved or nothing left to acknowledge resets it, and
+// anything else leaves it as is. The condition is set once the streak reaches
+// StuckAfter, and an event recorded when it changes.
+func (c *Controller) updateStuck(cns *apis.Consumer, prev *apis.LiveState) {
+	if c.opts.StuckAfter <= 0 {
+		return
+	}
+	next := cns.Status.State
+
+	stuck := false
+	var prevCond *apis.Condition
+	for i := range cns.Status.Conditions {
+		if cns.Status.Conditions[i].Type == stuckCondType {
+			prevCond = &cns.Status.Conditions[i]
+			stuck = prevCond.Status == k8sapi.ConditionTrue
+		}
+	}
+
+	switch {
+	case prev == nil:
+	case next.AckFloor != prev.AckFloor || next.NumAckPending == 0:
+		next.StuckRefreshes = 0
+	case next.NumRedelivered > prev.NumRedelivered:
+		next.StuckRefreshes = prev.StuckRefreshes + 1
+	default:
+		next.StuckRefreshes = prev.StuckRefreshes
+	}
+
+	now := next.StuckRefreshes >= c.opts.StuckAfter
+	if prevCond != nil && now == stuck {
+		return
+	}
+
+	cond := apis.Condition{
+		Type:               stuckCondType,
+		Status:             k8sapi.ConditionFalse,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "Progressing",
+		Message:            fmt.Sprintf("Ack floor at stream sequence %d, %d messages redelivered", next.AckFloor, next.NumRedelivered),
+	}
+	if now {
+		cond.Status = k8sapi.ConditionTrue
+		cond.Reason = "Stuck"
+		cond.Message = fmt.Sprintf("Redeliveries climbed to %d over %d refreshes while the ack floor stayed at stream sequence %d",
+			next.NumRedelivered, next.StuckRefreshes, next.AckFloor)
+		c.warningEvent(cns, "Stuck", fmt.Sprintf("Consumer %q looks stuck: %s", cns.Spec.DurableName, cond.Message))
+	} else if stuck {
+		c.normalEvent(cns, "Progressing", fmt.Sprintf("Consumer %q is progressing again: %s", cns.Spec.DurableName, cond.Message))
+	}
+	cns.Status.Conditions = upsertCondition(cns.Status.Conditions, cond)
+}
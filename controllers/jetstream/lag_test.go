@@ -0,0 +1,234 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLagTracker(t *testing.T) {
+	t.Parallel()
+
+	lt := &lagTracker{streaks: make(map[string]int)}
+	lagging := false
+	steps := []struct {
+		over bool
+		want bool
+	}{
+		{true, false},
+		{false, false}, // A dip resets the streak.
+		{true, false},
+		{true, false},
+		{true, true}, // Third consecutive observation over the threshold.
+		{false, true},
+		{true, true}, // A spike resets the streak.
+		{false, true},
+		{false, false}, // Second consecutive observation within it.
+	}
+	for i, step := range steps {
+		lagging = lt.observe("default/my-consumer", step.over, lagging, 3, 2)
+		assert.Equal(t, step.want, lagging, "step %d", i)
+	}
+}
+
+func TestProcessConsumerLaggingHysteresis(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(100)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+		LagThreshold:   100,
+		LagSetAfter:    2,
+		LagClearAfter:  2,
+	})
+
+	ns, name := "default", "my-consumer"
+	store := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore()
+	err := store.Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: name,
+			StreamName:  "orders",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Consumer)
+		// Keep the informer cache in sync with the written status.
+		if err := store.Update(obj); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	mc := &mockConsumer{}
+	jsmc := &mockJsmClient{loadConsumer: mc}
+	lagging := func(pending uint64) k8sapi.ConditionStatus {
+		t.Helper()
+		mc.state = jsmapi.ConsumerInfo{NumPending: pending}
+		require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+		obj, _, err := store.GetByKey(ns + "/" + name)
+		require.NoError(t, err)
+		for _, cond := range obj.(*apis.Consumer).Status.Conditions {
+			if cond.Type == laggingCondType {
+				return cond.Status
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, k8sapi.ConditionFalse, lagging(10))
+	assert.Equal(t, k8sapi.ConditionFalse, lagging(500), "not set after a single reconcile over the threshold")
+	assert.Equal(t, k8sapi.ConditionFalse, lagging(50))
+	assert.Equal(t, k8sapi.ConditionFalse, lagging(500))
+	assert.Equal(t, k8sapi.ConditionTrue, lagging(500))
+	assert.Equal(t, k8sapi.ConditionTrue, lagging(50), "not cleared after a single reconcile within the threshold")
+	assert.Equal(t, k8sapi.ConditionFalse, lagging(50))
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Contains(t, events, `Warning Lagging Consumer "my-consumer" is lagging: 500 messages pending, over the threshold of 100`)
+	assert.Contains(t, events, `Normal CaughtUp Consumer "my-consumer" caught up: 50 messages pending, within the threshold of 100`)
+}
+
+func TestProcessConsumerFetchesStateOnce(t *testing.T) {
+	t.Parallel()
+
+	stateCalls := func(opts Options) int {
+		t.Helper()
+		opts.Ctx = context.Background()
+		opts.KubeIface = k8sclientsetfake.NewSimpleClientset()
+		jc := clientsetfake.NewSimpleClientset()
+		jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+			return true, a.(k8stesting.UpdateAction).GetObject(), nil
+		})
+		opts.JetstreamIface = jc
+		opts.Recorder = record.NewFakeRecorder(100)
+		ctrl := NewController(opts)
+
+		err := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore().Add(&apis.Consumer{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "my-consumer", Generation: 1},
+			Spec:       apis.ConsumerSpec{DurableName: "my-consumer", StreamName: "orders"},
+			Status:     apis.ConsumerStatus{Status: apis.Status{ObservedGeneration: 1}},
+		})
+		require.NoError(t, err)
+
+		mc := &mockConsumer{state: jsmapi.ConsumerInfo{PushBound: true}}
+		require.NoError(t, ctrl.processConsumer("default", "my-consumer", &mockJsmClient{loadConsumer: mc}))
+		return mc.stateCalls
+	}
+
+	base := stateCalls(Options{})
+	all := stateCalls(Options{
+		StrictConsumerReadiness: true,
+		StatusRefreshInterval:   time.Nanosecond,
+		LagThreshold:            100,
+	})
+	assert.Equal(t, base+1, all, "strict readiness, live state and lag share one state fetch")
+}
+
+func TestProcessConsumerStuck(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(100)
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        jc,
+		Recorder:              rec,
+		StatusRefreshInterval: time.Nanosecond,
+		StuckAfter:            2,
+	})
+
+	ns, name := "default", "my-consumer"
+	store := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore()
+	err := store.Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: name,
+			StreamName:  "orders",
+		},
+		Status: apis.ConsumerStatus{
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Consumer)
+		if err := store.Update(obj); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	mc := &mockConsumer{}
+	jsmc := &mockJsmClient{loadConsumer: mc}
+	stuck := func(redelivered int, ackFloor uint64) k8sapi.ConditionStatus {
+		t.Helper()
+		mc.state = jsmapi.ConsumerInfo{
+			NumAckPending:  10,
+			NumRedelivered: redelivered,
+			AckFloor:       jsmapi.SequenceInfo{Stream: ackFloor},
+		}
+		require.NoError(t, ctrl.processConsumer(ns, name, jsmc))
+		obj, _, err := store.GetByKey(ns + "/" + name)
+		require.NoError(t, err)
+		for _, cond := range obj.(*apis.Consumer).Status.Conditions {
+			if cond.Type == stuckCondType {
+				return cond.Status
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, k8sapi.ConditionFalse, stuck(0, 100))
+	assert.Equal(t, k8sapi.ConditionFalse, stuck(5, 100), "not set after a single refresh with climbing redeliveries")
+	assert.Equal(t, k8sapi.ConditionFalse, stuck(5, 100), "a flat refresh keeps the streak")
+	assert.Equal(t, k8sapi.ConditionTrue, stuck(9, 100))
+	assert.Equal(t, k8sapi.ConditionTrue, stuck(12, 100))
+	assert.Equal(t, k8sapi.ConditionFalse, stuck(12, 150), "cleared once the ack floor moves")
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	assert.Contains(t, events, `Warning Stuck Consumer "my-consumer" looks stuck: Redeliveries climbed to 9 over 2 refreshes while the ack floor stayed at stream sequence 100`)
+	assert.Contains(t, events, `Normal Progressing Consumer "my-consumer" is progressing again: Ack floor at stream sequence 150, 12 messages redelivered`)
+}
@@ -0,0 +1,34 @@
+package jetstream
+
+import (
+	"expvar"
+)
+
+const (
+	// reconcilesVar and reconcileErrorsVar are the expvar names of the
+	// reconcile counters.
+	reconcilesVar      = "jetstream_reconciles_total"
+	reconcileErrorsVar = "jetstream_reconcile_errors_total"
+)
+
+// reconcileMetrics counts the reconciles of streams and consumers, and the
+// failed ones, per namespace. They're keyed by namespace rather than resource
+// to keep the number of keys bounded.
+type reconcileMetrics struct {
+	total  *expvar.Map
+	errors *expvar.Map
+}
+
+func newReconcileMetrics() *reconcileMetrics {
+	return &reconcileMetrics{
+		total:  new(expvar.Map).Init(),
+		errors: new(expvar.Map).Init(),
+	}
+}
+
+func (m *reconcileMetrics) record(ns string, err error) {
+	m.total.Add(ns, 1)
+	if err != nil {
+		m.errors.Add(ns, 1)
+	}
+}
@@ -0,0 +1,86 @@
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReconcileMetricsPerNamespace(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for _, ns := range []string{"team-a", "team-b"} {
+		err := store.Add(&apis.Stream{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:  ns,
+				Name:       "orders",
+				Generation: 1,
+			},
+			Spec: apis.StreamSpec{
+				Name:    ns + "-orders",
+				Storage: "memory",
+			},
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	ok := &mockJsmClient{}
+	failing := &mockJsmClient{loadStreamErr: errors.New("boom")}
+	for i := 0; i < 2; i++ {
+		if err := ctrl.processStream("team-a", "orders", ok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ctrl.processStream("team-b", "orders", failing); err == nil {
+		t.Fatal("expected error")
+	}
+
+	count := func(m *expvar.Map, ns string) int64 {
+		v, _ := m.Get(ns).(*expvar.Int)
+		if v == nil {
+			return 0
+		}
+		return v.Value()
+	}
+	for _, tt := range []struct {
+		m    *expvar.Map
+		ns   string
+		want int64
+	}{
+		{ctrl.metrics.total, "team-a", 2},
+		{ctrl.metrics.errors, "team-a", 0},
+		{ctrl.metrics.total, "team-b", 1},
+		{ctrl.metrics.errors, "team-b", 1},
+	} {
+		if got := count(tt.m, tt.ns); got != tt.want {
+			t.Errorf("%s: got=%d; want=%d", tt.ns, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package jetstream
+
+import (
+	"fmt"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+)
+
+// SpecMutator customizes stream and consumer specs before they're applied to
+// NATS, to enforce organization-specific defaults or policies without forking
+// the controller. The resource is passed for its metadata and must not be
+// modified, only the spec, which is a deep copy.
+type SpecMutator interface {
+	MutateStream(str *apis.Stream, spec *apis.StreamSpec) error
+	MutateConsumer(cns *apis.Consumer, spec *apis.ConsumerSpec) error
+}
+
+// mutateStreamSpec returns the spec of str after the SpecMutator, if any.
+func (c *Controller) mutateStreamSpec(str *apis.Stream) (apis.StreamSpec, error) {
+	if c.opts.SpecMutator == nil {
+		return str.Spec, nil
+	}
+	spec := str.Spec.DeepCopy()
+	if err := c.opts.SpecMutator.MutateStream(str, spec); err != nil {
+		c.warningEvent(str, "MutateFailed", fmt.Sprintf("Failed to mutate spec of stream %q: %s", str.Spec.Name, err))
+		return apis.StreamSpec{}, fmt.Errorf("failed to mutate stream spec: %w", err)
+	}
+	return *spec, nil
+}
+
+// mutateConsumerSpec returns the spec of cns after the SpecMutator, if any.
+func (c *Controller) mutateConsumerSpec(cns *apis.Consumer) (apis.ConsumerSpec, error) {
+	if c.opts.SpecMutator == nil {
+		return cns.Spec, nil
+	}
+	spec := cns.Spec.DeepCopy()
+	if err := c.opts.SpecMutator.MutateConsumer(cns, spec); err != nil {
+		c.warningEvent(cns, "MutateFailed", fmt.Sprintf("Failed to mutate spec of consumer %q: %s", cns.Spec.DurableName, err))
+		return apis.ConsumerSpec{}, fmt.Errorf("failed to mutate consumer spec: %w", err)
+	}
+	return *spec, nil
+}
@@ -0,0 +1,142 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+// replicasMutator forces three stream replicas and caps consumer deliveries.
+type replicasMutator struct{}
+
+func (replicasMutator) MutateStream(str *apis.Stream, spec *apis.StreamSpec) error {
+	spec.Replicas = 3
+	return nil
+}
+
+func (replicasMutator) MutateConsumer(cns *apis.Consumer, spec *apis.ConsumerSpec) error {
+	spec.MaxDeliver = 5
+	return nil
+}
+
+func TestSpecMutatorStream(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+		SpecMutator:    replicasMutator{},
+	})
+
+	ns, name := "default", "orders"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:     name,
+			MaxAge:   "1h",
+			Storage:  "memory",
+			Replicas: 1,
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	}
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	if err := informer.Informer().GetStore().Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{
+			Name:     name,
+			Storage:  jsmapi.MemoryStorage,
+			MaxAge:   time.Hour,
+			Replicas: 1,
+		},
+	}
+	if err := ctrl.processStream(ns, name, &mockJsmClient{loadStream: ms}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ms.updatedConfig == nil {
+		t.Fatal("expected stream configuration update")
+	}
+	if got, want := ms.updatedConfig.Replicas, 3; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	if got, want := str.Spec.Replicas, 1; got != want {
+		t.Fatalf("cached spec mutated: got=%d; want=%d", got, want)
+	}
+}
+
+func TestSpecMutatorConsumer(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+		SpecMutator:    replicasMutator{},
+	})
+
+	ns, name := "default", "my-consumer"
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Consumers()
+	err := informer.Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.ConsumerSpec{
+			DurableName: "worker",
+			StreamName:  "orders",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "consumers", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadConsumerErr: jsmapi.ApiError{Code: 404},
+		newConsumer:     &mockConsumer{},
+	}
+	if err := ctrl.processConsumer(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := jsm.NewConsumerConfiguration(jsm.DefaultConsumer, jsmc.newConsumerOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.MaxDeliver, 5; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+}
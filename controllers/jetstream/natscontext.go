@@ -0,0 +1,150 @@
+package jetstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	"github.com/nats-io/nats.go"
+)
+
+// natsContext is the part of a nats CLI context file used to connect. File
+// paths in it are relative to the directory the context secret is written
+// to, so the secret can bundle the creds and certs it refers to.
+type natsContext struct {
+	URL         string `json:"url"`
+	User        string `json:"user"`
+	Password    string `json:"password"`
+	Token       string `json:"token"`
+	Creds       string `json:"creds"`
+	NKey        string `json:"nkey"`
+	Cert        string `json:"cert"`
+	Key         string `json:"key"`
+	CA          string `json:"ca"`
+	InboxPrefix string `json:"inbox_prefix"`
+}
+
+func parseNATSContext(data []byte) (*natsContext, error) {
+	var nctx natsContext
+	if err := json.Unmarshal(data, &nctx); err != nil {
+		return nil, fmt.Errorf("invalid NATS context: %w", err)
+	}
+
+	if nctx.URL == "" {
+		return nil, errors.New("invalid NATS context: url is required")
+	}
+	if (nctx.Cert == "") != (nctx.Key == "") {
+		return nil, errors.New("invalid NATS context: cert and key must be set together")
+	}
+	if nctx.Password != "" && nctx.User == "" {
+		return nil, errors.New("invalid NATS context: password requires user")
+	}
+	var auth int
+	for _, v := range []string{nctx.User, nctx.Token, nctx.Creds, nctx.NKey} {
+		if v != "" {
+			auth++
+		}
+	}
+	if auth > 1 {
+		return nil, errors.New("invalid NATS context: only one of user, token, creds and nkey can be set")
+	}
+
+	return &nctx, nil
+}
+
+func (nctx *natsContext) servers() []string {
+	var servers []string
+	for _, s := range strings.Split(nctx.URL, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+func (nctx *natsContext) options(dir string) ([]nats.Option, error) {
+	path := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	var opts []nats.Option
+	switch {
+	case nctx.User != "":
+		opts = append(opts, nats.UserInfo(nctx.User, nctx.Password))
+	case nctx.Token != "":
+		opts = append(opts, nats.Token(nctx.Token))
+	case nctx.Creds != "":
+		opts = append(opts, nats.UserCredentials(path(nctx.Creds)))
+	case nctx.NKey != "":
+		opt, err := nats.NkeyOptionFromSeed(path(nctx.NKey))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	if nctx.Cert != "" {
+		opts = append(opts, nats.ClientCert(path(nctx.Cert), path(nctx.Key)))
+	}
+	if nctx.CA != "" {
+		opts = append(opts, nats.RootCAs(path(nctx.CA)))
+	}
+	if nctx.InboxPrefix != "" {
+		opts = append(opts, nats.CustomInboxPrefix(nctx.InboxPrefix))
+	}
+
+	return opts, nil
+}
+
+// credsFile returns the creds file the context authenticates with, empty when
+// it uses none or another kind of auth takes precedence.
+func (nctx *natsContext) credsFile(dir string) string {
+	if nctx.User != "" || nctx.Token != "" || nctx.Creds == "" {
+		return ""
+	}
+	if filepath.IsAbs(nctx.Creds) {
+		return nctx.Creds
+	}
+	return filepath.Join(dir, nctx.Creds)
+}
+
+// loadAccountContext writes the account's context secret to the cache dir and
+// returns the servers, connection options and creds file of the context in
+// it.
+func (c *Controller) loadAccountContext(ns, account string, ref *apis.ContextSecret) ([]string, []nats.Option, string, error) {
+	secret, err := c.getSecret(c.ctx, ns, ref.Secret.Name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	accDir := filepath.Join(c.cacheDir, ns, account)
+	if err := os.MkdirAll(accDir, 0755); err != nil {
+		return nil, nil, "", err
+	}
+	for k, v := range secret.Data {
+		if err := os.WriteFile(filepath.Join(accDir, k), v, 0600); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	data, ok := secret.Data[ref.File]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("NATS context %q not found in secret %q", ref.File, ref.Secret.Name)
+	}
+	nctx, err := parseNATSContext(data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	opts, err := nctx.options(accDir)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return nctx.servers(), opts, nctx.credsFile(accDir), nil
+}
@@ -0,0 +1,88 @@
+package jetstream
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	"github.com/nats-io/nats.go"
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadAccountContext(t *testing.T) {
+	t.Parallel()
+
+	secret := &k8sapi.Secret{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "nats-context",
+		},
+		Data: map[string][]byte{
+			"ctx.json": []byte(`{
+				"description": "production",
+				"url": "nats://a:4222, nats://b:4222",
+				"user": "nack",
+				"password": "s3cret",
+				"inbox_prefix": "_INBOX.nack"
+			}`),
+		},
+	}
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(secret),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+	})
+	// The context secret holds a password, keep it out of the package dir.
+	ctrl.cacheDir = t.TempDir()
+
+	servers, opts, creds, err := ctrl.loadAccountContext("default", "a", &apis.ContextSecret{
+		File:   "ctx.json",
+		Secret: apis.SecretRef{Name: "nats-context"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := strings.Join(servers, ","), "nats://a:4222,nats://b:4222"; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+
+	o := nats.GetDefaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if o.User != "nack" || o.Password != "s3cret" || o.InboxPrefix != "_INBOX.nack" {
+		t.Fatalf("unexpected options: user=%q password=%q inbox=%q", o.User, o.Password, o.InboxPrefix)
+	}
+	// A context authenticating with a password has no creds file.
+	if creds != "" {
+		t.Fatalf("got creds=%q; want none", creds)
+	}
+}
+
+func TestParseNATSContextErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data string
+		want string
+	}{
+		"malformed":        {`{"url":`, "invalid NATS context"},
+		"missing url":      {`{"user": "a"}`, "url is required"},
+		"cert without key": {`{"url": "nats://a", "cert": "tls.crt"}`, "cert and key must be set together"},
+		"password only":    {`{"url": "nats://a", "password": "p"}`, "password requires user"},
+		"conflicting auth": {`{"url": "nats://a", "token": "t", "creds": "u.creds"}`, "only one of"},
+	}
+	for name, tt := range tests {
+		_, err := parseNATSContext([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got=%v; want=%s", name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package jetstream
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsOptionParsers map the keys accepted in a resource's natsOptions to the
+// NATS client option they set.
+var natsOptionParsers = map[string]func(v string) (nats.Option, error){
+	"pingInterval":     durationOption(nats.PingInterval),
+	"maxPingsOut":      intOption(nats.MaxPingsOutstanding),
+	"reconnectWait":    durationOption(nats.ReconnectWait),
+	"reconnectBufSize": intOption(nats.ReconnectBufSize),
+	"timeout":          durationOption(nats.Timeout),
+	"drainTimeout":     durationOption(nats.DrainTimeout),
+	"noEcho":           boolOption(nats.NoEcho),
+	"retryOnFailedConnect": func(v string) (nats.Option, error) {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		return nats.RetryOnFailedConnect(b), nil
+	},
+	"inboxPrefix": func(v string) (nats.Option, error) {
+		return nats.CustomInboxPrefix(v), nil
+	},
+}
+
+// getNATSOptions converts the natsOptions of a resource to NATS client
+// options. Unknown keys and malformed values are an error.
+func getNATSOptions(opts map[string]string) ([]nats.Option, error) {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	natsOpts := make([]nats.Option, 0, len(opts))
+	for _, k := range keys {
+		parse, ok := natsOptionParsers[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown NATS option %q", k)
+		}
+		opt, err := parse(opts[k])
+		if err != nil {
+			return nil, fmt.Errorf("invalid NATS option %q: %w", k, err)
+		}
+		natsOpts = append(natsOpts, opt)
+	}
+	return natsOpts, nil
+}
+
+func durationOption(f func(time.Duration) nats.Option) func(string) (nats.Option, error) {
+	return func(v string) (nats.Option, error) {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		return f(d), nil
+	}
+}
+
+func intOption(f func(int) nats.Option) func(string) (nats.Option, error) {
+	return func(v string) (nats.Option, error) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		return f(n), nil
+	}
+}
+
+// boolOption is for options that are only ever switched on.
+func boolOption(f func() nats.Option) func(string) (nats.Option, error) {
+	return func(v string) (nats.Option, error) {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		if !b {
+			return func(*nats.Options) error { return nil }, nil
+		}
+		return f(), nil
+	}
+}
@@ -0,0 +1,54 @@
+package jetstream
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestGetNATSOptions(t *testing.T) {
+	t.Parallel()
+
+	opts, err := getNATSOptions(map[string]string{
+		"pingInterval": "10s",
+		"maxPingsOut":  "5",
+		"noEcho":       "true",
+		"inboxPrefix":  "_INBOX.nack",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := nats.GetDefaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if o.PingInterval != 10*time.Second || o.MaxPingsOut != 5 || !o.NoEcho || o.InboxPrefix != "_INBOX.nack" {
+		t.Fatalf("unexpected options: ping=%s pings=%d noEcho=%t inbox=%q",
+			o.PingInterval, o.MaxPingsOut, o.NoEcho, o.InboxPrefix)
+	}
+}
+
+func TestGetNATSOptionsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts map[string]string
+		want string
+	}{
+		"unknown key":  {map[string]string{"userJwt": "x"}, `unknown NATS option "userJwt"`},
+		"bad duration": {map[string]string{"timeout": "soon"}, `invalid NATS option "timeout"`},
+		"bad int":      {map[string]string{"maxPingsOut": "many"}, `invalid NATS option "maxPingsOut"`},
+		"bad bool":     {map[string]string{"noEcho": "yes please"}, `invalid NATS option "noEcho"`},
+	}
+	for name, tt := range tests {
+		_, err := getNATSOptions(tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got=%v; want=%s", name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package jetstream
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	klog "k8s.io/klog/v2"
+)
+
+// pausedKey is the PauseConfigMap key that pauses the controller when set
+// to "true".
+const pausedKey = "paused"
+
+// pauseSwitch tracks whether the controller is paused, and lets workers
+// wait for it to be resumed.
+type pauseSwitch struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+func (p *pauseSwitch) set(paused bool) (changed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused == paused {
+		return false
+	}
+	p.paused = paused
+	if paused {
+		p.resumed = make(chan struct{})
+	} else {
+		close(p.resumed)
+	}
+	return true
+}
+
+// wait returns a channel closed once the switch is resumed, nil when it
+// isn't paused.
+func (p *pauseSwitch) wait() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return nil
+	}
+	return p.resumed
+}
+
+func (p *pauseSwitch) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// newPauseInformerFactory returns an informer factory watching only the
+// PauseConfigMap.
+func newPauseInformerFactory(opt Options) kubeinformers.SharedInformerFactory {
+	ref := opt.PauseConfigMap
+	return kubeinformers.NewSharedInformerFactoryWithOptions(opt.KubeIface, 0,
+		kubeinformers.WithNamespace(ref.Namespace),
+		kubeinformers.WithTweakListOptions(func(o *k8smeta.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", ref.Name).String()
+		}),
+	)
+}
+
+// watchPauseConfigMap pauses and resumes the controller as the paused key of
+// the PauseConfigMap changes. Deleting the ConfigMap resumes it.
+func (c *Controller) watchPauseConfigMap(factory kubeinformers.SharedInformerFactory) {
+	update := func(obj interface{}, deleted bool) {
+		cm, ok := obj.(*k8sapi.ConfigMap)
+		if !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			if cm, ok = tombstone.Obj.(*k8sapi.ConfigMap); !ok {
+				return
+			}
+		}
+		if (types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}) != c.opts.PauseConfigMap {
+			return
+		}
+
+		paused := !deleted && cm.Data[pausedKey] == "true"
+		if c.pause.set(paused) {
+			if paused {
+				klog.Infof("Paused by ConfigMap %s, workers are idle", c.opts.PauseConfigMap)
+			} else {
+				klog.Infof("Resumed by ConfigMap %s", c.opts.PauseConfigMap)
+			}
+		}
+	}
+
+	factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { update(obj, false) },
+		UpdateFunc: func(_, obj interface{}) { update(obj, false) },
+		DeleteFunc: func(obj interface{}) { update(obj, true) },
+	})
+}
+
+// whenResumed wraps process so that it first waits for the controller to be
+// resumed, keeping workers from making any NATS calls while it's paused.
+func (c *Controller) whenResumed(process processorFunc) processorFunc {
+	return func(ns, name string, jsmc jsmClient) error {
+		if resumed := c.pause.wait(); resumed != nil {
+			select {
+			case <-resumed:
+			case <-c.ctx.Done():
+				return c.ctx.Err()
+			}
+		}
+		return process(ns, name, jsmc)
+	}
+}
+
+// Healthz is a health check handler, also reporting whether the controller
+// is paused.
+func (c *Controller) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+		Paused bool   `json:"paused"`
+	}{
+		Status: "ok",
+		Paused: c.pause.isPaused(),
+	})
+	if err != nil {
+		klog.Infof("failed to write health response: %s", err)
+	}
+}
@@ -0,0 +1,97 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8sapis "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestPauseConfigMap(t *testing.T) {
+	t.Parallel()
+
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	cm := &k8sapis.ConfigMap{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "nack-pause"},
+		Data:       map[string]string{pausedKey: "true"},
+	}
+	kc := k8sclientsetfake.NewSimpleClientset(cm)
+	ctrl := NewController(Options{
+		Ctx:            ctx,
+		KubeIface:      kc,
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+		PauseConfigMap: types.NamespacedName{Namespace: "default", Name: "nack-pause"},
+	})
+	ctrl.pauseInformerFactory.Start(ctx.Done())
+	ctrl.pauseInformerFactory.WaitForCacheSync(ctx.Done())
+
+	healthzPaused := func() bool {
+		rr := httptest.NewRecorder()
+		ctrl.Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+		var health struct {
+			Paused bool `json:"paused"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&health); err != nil {
+			t.Fatal(err)
+		}
+		return health.Paused
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !ctrl.pause.isPaused() {
+		if time.Now().After(deadline) {
+			t.Fatal("controller not paused by the ConfigMap")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !healthzPaused() {
+		t.Fatal("got paused=false from healthz; want=true")
+	}
+
+	q := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "StreamsTest")
+	defer q.ShutDown()
+	q.Add("default/mystream")
+
+	processed := make(chan string, 1)
+	go processQueueNext(q, &mockJsmClient{}, ctrl.whenResumed(func(ns, name string, c jsmClient) error {
+		processed <- name
+		return nil
+	}))
+
+	select {
+	case name := <-processed:
+		t.Fatalf("processed %q while paused", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cm = cm.DeepCopy()
+	cm.Data[pausedKey] = "false"
+	if _, err := kc.CoreV1().ConfigMaps("default").Update(ctx, cm, k8smeta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-processed:
+		if name != "mystream" {
+			t.Fatalf("got=%s; want=mystream", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker not resumed after unpausing")
+	}
+	if healthzPaused() {
+		t.Fatal("got paused=true from healthz; want=false")
+	}
+}
@@ -0,0 +1,100 @@
+package jetstream
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nats-io/jwt/v2"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	klog "k8s.io/klog/v2"
+)
+
+// preflightDeliverSubject warns when the publish permissions of the user the
+// consumer connects as, with the creds file creds, don't allow its deliver
+// subject. Permissions can only be introspected from a user JWT, the check is
+// skipped otherwise.
+func (c *Controller) preflightDeliverSubject(cns *apis.Consumer, spec apis.ConsumerSpec, creds string) {
+	if creds == "" {
+		klog.V(4).Infof("Skipping deliver subject preflight of consumer %q: no user credentials", spec.DurableName)
+		return
+	}
+
+	perm, err := credsPubPermission(creds)
+	if err != nil {
+		klog.Infof("Skipping deliver subject preflight of consumer %q: %s", spec.DurableName, err)
+		return
+	}
+	if !publishAllowed(perm, spec.DeliverSubject) {
+		c.warningEvent(cns, "PreflightFailed",
+			fmt.Sprintf("Deliver subject %q of consumer %q is not allowed by the user's publish permissions", spec.DeliverSubject, spec.DurableName))
+	}
+}
+
+// connectionCreds returns the creds file a resource authenticates with in
+// CRDConnect mode, empty when it uses none. The creds of the NATS context of
+// its Account take precedence over the creds of the Account, which take
+// precedence over its own creds or, without an nkey nor an Account context,
+// the default ones.
+func (c *Controller) connectionCreds(own string, hasNkey bool, accCreds, accContextCreds string, hasAccContext bool) (string, error) {
+	switch {
+	case accContextCreds != "":
+		return accContextCreds, nil
+	case accCreds != "":
+		return accCreds, nil
+	}
+	return c.resourceCreds(own, hasNkey || hasAccContext)
+}
+
+// credsPubPermission returns the publish permission of the user JWT in a
+// creds file.
+func credsPubPermission(path string) (jwt.Permission, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return jwt.Permission{}, err
+	}
+	token, err := jwt.ParseDecoratedJWT(contents)
+	if err != nil {
+		return jwt.Permission{}, err
+	}
+	claims, err := jwt.DecodeUserClaims(token)
+	if err != nil {
+		return jwt.Permission{}, fmt.Errorf("failed to decode user JWT: %w", err)
+	}
+	return claims.Pub, nil
+}
+
+// publishAllowed reports whether perm allows publishing to subject. Without
+// an allow list every subject not denied is allowed.
+func publishAllowed(perm jwt.Permission, subject string) bool {
+	for _, f := range perm.Deny {
+		if subjectMatches(f, subject) {
+			return false
+		}
+	}
+	if len(perm.Allow) == 0 {
+		return true
+	}
+	for _, f := range perm.Allow {
+		if subjectMatches(f, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectMatches reports whether subject matches filter, which may contain
+// the * and > wildcards.
+func subjectMatches(filter, subject string) bool {
+	ft := strings.Split(filter, ".")
+	st := strings.Split(subject, ".")
+	for i, t := range ft {
+		if t == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) || (t != "*" && t != st[i]) {
+			return false
+		}
+	}
+	return len(ft) == len(st)
+}
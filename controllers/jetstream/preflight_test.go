@@ -0,0 +1,121 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/jwt/v2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+	k8sapis "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		filter  string
+		subject string
+		want    bool
+	}{
+		{"orders.deliver", "orders.deliver", true},
+		{"orders.deliver", "orders.other", false},
+		{"orders.*", "orders.deliver", true},
+		{"orders.*", "orders.deliver.a", false},
+		{"orders.>", "orders.deliver.a", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+		{"*.deliver", "orders.deliver", true},
+		{"orders.deliver.a", "orders.deliver", false},
+	}
+
+	for _, tt := range tests {
+		if got := subjectMatches(tt.filter, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q): got=%t; want=%t", tt.filter, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestPublishAllowed(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		perm jwt.Permission
+		want bool
+	}{
+		"no permissions": {
+			want: true,
+		},
+		"allowed": {
+			perm: jwt.Permission{Allow: jwt.StringList{"deliver.>"}},
+			want: true,
+		},
+		"not in allow list": {
+			perm: jwt.Permission{Allow: jwt.StringList{"other.>"}},
+			want: false,
+		},
+		"denied": {
+			perm: jwt.Permission{Deny: jwt.StringList{"deliver.*"}},
+			want: false,
+		},
+		"deny wins over allow": {
+			perm: jwt.Permission{Allow: jwt.StringList{">"}, Deny: jwt.StringList{"deliver.orders"}},
+			want: false,
+		},
+	}
+
+	for name, tt := range tests {
+		if got := publishAllowed(tt.perm, "deliver.orders"); got != tt.want {
+			t.Errorf("%s: got=%t; want=%t", name, got, tt.want)
+		}
+	}
+}
+
+func TestConnectionCreds(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx: context.Background(),
+		KubeIface: k8sclientsetfake.NewSimpleClientset(&k8sapis.Secret{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "nats", Name: "default-creds"},
+			Data:       map[string][]byte{"user.creds": []byte("default user")},
+		}),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		DefaultCredentialsSecret: SecretKeyRef{
+			Namespace: "nats",
+			Name:      "default-creds",
+			Key:       "user.creds",
+		},
+	})
+	ctrl.cacheDir = t.TempDir()
+	defaultCreds, err := ctrl.resourceCreds("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		own             string
+		hasNkey         bool
+		accCreds        string
+		accContextCreds string
+		hasAccContext   bool
+		want            string
+	}{
+		"default":                  {want: defaultCreds},
+		"own":                      {own: "own.creds", want: "own.creds"},
+		"nkey":                     {hasNkey: true, want: ""},
+		"account":                  {own: "own.creds", accCreds: "acc.creds", want: "acc.creds"},
+		"account context":          {accCreds: "acc.creds", accContextCreds: "ctx.creds", hasAccContext: true, want: "ctx.creds"},
+		"account context password": {hasAccContext: true, want: ""},
+	}
+	for name, tt := range tests {
+		got, err := ctrl.connectionCreds(tt.own, tt.hasNkey, tt.accCreds, tt.accContextCreds, tt.hasAccContext)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got=%q; want=%q", name, got, tt.want)
+		}
+	}
+}
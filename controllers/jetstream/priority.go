@@ -0,0 +1,196 @@
+package jetstream
+
+import (
+	"container/heap"
+	"strconv"
+	"sync"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	klog "k8s.io/klog/v2"
+)
+
+// priorityFunc returns the priority of a queued item, higher first.
+type priorityFunc func(item interface{}) int
+
+// annotationPriority returns the priority in the priority annotation of o,
+// zero when it's unset or invalid.
+func annotationPriority(o k8smeta.Object) int {
+	v, ok := o.GetAnnotations()[priorityAnnotation]
+	if !ok {
+		return 0
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil {
+		klog.V(2).Infof("ignoring invalid %s annotation of %s/%s: %s", priorityAnnotation, o.GetNamespace(), o.GetName(), err)
+		return 0
+	}
+	return p
+}
+
+type priorityItem struct {
+	item     interface{}
+	priority int
+	seq      uint64
+}
+
+// priorityHeap orders items by priority, and then in the order they were
+// added.
+type priorityHeap []priorityItem
+
+func (h priorityHeap) Len() int { return len(h) }
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h priorityHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *priorityHeap) Push(x interface{}) { *h = append(*h, x.(priorityItem)) }
+func (h *priorityHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// priorityQueue is a workqueue.Interface handing out the highest priority
+// item first. Like workqueue.Type, an item is only queued once and isn't
+// handed out again until it's Done.
+type priorityQueue struct {
+	priority priorityFunc
+
+	cond       *sync.Cond
+	queue      priorityHeap
+	seq        uint64
+	dirty      map[interface{}]struct{}
+	processing map[interface{}]struct{}
+
+	shuttingDown bool
+}
+
+func newPriorityQueue(priority priorityFunc) *priorityQueue {
+	return &priorityQueue{
+		priority:   priority,
+		cond:       sync.NewCond(&sync.Mutex{}),
+		dirty:      make(map[interface{}]struct{}),
+		processing: make(map[interface{}]struct{}),
+	}
+}
+
+// newPriorityRateLimitingQueue returns a rate limiting queue backed by a
+// priorityQueue.
+func newPriorityRateLimitingQueue(rateLimiter workqueue.RateLimiter, name string, priority priorityFunc) workqueue.RateLimitingInterface {
+	return &priorityRateLimitingQueue{
+		DelayingInterface: workqueue.NewDelayingQueueWithCustomQueue(newPriorityQueue(priority), name),
+		rateLimiter:       rateLimiter,
+	}
+}
+
+// push queues item, with q.cond.L held.
+func (q *priorityQueue) push(item interface{}, priority int) {
+	q.seq++
+	heap.Push(&q.queue, priorityItem{item: item, priority: priority, seq: q.seq})
+	q.cond.Signal()
+}
+
+func (q *priorityQueue) Add(item interface{}) {
+	priority := q.priority(item)
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	if q.shuttingDown {
+		return
+	}
+	if _, ok := q.dirty[item]; ok {
+		return
+	}
+	q.dirty[item] = struct{}{}
+	if _, ok := q.processing[item]; ok {
+		return
+	}
+	q.push(item, priority)
+}
+
+func (q *priorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.queue.Len()
+}
+
+func (q *priorityQueue) Get() (item interface{}, shutdown bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	for q.queue.Len() == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if q.queue.Len() == 0 {
+		return nil, true
+	}
+
+	item = heap.Pop(&q.queue).(priorityItem).item
+	q.processing[item] = struct{}{}
+	delete(q.dirty, item)
+	return item, false
+}
+
+func (q *priorityQueue) Done(item interface{}) {
+	priority := q.priority(item)
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	delete(q.processing, item)
+	if _, ok := q.dirty[item]; ok {
+		q.push(item, priority)
+	}
+	if len(q.processing) == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+func (q *priorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+func (q *priorityQueue) ShutDownWithDrain() {
+	q.ShutDown()
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.processing) > 0 {
+		q.cond.Wait()
+	}
+}
+
+func (q *priorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
+
+// priorityRateLimitingQueue adds rate limited requeues to a delaying queue,
+// as workqueue.NewRateLimitingQueue does for the default queue.
+type priorityRateLimitingQueue struct {
+	workqueue.DelayingInterface
+	rateLimiter workqueue.RateLimiter
+}
+
+func (q *priorityRateLimitingQueue) AddRateLimited(item interface{}) {
+	q.DelayingInterface.AddAfter(item, q.rateLimiter.When(item))
+}
+
+func (q *priorityRateLimitingQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+func (q *priorityRateLimitingQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
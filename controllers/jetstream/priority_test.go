@@ -0,0 +1,94 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	t.Parallel()
+
+	priorities := map[string]int{"high": 10, "higher": 20, "low": -5}
+	q := newPriorityQueue(func(item interface{}) int {
+		return priorities[item.(string)]
+	})
+	defer q.ShutDown()
+
+	for _, item := range []string{"neutral1", "low", "high", "neutral2", "higher", "high"} {
+		q.Add(item)
+	}
+	if got, want := q.Len(), 5; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+
+	for _, want := range []string{"higher", "high", "neutral1", "neutral2", "low"} {
+		got, shutdown := q.Get()
+		if shutdown {
+			t.Fatal("unexpected shutdown")
+		}
+		if got != want {
+			t.Fatalf("got=%s; want=%s", got, want)
+		}
+		q.Done(got)
+	}
+}
+
+func TestPriorityQueueRequeuesDirty(t *testing.T) {
+	t.Parallel()
+
+	q := newPriorityQueue(func(item interface{}) int { return 0 })
+	defer q.ShutDown()
+
+	q.Add("a")
+	item, _ := q.Get()
+	q.Add("a")
+	if got, want := q.Len(), 0; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	q.Done(item)
+	if got, want := q.Len(), 1; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+}
+
+func TestStreamQueuePriorityAnnotation(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+	defer ctrl.strQueue.ShutDown()
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer()
+	for name, priority := range map[string]string{"tail": "", "critical": "100", "invalid": "high"} {
+		str := &apis.Stream{ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: name}}
+		if priority != "" {
+			str.Annotations = map[string]string{priorityAnnotation: priority}
+		}
+		if err := informer.GetStore().Add(str); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctrl.strQueue.Add("default/tail")
+	ctrl.strQueue.Add("default/invalid")
+	ctrl.strQueue.Add("default/critical")
+
+	for _, want := range []string{"default/critical", "default/tail", "default/invalid"} {
+		got, _ := ctrl.strQueue.Get()
+		if got != want {
+			t.Fatalf("got=%s; want=%s", got, want)
+		}
+		ctrl.strQueue.Done(got)
+	}
+}
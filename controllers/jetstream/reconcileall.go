@@ -0,0 +1,181 @@
+package jetstream
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// reconcileAllPollInterval is how often ReconcileAll checks whether every
+// resource has settled.
+const reconcileAllPollInterval = time.Second
+
+// outcomeTracker records the result of the latest reconcile of each
+// resource, keyed by kind and namespace/name, and why the ones that weren't
+// reconciled were skipped.
+type outcomeTracker struct {
+	mu       sync.Mutex
+	outcomes map[string]error
+	skipped  map[string]string
+}
+
+func outcomeKey(kind, ns, name string) string {
+	return fmt.Sprintf("%s %s", kind, objectKey(ns, name))
+}
+
+func (t *outcomeTracker) record(kind, ns, name string, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := outcomeKey(kind, ns, name)
+	t.outcomes[key] = err
+	delete(t.skipped, key)
+}
+
+// skip records that the resource wasn't reconciled, for reason.
+func (t *outcomeTracker) skip(kind, ns, name, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.skipped == nil {
+		t.skipped = make(map[string]string)
+	}
+	key := outcomeKey(kind, ns, name)
+	t.skipped[key] = reason
+	delete(t.outcomes, key)
+}
+
+func (t *outcomeTracker) get(key string) (err error, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err, ok = t.outcomes[key]
+	return err, ok
+}
+
+func (t *outcomeTracker) skipReason(key string) (reason string, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reason, ok = t.skipped[key]
+	return reason, ok
+}
+
+// enqueueAll adds every managed stream and consumer to the queues, and
+// returns their outcome keys.
+func (c *Controller) enqueueAll() ([]string, error) {
+	streams, err := c.strLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+	consumers, err := c.cnsLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumers: %w", err)
+	}
+
+	keys := make([]string, 0, len(streams)+len(consumers))
+	for _, s := range streams {
+		c.strQueue.Add(objectKey(s.Namespace, s.Name))
+		keys = append(keys, outcomeKey("stream", s.Namespace, s.Name))
+	}
+	for _, cns := range consumers {
+		c.cnsQueue.Add(objectKey(cns.Namespace, cns.Name))
+		keys = append(keys, outcomeKey("consumer", cns.Namespace, cns.Name))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ReconcileAll runs the controller until every managed stream and consumer
+// has been reconciled once successfully or skipped, or timeout has passed,
+// then writes a summary to w. It returns an error unless all of them
+// succeeded or were skipped.
+func (c *Controller) ReconcileAll(w io.Writer, timeout time.Duration) error {
+	c.outcomes = &outcomeTracker{outcomes: make(map[string]error)}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- c.Run() }()
+
+	if !cache.WaitForCacheSync(c.ctx.Done(), c.strSynced, c.cnsSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	keys, err := c.enqueueAll()
+	if err != nil {
+		return err
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tick := time.NewTicker(reconcileAllPollInterval)
+	defer tick.Stop()
+wait:
+	for !c.settled(keys) {
+		select {
+		case err := <-runErr:
+			return err
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-deadline.C:
+			break wait
+		case <-tick.C:
+		}
+	}
+
+	return c.writeReconcileSummary(w, keys)
+}
+
+// settled reports whether the latest reconcile of every key succeeded or was
+// skipped.
+func (c *Controller) settled(keys []string) bool {
+	for _, k := range keys {
+		if _, skipped := c.outcomes.skipReason(k); skipped {
+			continue
+		}
+		if err, ok := c.outcomes.get(k); !ok || err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Controller) writeReconcileSummary(w io.Writer, keys []string) error {
+	var ok, skipped, failed, pending int
+	var details []string
+	for _, k := range keys {
+		err, done := c.outcomes.get(k)
+		reason, isSkipped := c.outcomes.skipReason(k)
+		switch {
+		case isSkipped:
+			skipped++
+			details = append(details, fmt.Sprintf("skipped %s: %s", k, reason))
+		case !done:
+			pending++
+			details = append(details, fmt.Sprintf("pending %s", k))
+		case err != nil:
+			failed++
+			details = append(details, fmt.Sprintf("failed %s: %s", k, err))
+		default:
+			ok++
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "reconciled %d resources: %d ok, %d skipped, %d failed, %d pending\n", len(keys), ok, skipped, failed, pending); err != nil {
+		return err
+	}
+	for _, d := range details {
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	if failed > 0 || pending > 0 {
+		return fmt.Errorf("%d resources failed and %d are pending", failed, pending)
+	}
+	return nil
+}
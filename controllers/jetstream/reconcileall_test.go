@@ -0,0 +1,91 @@
+package jetstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEnqueueAll(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+	defer ctrl.strQueue.ShutDown()
+	defer ctrl.cnsQueue.ShutDown()
+
+	streams := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for _, name := range []string{"orders", "payments"} {
+		if err := streams.Add(&apis.Stream{ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	consumers := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore()
+	if err := consumers.Add(&apis.Consumer{ObjectMeta: k8smeta.ObjectMeta{Namespace: "team", Name: "worker"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := ctrl.enqueueAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"consumer team/worker", "stream default/orders", "stream default/payments"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got=%v; want=%v", keys, want)
+	}
+	if got, want := ctrl.strQueue.Len(), 2; got != want {
+		t.Fatalf("got=%d streams queued; want=%d", got, want)
+	}
+	if got, want := ctrl.cnsQueue.Len(), 1; got != want {
+		t.Fatalf("got=%d consumers queued; want=%d", got, want)
+	}
+	if item, _ := ctrl.cnsQueue.Get(); item != "team/worker" {
+		t.Fatalf("got=%v; want=team/worker", item)
+	}
+}
+
+func TestWriteReconcileSummary(t *testing.T) {
+	t.Parallel()
+
+	ctrl := &Controller{outcomes: &outcomeTracker{outcomes: make(map[string]error)}}
+	ctrl.outcomes.record("stream", "default", "orders", nil)
+	ctrl.outcomes.record("consumer", "default", "worker", errors.New("boom"))
+	ctrl.outcomes.skip("stream", "default", "archive", "paused until 2030-01-01T00:00:00Z")
+	keys := []string{"consumer default/worker", "stream default/archive", "stream default/orders", "stream default/payments"}
+
+	if ctrl.settled(keys) {
+		t.Fatal("expected unsettled resources")
+	}
+
+	var buf bytes.Buffer
+	if err := ctrl.writeReconcileSummary(&buf, keys); err == nil {
+		t.Fatal("expected an error for failed and pending resources")
+	}
+	want := "reconciled 4 resources: 1 ok, 1 skipped, 1 failed, 1 pending\n" +
+		"failed consumer default/worker: boom\n" +
+		"skipped stream default/archive: paused until 2030-01-01T00:00:00Z\n" +
+		"pending stream default/payments\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got=%q; want=%q", got, want)
+	}
+
+	// Skipped resources don't hold the others back.
+	ctrl.outcomes.record("consumer", "default", "worker", nil)
+	ctrl.outcomes.record("stream", "default", "payments", nil)
+	if !ctrl.settled(keys) {
+		t.Fatal("expected settled resources")
+	}
+}
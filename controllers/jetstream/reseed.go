@@ -0,0 +1,217 @@
+package jetstream
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	klog "k8s.io/klog/v2"
+)
+
+// ReseedStreamPath is the admin endpoint path prefix recreating the consumers
+// of a stream, as in POST /reseed/stream/<namespace>/<name>.
+const ReseedStreamPath = "/reseed/stream/"
+
+// reseedResult is what reseeding did to one consumer.
+type reseedResult struct {
+	Name     string `json:"name"`
+	Consumer string `json:"consumer"`
+	Reseeded bool   `json:"reseeded"`
+	StartSeq int    `json:"startSeq,omitempty"`
+	Skipped  string `json:"skipped,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// streamReseed is the outcome of reseeding the consumers of a Stream.
+type streamReseed struct {
+	Namespace string         `json:"namespace"`
+	Name      string         `json:"name"`
+	Stream    string         `json:"stream"`
+	Consumers []reseedResult `json:"consumers"`
+}
+
+// ReseedStream deletes and recreates the consumers of a Stream, as needed
+// after some NATS upgrades. Requests must carry Options.ReseedToken as a
+// bearer token. Not available with CRDConnect.
+func (c *Controller) ReseedStream(w http.ResponseWriter, r *http.Request) {
+	if c.opts.CRDConnect {
+		http.Error(w, "reseeding needs the controller's NATS connection", http.StatusNotImplemented)
+		return
+	}
+	jsmc := c.jsmClient()
+	if jsmc.jm == nil {
+		http.Error(w, "not connected to NATS yet", http.StatusServiceUnavailable)
+		return
+	}
+	c.serveReseedStream(w, r, jsmc)
+}
+
+func (c *Controller) serveReseedStream(w http.ResponseWriter, r *http.Request, jsmc jsmClient) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.reseedAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if c.opts.ReadOnly || c.opts.AuditOnly {
+		http.Error(w, "reseeding is disabled in read-only and audit-only modes", http.StatusForbidden)
+		return
+	}
+	if c.pause.isPaused() {
+		http.Error(w, "the controller is paused", http.StatusServiceUnavailable)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, ReseedStreamPath), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	ns, name := parts[0], parts[1]
+
+	str, err := c.strLister.Streams(ns).Get(name)
+	if k8serrors.IsNotFound(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res, err := c.reseedStream(r.Context(), jsmc, str)
+	code := http.StatusOK
+	if err != nil {
+		klog.Infof("failed to reseed stream %s/%s: %s", ns, name, err)
+		code = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		klog.Infof("failed to write stream reseed: %s", err)
+	}
+}
+
+// reseedAuthorized reports whether r carries the reseed token. Without a
+// token configured no request is.
+func (c *Controller) reseedAuthorized(r *http.Request) bool {
+	if c.opts.ReseedToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.opts.ReseedToken)) == 1
+}
+
+// reseedStream recreates, one at a time and in name order, the consumers of
+// str managed in its namespace. It stops at the first failure, so that a
+// problem with the stream doesn't leave all of its consumers deleted.
+func (c *Controller) reseedStream(ctx context.Context, jsmc jsmClient, str *apis.Stream) (streamReseed, error) {
+	res := streamReseed{
+		Namespace: str.Namespace,
+		Name:      str.Name,
+		Stream:    str.Spec.Name,
+		Consumers: []reseedResult{},
+	}
+	if _, err := jsmc.LoadStream(ctx, str.Spec.Name); err != nil {
+		return res, fmt.Errorf("failed to load stream %q: %w", str.Spec.Name, err)
+	}
+
+	consumers, err := c.cnsLister.Consumers(str.Namespace).List(labels.Everything())
+	if err != nil {
+		return res, fmt.Errorf("failed to list consumers: %w", err)
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+
+	for _, cns := range consumers {
+		// Consumers are matched by their spec as resolved by the
+		// SpecMutator, which is the one they're recreated from.
+		spec, err := c.mutateConsumerSpec(cns)
+		if err != nil {
+			if cns.Spec.StreamName != str.Spec.Name {
+				continue
+			}
+			res.Consumers = append(res.Consumers, reseedResult{Name: cns.Name, Error: err.Error()})
+			return res, err
+		}
+		if spec.StreamName != str.Spec.Name {
+			continue
+		}
+		cr, err := c.reseedConsumer(ctx, jsmc, cns, spec)
+		res.Consumers = append(res.Consumers, cr)
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// reseedConsumer deletes and recreates the consumer of cns from spec, its
+// resolved spec. With recreateFromAckFloor it starts again after its ack floor when there is one,
+// so acknowledged messages aren't redelivered. Other consumers start as their
+// spec says, as a later update would otherwise see their start as drift.
+func (c *Controller) reseedConsumer(ctx context.Context, jsmc jsmClient, cns *apis.Consumer, spec apis.ConsumerSpec) (res reseedResult, err error) {
+	res = reseedResult{Name: cns.Name}
+	defer func() {
+		if err != nil {
+			res.Error = err.Error()
+			c.warningEvent(cns, "ReseedFailed", fmt.Sprintf("Failed to reseed consumer %q: %s", res.Consumer, err))
+		}
+	}()
+
+	if spec.DurableName, err = resolveDurableName(cns); err != nil {
+		return res, err
+	}
+	res.Consumer = spec.DurableName
+
+	switch {
+	case cns.DeletionTimestamp != nil:
+		res.Skipped = "being deleted"
+	case spec.PreventDelete:
+		res.Skipped = "preventDelete is set"
+	}
+	if res.Skipped != "" {
+		c.normalEvent(cns, "SkipReseed", fmt.Sprintf("Skip reseeding consumer %q: %s", spec.DurableName, res.Skipped))
+		return res, nil
+	}
+
+	var floor uint64
+	if spec.RecreateFromAckFloor {
+		var apierr jsmapi.ApiError
+		floor, err = consumerAckFloor(ctx, jsmc, spec)
+		if err != nil && !(errors.As(err, &apierr) && apierr.NotFoundError()) {
+			return res, err
+		}
+	}
+	if floor > 0 {
+		spec.DeliverPolicy = "byStartSequence"
+		spec.OptStartSeq = int(floor + 1)
+		spec.OptStartTime = ""
+		res.StartSeq = spec.OptStartSeq
+	}
+
+	c.normalEvent(cns, "Reseeding", fmt.Sprintf("Reseeding consumer %q on stream %q", spec.DurableName, spec.StreamName))
+	if err := deleteConsumer(ctx, jsmc, spec); err != nil {
+		return res, err
+	}
+	if err := createConsumer(ctx, jsmc, spec); err != nil {
+		return res, err
+	}
+	res.Reseeded = true
+
+	msg := fmt.Sprintf("Reseeded consumer %q on stream %q", spec.DurableName, spec.StreamName)
+	if res.StartSeq > 0 {
+		msg += fmt.Sprintf(", starting at sequence %d after the ack floor", res.StartSeq)
+	}
+	c.normalEvent(cns, "Reseeded", msg)
+	return res, nil
+}
@@ -0,0 +1,250 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestServeReseedStream(t *testing.T) {
+	t.Parallel()
+
+	rec := record.NewFakeRecorder(20)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       rec,
+		ReseedToken:    "secret",
+		// The mutator moves archive off ORDERS and legacy onto it.
+		SpecMutator: streamNameMutator{"archive": "ARCHIVE", "legacy": "ORDERS"},
+	})
+
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	err := informers.Streams().Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "orders"},
+		Spec:       apis.StreamSpec{Name: "ORDERS"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cns := range []*apis.Consumer{
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "shipping"},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "shipping", AckPolicy: "explicit"},
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "billing"},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "billing", AckPolicy: "explicit", RecreateFromAckFloor: true},
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "audit"},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "audit", PreventDelete: true},
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "payments"},
+			Spec:       apis.ConsumerSpec{StreamName: "PAYMENTS", DurableName: "payments"},
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "archive"},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: "archive"},
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "legacy"},
+			Spec:       apis.ConsumerSpec{StreamName: "LEGACY", DurableName: "legacy"},
+		},
+	} {
+		if err := informers.Consumers().Informer().GetStore().Add(cns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// billing has acked up to 41 and resumes from there. shipping has too,
+	// but starts as its spec says without recreateFromAckFloor.
+	billing := &mockConsumer{state: jsmapi.ConsumerInfo{AckFloor: jsmapi.SequenceInfo{Stream: 41}}}
+	shipping := &mockConsumer{state: jsmapi.ConsumerInfo{AckFloor: jsmapi.SequenceInfo{Stream: 41}}}
+	payments := &mockConsumer{}
+	archive := &mockConsumer{}
+	legacy := &mockConsumer{}
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{},
+		consumers: map[string]jsmConsumer{
+			"billing":  billing,
+			"shipping": shipping,
+			"payments": payments,
+			"archive":  archive,
+			"legacy":   legacy,
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	ctrl.serveReseedStream(rr, reseedRequest("secret"), jsmc)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("got=%d; want=%d: %s", got, want, rr.Body)
+	}
+
+	var got streamReseed
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []reseedResult{
+		{Name: "audit", Consumer: "audit", Skipped: "preventDelete is set"},
+		{Name: "billing", Consumer: "billing", Reseeded: true, StartSeq: 42},
+		{Name: "legacy", Consumer: "legacy", Reseeded: true},
+		{Name: "shipping", Consumer: "shipping", Reseeded: true},
+	}
+	if len(got.Consumers) != len(want) {
+		t.Fatalf("got=%+v; want=%+v", got.Consumers, want)
+	}
+	for i := range want {
+		if got.Consumers[i] != want[i] {
+			t.Fatalf("got=%+v; want=%+v", got.Consumers[i], want[i])
+		}
+	}
+	if !billing.deleted || !shipping.deleted || !legacy.deleted || payments.deleted || archive.deleted {
+		t.Fatalf("got billing deleted=%t, shipping deleted=%t, legacy deleted=%t, payments deleted=%t, archive deleted=%t; want only ORDERS consumers deleted",
+			billing.deleted, shipping.deleted, legacy.deleted, payments.deleted, archive.deleted)
+	}
+	if jsmc.newConsumers != 3 {
+		t.Fatalf("got=%d consumers created; want=3", jsmc.newConsumers)
+	}
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	all := strings.Join(events, "\n")
+	for _, want := range []string{
+		`SkipReseed Skip reseeding consumer "audit"`,
+		`Reseeded Reseeded consumer "billing" on stream "ORDERS", starting at sequence 42 after the ack floor`,
+		`Reseeded Reseeded consumer "shipping" on stream "ORDERS"`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("missing event %q in:\n%s", want, all)
+		}
+	}
+}
+
+func TestServeReseedStreamStopsOnFailure(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(20),
+		ReseedToken:    "secret",
+	})
+
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	err := informers.Streams().Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "orders"},
+		Spec:       apis.StreamSpec{Name: "ORDERS"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		err := informers.Consumers().Informer().GetStore().Add(&apis.Consumer{
+			ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       apis.ConsumerSpec{StreamName: "ORDERS", DurableName: name},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := &mockConsumer{}
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{},
+		consumers: map[string]jsmConsumer{
+			"a": &mockConsumer{deleteErr: errors.New("boom")},
+			"b": b,
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	ctrl.serveReseedStream(rr, reseedRequest("secret"), jsmc)
+	if got, want := rr.Code, http.StatusBadGateway; got != want {
+		t.Fatalf("got=%d; want=%d: %s", got, want, rr.Body)
+	}
+	if b.deleted || jsmc.newConsumers != 0 {
+		t.Fatal("got the next consumer reseeded; want reseeding stopped at the failure")
+	}
+}
+
+func TestServeReseedStreamRefused(t *testing.T) {
+	t.Parallel()
+
+	for name, tt := range map[string]struct {
+		opts   Options
+		token  string
+		paused bool
+		want   int
+	}{
+		"read-only":   {opts: Options{ReadOnly: true, ReseedToken: "secret"}, token: "secret", want: http.StatusForbidden},
+		"audit-only":  {opts: Options{AuditOnly: true, ReseedToken: "secret"}, token: "secret", want: http.StatusForbidden},
+		"paused":      {opts: Options{ReseedToken: "secret"}, token: "secret", paused: true, want: http.StatusServiceUnavailable},
+		"no token":    {opts: Options{ReseedToken: "secret"}, want: http.StatusUnauthorized},
+		"wrong token": {opts: Options{ReseedToken: "secret"}, token: "guess", want: http.StatusUnauthorized},
+		"unset token": {token: "secret", want: http.StatusUnauthorized},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := tt.opts
+			opts.Ctx = context.Background()
+			opts.KubeIface = k8sclientsetfake.NewSimpleClientset()
+			opts.JetstreamIface = clientsetfake.NewSimpleClientset()
+			opts.Recorder = record.NewFakeRecorder(10)
+			ctrl := NewController(opts)
+			ctrl.pause.set(tt.paused)
+
+			jsmc := &mockJsmClient{loadStream: &mockStream{}}
+			rr := httptest.NewRecorder()
+			ctrl.serveReseedStream(rr, reseedRequest(tt.token), jsmc)
+			if rr.Code != tt.want {
+				t.Fatalf("got=%d; want=%d: %s", rr.Code, tt.want, rr.Body)
+			}
+			if jsmc.loadStreamCalls != 0 {
+				t.Fatal("got NATS calls; want none")
+			}
+		})
+	}
+}
+
+// reseedRequest returns a request reseeding default/orders with token.
+func reseedRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/reseed/stream/default/orders", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// streamNameMutator moves the consumers it names to another stream.
+type streamNameMutator map[string]string
+
+func (streamNameMutator) MutateStream(str *apis.Stream, spec *apis.StreamSpec) error {
+	return nil
+}
+
+func (m streamNameMutator) MutateConsumer(cns *apis.Consumer, spec *apis.ConsumerSpec) error {
+	if stream, ok := m[cns.Name]; ok {
+		spec.StreamName = stream
+	}
+	return nil
+}
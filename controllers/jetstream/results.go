@@ -0,0 +1,123 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// resultsSubjectPrefix prefixes the subjects reconcile results are published
+// on, followed by the kind, namespace and name of the resource.
+const resultsSubjectPrefix = "nack.events"
+
+// resultPublisher publishes messages without waiting for them to be
+// delivered, as done by nats.Conn.
+type resultPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// reconcileResult is the message published after each reconcile.
+type reconcileResult struct {
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace"`
+	Name       string    `json:"name"`
+	Generation int64     `json:"generation"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// resultSubject returns the subject results for a resource are published on.
+// Dots in the namespace or name are replaced so that each stays one token.
+func resultSubject(kind, ns, name string) string {
+	return fmt.Sprintf("%s.%s.%s.%s", resultsSubjectPrefix, kind,
+		strings.ReplaceAll(ns, ".", "_"), strings.ReplaceAll(name, ".", "_"))
+}
+
+// publishResult publishes the outcome of reconciling o when PublishResults is
+// enabled. It's best-effort, failures are only logged.
+func (c *Controller) publishResult(kind string, o k8smeta.Object, err error) {
+	if c.results == nil {
+		return
+	}
+
+	res := reconcileResult{
+		Kind:       kind,
+		Namespace:  o.GetNamespace(),
+		Name:       o.GetName(),
+		Generation: o.GetGeneration(),
+		Success:    err == nil,
+		Time:       time.Now().UTC(),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	data, merr := json.Marshal(res)
+	if merr != nil {
+		klog.Infof("failed to encode reconcile result of %s %s/%s: %s", kind, res.Namespace, res.Name, merr)
+		return
+	}
+
+	subj := resultSubject(kind, res.Namespace, res.Name)
+	if perr := c.results.Publish(subj, data); perr != nil {
+		klog.V(2).Infof("failed to publish reconcile result on %s: %s", subj, perr)
+	}
+}
+
+// ReconcileAction is the action a reconcile took on a resource.
+type ReconcileAction string
+
+const (
+	ActionCreated ReconcileAction = "created"
+	ActionUpdated ReconcileAction = "updated"
+	ActionDeleted ReconcileAction = "deleted"
+	ActionNoop    ReconcileAction = "noop"
+	ActionErrored ReconcileAction = "errored"
+)
+
+// ReconcileResult is the outcome of reconciling a resource with
+// ReconcileStream or ReconcileConsumer.
+type ReconcileResult struct {
+	Action ReconcileAction
+	// Config is the configuration sent to NATS when the resource was created
+	// or updated, a jsmapi.StreamConfig or jsmapi.ConsumerConfig.
+	Config interface{}
+}
+
+type resultKey struct{}
+
+// withResult returns a context carrying res, for the reconcile to record
+// what it did.
+func withResult(ctx context.Context, res *ReconcileResult) context.Context {
+	if res == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, resultKey{}, res)
+}
+
+// resultFrom returns the result carried by ctx, nil if there's none.
+func resultFrom(ctx context.Context) *ReconcileResult {
+	res, _ := ctx.Value(resultKey{}).(*ReconcileResult)
+	return res
+}
+
+// ReconcileStream reconciles the Stream resource ns/name once, outside of the
+// work queue, and returns what was done.
+func (c *Controller) ReconcileStream(ns, name string) (ReconcileResult, error) {
+	res := ReconcileResult{Action: ActionNoop}
+	err := c.reconcileStream(ns, name, c.jsmClient(), &res)
+	return res, err
+}
+
+// ReconcileConsumer reconciles the Consumer resource ns/name once, outside of
+// the work queue, and returns what was done.
+func (c *Controller) ReconcileConsumer(ns, name string) (ReconcileResult, error) {
+	res := ReconcileResult{Action: ActionNoop}
+	err := c.reconcileConsumer(ns, name, c.jsmClient(), &res)
+	return res, err
+}
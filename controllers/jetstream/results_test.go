@@ -0,0 +1,186 @@
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+type published struct {
+	subject string
+	data    []byte
+}
+
+type mockPublisher struct {
+	msgs []published
+	err  error
+}
+
+func (p *mockPublisher) Publish(subject string, data []byte) error {
+	p.msgs = append(p.msgs, published{subject, data})
+	return p.err
+}
+
+func TestProcessStreamPublishesResult(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+		PublishResults: true,
+	})
+	pub := &mockPublisher{err: errors.New("not connected")}
+	ctrl.results = pub
+
+	ns, name := "default", "my.orders"
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    "orders",
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{
+			config: jsmapi.StreamConfig{Name: "orders", Storage: jsmapi.MemoryStorage, MaxAge: time.Hour},
+		},
+	}
+	// A failed publish doesn't fail the reconcile.
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(pub.msgs), 1; got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	if got, want := pub.msgs[0].subject, "nack.events.stream.default.my_orders"; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+	var res reconcileResult
+	if err := json.Unmarshal(pub.msgs[0].data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Success || res.Error != "" || res.Kind != "stream" || res.Name != name || res.Generation != 2 {
+		t.Fatalf("got=%+v", res)
+	}
+}
+
+func TestReconcileStreamResult(t *testing.T) {
+	t.Parallel()
+
+	existing := &mockStream{
+		config: jsmapi.StreamConfig{Name: "orders", Storage: jsmapi.MemoryStorage, MaxAge: time.Hour},
+	}
+	tests := map[string]struct {
+		observed   int64
+		jsmc       *mockJsmClient
+		wantAction ReconcileAction
+		wantConfig bool
+	}{
+		"created": {
+			jsmc:       &mockJsmClient{loadStreamErr: jsmapi.ApiError{Code: 404}},
+			wantAction: ActionCreated,
+			wantConfig: true,
+		},
+		"updated": {
+			observed:   1,
+			jsmc:       &mockJsmClient{loadStream: existing},
+			wantAction: ActionUpdated,
+			wantConfig: true,
+		},
+		"noop": {
+			observed:   2,
+			jsmc:       &mockJsmClient{loadStream: existing},
+			wantAction: ActionNoop,
+		},
+		"errored": {
+			jsmc:       &mockJsmClient{loadStreamErr: errors.New("boom")},
+			wantAction: ActionErrored,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       record.NewFakeRecorder(10),
+			})
+
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+			err := informer.Informer().GetStore().Add(&apis.Stream{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  "default",
+					Name:       "orders",
+					Generation: 2,
+				},
+				Spec: apis.StreamSpec{
+					Name:    "orders",
+					MaxAge:  "1h",
+					Storage: "memory",
+				},
+				Status: apis.Status{
+					ObservedGeneration: tt.observed,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				return true, a.(k8stesting.UpdateAction).GetObject(), nil
+			})
+
+			res := ReconcileResult{Action: ActionNoop}
+			err = ctrl.reconcileStream("default", "orders", tt.jsmc, &res)
+			if (err != nil) != (tt.wantAction == ActionErrored) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Action != tt.wantAction {
+				t.Fatalf("got=%s; want=%s", res.Action, tt.wantAction)
+			}
+			config, ok := res.Config.(jsmapi.StreamConfig)
+			if ok != tt.wantConfig {
+				t.Fatalf("got=%+v; want config=%v", res.Config, tt.wantConfig)
+			}
+			if ok && (config.Name != "orders" || config.MaxAge != time.Hour) {
+				t.Fatalf("got=%+v; want the resolved stream config", config)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package jetstream
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// srvNameRe matches SRV names of the _service._proto.name form.
+var srvNameRe = regexp.MustCompile(`^_[A-Za-z0-9-]+\._(tcp|udp)\.[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.?$`)
+
+// srvResolver looks up SRV records, as done by net.Resolver.
+type srvResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// validateSRVName checks name is an SRV name like _nats._tcp.example.com.
+func validateSRVName(name string) error {
+	if !srvNameRe.MatchString(name) {
+		return fmt.Errorf("invalid SRV name %q, want _service._proto.name", name)
+	}
+	return nil
+}
+
+// serversFromSRV resolves the SRV name into NATS server URLs, ordered by
+// priority and then weight.
+func serversFromSRV(ctx context.Context, r srvResolver, name string) ([]string, error) {
+	if err := validateSRVName(name); err != nil {
+		return nil, err
+	}
+
+	_, records, err := r.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve SRV %q: %w", name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV records found for %q", name)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+	servers := make([]string, 0, len(records))
+	for _, rec := range records {
+		host := strings.TrimSuffix(rec.Target, ".")
+		servers = append(servers, fmt.Sprintf("nats://%s", net.JoinHostPort(host, fmt.Sprint(rec.Port))))
+	}
+	return servers, nil
+}
+
+// srvDialer resolves the SRV name again on every connect and reconnect, and
+// dials the first server it lists if the one NATS picked from its pool has
+// since been removed from the records.
+type srvDialer struct {
+	name     string
+	resolver srvResolver
+	dialer   net.Dialer
+}
+
+func (d *srvDialer) Dial(network, address string) (net.Conn, error) {
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+
+	servers, err := serversFromSRV(ctx, d.resolver, d.name)
+	if err != nil {
+		klog.Infof("failed to refresh NATS servers, dialing %s: %s", address, err)
+		return d.dialer.Dial(network, address)
+	}
+	for _, s := range servers {
+		if strings.TrimPrefix(s, "nats://") == address {
+			return d.dialer.Dial(network, address)
+		}
+	}
+	return d.dialer.Dial(network, strings.TrimPrefix(servers[0], "nats://"))
+}
@@ -0,0 +1,92 @@
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+type fakeResolver struct {
+	records []*net.SRV
+	err     error
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return name, r.records, r.err
+}
+
+func TestServersFromSRV(t *testing.T) {
+	t.Parallel()
+
+	r := &fakeResolver{
+		records: []*net.SRV{
+			{Target: "nats-2.nats.default.svc.", Port: 4222, Priority: 20, Weight: 10},
+			{Target: "nats-0.nats.default.svc.", Port: 4222, Priority: 10, Weight: 5},
+			{Target: "nats-1.nats.default.svc.", Port: 4223, Priority: 10, Weight: 50},
+		},
+	}
+	got, err := serversFromSRV(context.Background(), r, "_nats._tcp.nats.default.svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"nats://nats-1.nats.default.svc:4223",
+		"nats://nats-0.nats.default.svc:4222",
+		"nats://nats-2.nats.default.svc:4222",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v; want=%v", got, want)
+	}
+
+	for _, name := range []string{"", "nats.default.svc", "_nats.nats.default.svc", "_nats._sctp.example.com"} {
+		if _, err := serversFromSRV(context.Background(), r, name); err == nil {
+			t.Errorf("expected error for SRV name %q", name)
+		}
+	}
+
+	if _, err := serversFromSRV(context.Background(), &fakeResolver{}, "_nats._tcp.example.com"); err == nil {
+		t.Error("expected error without SRV records")
+	}
+	if _, err := serversFromSRV(context.Background(), &fakeResolver{err: errors.New("timeout")}, "_nats._tcp.example.com"); err == nil {
+		t.Error("expected error failing to resolve")
+	}
+}
+
+func TestSRVDialer(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	d := &srvDialer{
+		name: "_nats._tcp.example.com",
+		resolver: &fakeResolver{
+			records: []*net.SRV{{Target: "127.0.0.1.", Port: uint16(port)}},
+		},
+	}
+
+	// A server that left the records is swapped for a current one.
+	conn, err := d.Dial("tcp", "127.0.0.2:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got, want := conn.RemoteAddr().String(), ln.Addr().String(); got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+}
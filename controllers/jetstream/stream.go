@@ -15,11 +15,16 @@ package jetstream
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	jsm "github.com/nats-io/jsm.go"
@@ -31,35 +36,94 @@ import (
 	k8sapi "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/util/retry"
 	klog "k8s.io/klog/v2"
 )
 
 func (c *Controller) runStreamQueue() {
 	for {
-		processQueueNext(c.strQueue, &realJsmClient{jm: c.jm}, c.processStream)
+		processQueueNext(c.strQueue, c.jsmClient(), c.whenResumed(c.processStream))
 	}
 }
 
-func (c *Controller) processStream(ns, name string, jsmc jsmClient) (err error) {
+func (c *Controller) processStream(ns, name string, jsmc jsmClient) error {
+	return c.reconcileStream(ns, name, jsmc, nil)
+}
+
+// reconcileStream reconciles the stream ns/name, recording what was done in res
+// unless it's nil.
+func (c *Controller) reconcileStream(ns, name string, jsmc jsmClient, res *ReconcileResult) (err error) {
+	defer func() {
+		if err != nil && res != nil {
+			res.Action = ActionErrored
+		}
+	}()
+
 	str, err := c.strLister.Streams(ns).Get(name)
 	if err != nil && k8serrors.IsNotFound(err) {
+		if c.requeueNotFound(c.strQueue, "stream", ns, name) {
+			klog.V(4).Infof("stream %s/%s not found, requeued", ns, name)
+		}
 		return nil
 	} else if err != nil {
 		return err
 	}
+	c.notFound.forget(outcomeKey("stream", ns, name))
+
+	until, paused, err := pausedUntil(str, time.Now())
+	if err != nil {
+		c.warningEvent(str, "InvalidAnnotation", err.Error())
+	} else if paused {
+		c.normalEvent(str, "PausedUntil", fmt.Sprintf("Reconcile of stream %q paused until %s", str.Spec.Name, until.Format(time.RFC3339)))
+		c.strQueue.AddAfter(fmt.Sprintf("%s/%s", ns, name), time.Until(until))
+		c.outcomes.skip("stream", ns, name, fmt.Sprintf("paused until %s", until.Format(time.RFC3339)))
+		return nil
+	}
+	// The ConsumersReady condition follows the Consumers of the stream, so
+	// it's kept up to date even when the stream itself is unchanged.
+	if str, err = c.updateConsumersReady(c.ctx, str); err != nil {
+		return err
+	}
+	if str.Spec.SubjectsFrom == nil && c.unchangedSinceReconcile(str, str.Spec, str.Status) {
+		klog.V(4).Infof("stream %s/%s unchanged since its last reconcile, skipped", ns, name)
+		return nil
+	}
 
-	return c.processStreamObject(str, jsmc)
+	ctx, cancel := c.reconcileContext()
+	defer cancel()
+	ctx = withResult(ctx, res)
+	err = c.processStreamObject(ctx, str, jsmc)
+	c.warnReconcileTimeout(str, err)
+	err = c.reportConnectError(str, outcomeKey("stream", ns, name), err)
+	c.waitingForLeader(str, err)
+	c.publishResult("stream", str, err)
+	c.outcomes.record("stream", ns, name, err)
+	c.metrics.record(ns, err)
+
+	// The resource may have been edited again while it was reconciled, in
+	// which case the latest spec is reconciled too.
+	if err == nil {
+		if cur, gerr := c.strLister.Streams(ns).Get(name); gerr == nil && specHash(cur.Spec) != specHash(str.Spec) {
+			klog.V(2).Infof("stream %s/%s changed during reconcile, requeued", ns, name)
+			c.strQueue.Add(objectKey(ns, name))
+		}
+		c.requeueAfterSuccess(c.strQueue, str, c.opts.StreamRequeueInterval)
+	}
+	return err
 }
 
-func (c *Controller) processStreamObject(str *apis.Stream, jsmc jsmClient) (err error) {
+func (c *Controller) processStreamObject(ctx context.Context, str *apis.Stream, jsmc jsmClient) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("failed to process stream: %w", err)
 		}
 	}()
 
-	spec := str.Spec
+	spec, err := c.mutateStreamSpec(str)
+	if err != nil {
+		return err
+	}
 	ifc := c.ji.Streams(str.Namespace)
 	ns := str.Namespace
 	readOnly := c.opts.ReadOnly
@@ -69,6 +133,7 @@ func (c *Controller) processStreamObject(str *apis.Stream, jsmc jsmClient) (err
 		remoteClientKey  string
 		remoteRootCA     string
 		accServers       []string
+		accContextOpts   []nats.Option
 		acc              *apis.Account
 		accUserCreds     string
 	)
@@ -86,7 +151,7 @@ func (c *Controller) processStreamObject(str *apis.Stream, jsmc jsmClient) (err
 		// Lookup the TLS secrets
 		if acc.Spec.TLS != nil && acc.Spec.TLS.Secret != nil {
 			secretName := acc.Spec.TLS.Secret.Name
-			secret, err := c.ki.Secrets(ns).Get(c.ctx, secretName, k8smeta.GetOptions{})
+			secret, err := c.getSecret(ctx, ns, secretName)
 			if err != nil {
 				return err
 			}
@@ -109,49 +174,92 @@ func (c *Controller) processStreamObject(str *apis.Stream, jsmc jsmClient) (err
 		}
 		// Lookup the UserCredentials.
 		if acc.Spec.Creds != nil {
-			secretName := acc.Spec.Creds.Secret.Name
-			secret, err := c.ki.Secrets(ns).Get(c.ctx, secretName, k8smeta.GetOptions{})
+			accUserCreds, err = c.getCreds(ctx, ns, spec.Account, acc.Spec.Creds)
 			if err != nil {
 				return err
 			}
-
-			// Write the user credentials to the cache dir.
-			accDir := filepath.Join(c.cacheDir, ns, spec.Account)
-			if err := os.MkdirAll(accDir, 0755); err != nil {
+		}
+		// Lookup the NATS context.
+		if acc.Spec.Context != nil {
+			servers, opts, _, err := c.loadAccountContext(ns, spec.Account, acc.Spec.Context)
+			if err != nil {
 				return err
 			}
-			for k, v := range secret.Data {
-				if k == acc.Spec.Creds.File {
-					accUserCreds = filepath.Join(c.cacheDir, ns, spec.Account, k)
-					if err := os.WriteFile(filepath.Join(accDir, k), v, 0644); err != nil {
-						return err
-					}
-				}
-			}
+			accServers = append(accServers, servers...)
+			accContextOpts = opts
 		}
 	}
 
+	if err := c.clusterLimiter.wait(ctx, c.clusterServers(spec.Servers, accServers)); err != nil {
+		return err
+	}
+
 	defer func() {
 		if err == nil {
 			return
 		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = &reconcileTimeoutError{timeout: c.opts.MaxReconcileDuration, err: err}
+		}
+		// A leader election isn't a failure of the resource, it's retried
+		// without marking it errored.
+		if classifyError(err) == errKindNoLeader {
+			return
+		}
 
-		if _, serr := setStreamErrored(c.ctx, str, ifc, err); serr != nil {
+		if _, serr := setStreamErrored(c.ctx, str, ifc, err, c.opts.MaxConditionMessageLength); serr != nil {
 			err = fmt.Errorf("%s: %w", err, serr)
 		}
 	}()
 
+	// subjectsFromVersion is the version of the subjectsFrom ConfigMap the
+	// subjects were resolved from.
+	var subjectsFromVersion string
+	if str.GetDeletionTimestamp() == nil {
+		if spec.Subjects, subjectsFromVersion, err = c.subjectsFrom(str.Namespace, spec); err != nil {
+			c.warningEvent(str, "SubjectsFromFailed", err.Error())
+			return err
+		}
+		if err := validateName("stream name", spec.Name); err != nil {
+			c.warningEvent(str, "InvalidName", err.Error())
+			return err
+		}
+		if err := validateSubjects(spec.Subjects); err != nil {
+			c.warningEvent(str, "InvalidSubject", fmt.Sprintf("Stream %q has an %s", spec.Name, err))
+			return err
+		}
+		if err := validateRepublish(spec); err != nil {
+			c.warningEvent(str, "InvalidRepublish", fmt.Sprintf("Stream %q has an %s", spec.Name, err))
+			return err
+		}
+		var dropped []string
+		if spec.Subjects, dropped = dedupeSubjects(spec.Subjects); len(dropped) > 0 {
+			c.normalEvent(str, "DuplicateSubjects", fmt.Sprintf("Ignoring duplicate subjects %v of stream %q", dropped, spec.Name))
+		}
+		if err := c.checkStreamNameConflict(str, spec.Name); err != nil {
+			return err
+		}
+	}
+
 	type operator func(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err error)
 
+	// domain is the JetStream domain the stream was last managed in.
+	domain := str.Status.Domain
+
 	natsClientUtil := func(op operator) error {
 		servers := spec.Servers
 		if c.opts.CRDConnect {
 			// Create a new client
 			opts := make([]nats.Option, 0)
 			opts = append(opts, nats.Name(fmt.Sprintf("%s-str-%s-%d", c.opts.NATSClientName, spec.Name, str.Generation)))
-			// Use JWT/NKEYS based credentials if present.
-			if spec.Creds != "" {
-				opts = append(opts, nats.UserCredentials(spec.Creds))
+			// Use JWT/NKEYS based credentials if present, or the default
+			// credentials if the stream has none of its own.
+			creds, err := c.resourceCreds(spec.Creds, spec.Nkey != "" || accUserCreds != "" || accContextOpts != nil)
+			if err != nil {
+				return err
+			}
+			if creds != "" {
+				opts = append(opts, nats.UserCredentials(creds))
 			} else if spec.Nkey != "" {
 				opt, err := nats.NkeyOptionFromSeed(spec.Nkey)
 				if err != nil {
@@ -177,104 +285,470 @@ func (c *Controller) processStreamObject(str *apis.Stream, jsmc jsmClient) (err
 				opts = append(opts, nats.RootCAs(spec.TLS.RootCAs...))
 			}
 
+			opts = append(opts, accContextOpts...)
 			opts = append(opts, nats.MaxReconnects(-1))
 
+			extraOpts, err := getNATSOptions(spec.NATSOptions)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, extraOpts...)
+
+			release, err := c.acquireConn(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
 			natsServers := strings.Join(append(servers, accServers...), ",")
 			newNc, err := nats.Connect(natsServers, opts...)
 			if err != nil {
-				return fmt.Errorf("failed to connect to nats-servers(%s): %w", natsServers, err)
+				return &connectError{servers: natsServers, err: err}
 			}
+			// Deferred after release, so the connection is closed before
+			// its slot is given back, whether the operation failed or not.
+			newJsmc := &realJsmClient{nc: newNc}
+			defer newJsmc.Close()
 
 			c.normalEvent(str, "Connecting", "Connecting to new nats-servers")
-			newJm, err := jsm.New(newNc)
+			newJsmc.jm, newJsmc.domain, err = c.newJsmManager(newNc)
 			if err != nil {
 				return err
 			}
-			newJsmc := &realJsmClient{nc: newNc, jm: newJm}
 
-			if err := op(c.ctx, newJsmc, spec); err != nil {
+			if err := op(ctx, newJsmc, spec); err != nil {
 				return err
 			}
-			newJsmc.Close()
+			domain = newJsmc.Domain()
 		} else {
-			if err := op(c.ctx, jsmc, spec); err != nil {
+			if err := op(ctx, jsmc, spec); err != nil {
 				return err
 			}
+			domain = jsmc.Domain()
 		}
 		return nil
 	}
 
 	deleteOK := str.GetDeletionTimestamp() != nil
-	newGeneration := str.Generation != str.Status.ObservedGeneration
+	newGeneration := str.Generation != str.Status.ObservedGeneration ||
+		(str.Status.ObservedSpecHash != "" && str.Status.ObservedSpecHash != specHash(str.Spec)) ||
+		subjectsFromVersion != str.Status.SubjectsFromVersion
+	cacheKey := fmt.Sprintf("%s/%s", str.Namespace, str.Name)
 	strOK := true
-	err = natsClientUtil(streamExists)
-	var apierr jsmapi.ApiError
-	if errors.As(err, &apierr) && apierr.NotFoundError() {
-		strOK = false
-	} else if err != nil {
-		return err
+	if deleteOK || !c.strCache.hit(cacheKey, str.Generation) {
+		err = natsClientUtil(streamExists)
+		var apierr jsmapi.ApiError
+		if errors.As(err, &apierr) && apierr.NotFoundError() {
+			strOK = false
+		} else if err != nil {
+			if classifyError(err) == errKindJetStreamNotEnabled {
+				c.warningEvent(str, "JetStreamNotEnabled", "JetStream not enabled for this account")
+			}
+			return err
+		} else {
+			c.strCache.observe(cacheKey, str.Generation)
+		}
 	}
 	updateOK := (strOK && !deleteOK && newGeneration)
 	createOK := (!strOK && !deleteOK && newGeneration)
 
+	// withTargets returns str with the state of its sources and mirror, as
+	// reported by NATS, to be recorded in its status.
+	withTargets := func() *apis.Stream {
+		observed := str.DeepCopy()
+		observed.Status.Targets = nil
+		if spec.Mirror == nil && len(spec.Sources) == 0 {
+			return observed
+		}
+
+		err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.StreamSpec) (err error) {
+			observed.Status.Targets, err = streamTargets(ctx, jc, spec, c.opts.MaxConditionMessageLength)
+			return err
+		})
+		if err != nil {
+			klog.Infof("failed to get sources of stream %q: %s", spec.Name, err)
+			return str
+		}
+		// Noop reconciles refresh the targets too, so only sources that
+		// weren't already errored are warned about.
+		for i, t := range observed.Status.Targets {
+			if t.Status != k8sapi.ConditionFalse {
+				continue
+			}
+			if prev := str.Status.Targets; i < len(prev) && prev[i].Name == t.Name && prev[i].Status == k8sapi.ConditionFalse {
+				continue
+			}
+			c.warningEvent(str, "SourceErrored", fmt.Sprintf("Source %q of stream %q errored: %s", t.Name, spec.Name, t.Message))
+		}
+		return observed
+	}
+
+	// withSubjectsFrom returns s with the version of the subjectsFrom
+	// ConfigMap that was applied recorded in its status.
+	withSubjectsFrom := func(s *apis.Stream) *apis.Stream {
+		if s.Status.SubjectsFromVersion == subjectsFromVersion {
+			return s
+		}
+		observed := s.DeepCopy()
+		observed.Status.SubjectsFromVersion = subjectsFromVersion
+		return observed
+	}
+
+	// withDomain returns s with the JetStream domain recorded in its status.
+	withDomain := func(s *apis.Stream) *apis.Stream {
+		if s.Status.Domain == domain {
+			return s
+		}
+		observed := s.DeepCopy()
+		observed.Status.Domain = domain
+		return observed
+	}
+
+	// withState returns s with the live state of the stream refreshed in its
+	// status, when due.
+	withState := func(s *apis.Stream) *apis.Stream {
+		now := time.Now()
+		if !c.liveStateDue(s.Status.State, now) {
+			return s
+		}
+
+		var info *jsmapi.StreamInfo
+		err := natsClientUtil(func(ctx context.Context, jc jsmClient, spec apis.StreamSpec) error {
+			js, err := jc.LoadStream(ctx, spec.Name)
+			if err != nil {
+				return err
+			}
+			info, err = js.LatestInformation()
+			return err
+		})
+		if err != nil {
+			klog.Infof("failed to get state of stream %q: %s", spec.Name, err)
+			return s
+		}
+		observed := s.DeepCopy()
+		observed.Status.State = &apis.LiveState{
+			Messages:     info.State.Msgs,
+			Bytes:        info.State.Bytes,
+			NearCapacity: c.nearCapacity(info),
+			RefreshedAt:  now.UTC().Format(time.RFC3339),
+		}
+		// Only warn as the stream crosses the threshold, not on every refresh.
+		if observed.Status.State.NearCapacity && (s.Status.State == nil || !s.Status.State.NearCapacity) {
+			c.warningEvent(s, "NearCapacity", fmt.Sprintf("Stream %q holds %d of its %d max bytes",
+				spec.Name, info.State.Bytes, info.Config.MaxBytes))
+		}
+		if !s.Status.DiscardNewFull && discardNewFull(info) {
+			observed.Status.DiscardNewFull = true
+			c.normalEvent(s, "DiscardNewFull", fmt.Sprintf("Stream %q is full with %d of its %d max bytes and discards new messages, "+
+				"publishes to it fail until messages are removed or maxBytes is raised", spec.Name, info.State.Bytes, info.Config.MaxBytes))
+		}
+		return observed
+	}
+
 	switch {
 	case createOK:
 		if readOnly {
 			c.normalEvent(str, "SkipCreate", fmt.Sprintf("Skip creating stream %q", spec.Name))
 			return nil
 		}
+		if err := c.checkStreamQuota(str); err != nil {
+			return err
+		}
 		c.normalEvent(str, "Creating", fmt.Sprintf("Creating stream %q", spec.Name))
+		c.checkRepublishExported(str, acc)
+		c.strCache.invalidate(cacheKey)
 		if err := natsClientUtil(createStream); err != nil {
+			c.warnUnsupportedFeature(str, err)
 			return err
 		}
 
-		if _, err := setStreamOK(c.ctx, str, ifc); err != nil {
+		if _, err := setStreamOK(ctx, withSubjectsFrom(withDomain(withState(withTargets()))), ifc); err != nil {
 			return err
 		}
+		if c.opts.RecordLastAppliedConfig {
+			// The spec as resolved, with the SpecMutator, subjectsFrom and
+			// deduped subjects applied, is what was sent to NATS.
+			config, err := streamCreateConfig(spec)
+			if err != nil {
+				return err
+			}
+			if err := setStreamLastApplied(ctx, str, config, ifc); err != nil {
+				return err
+			}
+		}
+		if c.opts.ManagedByLabel {
+			if err := setStreamManagedBy(ctx, str, ifc); err != nil {
+				return err
+			}
+		}
 		c.normalEvent(str, "Created", fmt.Sprintf("Created stream %q", spec.Name))
+		c.adviseRetentionLimits(str, spec)
+		recordStreamResult(ctx, ActionCreated, spec)
 	case updateOK:
 		if str.Spec.PreventUpdate || readOnly {
-			c.normalEvent(str, "SkipUpdate", fmt.Sprintf("Skip updating stream %q", spec.Name))
-			if _, err := setStreamOK(c.ctx, str, ifc); err != nil {
+			c.noopEvent(str, "SkipUpdate", fmt.Sprintf("Skip updating stream %q", spec.Name))
+			if _, err := setStreamOK(ctx, str, ifc); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		c.checkRepublishExported(str, acc)
+		c.strCache.invalidate(cacheKey)
+		var changes streamChanges
+		var recreate bool
+		update := func(ctx context.Context, jc jsmClient, spec apis.StreamSpec) error {
+			js, err := jc.LoadStream(ctx, spec.Name)
+			if err != nil {
+				return fmt.Errorf("failed to update stream %q: %w", spec.Name, err)
+			}
+
+			// The storage type of a stream can't change in place, the stream
+			// is only recreated when it's been explicitly allowed to lose its
+			// messages.
+			var storageFrom jsmapi.StorageType
+			storageFrom, recreate = storageChange(js, spec)
+			if !recreate {
+				c.normalEvent(str, "Updating", fmt.Sprintf("Updating stream %q", spec.Name))
+				changes, err = updateStream(ctx, jc, js, spec)
+				return err
+			}
+
+			from, to := strings.ToLower(storageFrom.String()), strings.ToLower(getStorage(spec.Storage).String())
+			switch {
+			case str.Annotations[recreateOnStorageChangeAnnotation] != "true":
+				c.warningEvent(str, "StorageChangeBlocked", fmt.Sprintf("Storage of stream %q can't change from %s to %s in place, "+
+					"annotate it with %s=true to delete and recreate it, losing its messages", spec.Name, from, to, recreateOnStorageChangeAnnotation))
+				return fmt.Errorf("storage of stream %q can't change from %s to %s in place", spec.Name, from, to)
+			case spec.PreventDelete:
+				c.warningEvent(str, "StorageChangeBlocked", fmt.Sprintf("Storage of stream %q can't change from %s to %s with preventDelete set",
+					spec.Name, from, to))
+				return fmt.Errorf("stream %q can't be recreated to change its storage with preventDelete set", spec.Name)
+			}
+			c.warningEvent(str, "Recreating", fmt.Sprintf("Deleting and recreating stream %q to change its storage from %s to %s: "+
+				"all of its messages are lost and its consumers are recreated, back it up first to keep them", spec.Name, from, to))
+			config, err := recreateStream(ctx, jc, js, spec)
+			if err != nil {
 				return err
 			}
+			changes.applied = &config
 			return nil
 		}
-		c.normalEvent(str, "Updating", fmt.Sprintf("Updating stream %q", spec.Name))
-		if err := natsClientUtil(updateStream); err != nil {
+		if err := natsClientUtil(update); err != nil {
+			c.warnUnsupportedFeature(str, err)
+			if len(changes.removedSubjects) > 0 {
+				// The server may refuse to drop subjects, for instance ones
+				// that still have messages in the stream.
+				c.warningEvent(str, "SubjectsRejected", fmt.Sprintf("Failed to remove subjects %v from stream %q: %s",
+					changes.removedSubjects, spec.Name, err))
+			}
 			return err
 		}
+		if len(changes.addedSubjects) > 0 || len(changes.removedSubjects) > 0 {
+			c.normalEvent(str, "SubjectsChanged", fmt.Sprintf("Changed subjects of stream %q, added %v, removed %v",
+				spec.Name, changes.addedSubjects, changes.removedSubjects))
+		}
+		for _, name := range changes.added {
+			c.normalEvent(str, "SourceAdded", fmt.Sprintf("Added source %q to stream %q", name, spec.Name))
+		}
+		for _, name := range changes.removed {
+			c.normalEvent(str, "SourceRemoved", fmt.Sprintf("Removed source %q from stream %q", name, spec.Name))
+		}
+		if p := changes.placement; p != nil {
+			c.normalEvent(str, "Moving", fmt.Sprintf("Moving stream %q to cluster %q with tags %v", spec.Name, p.Cluster, p.Tags))
+		}
 
-		if _, err := setStreamOK(c.ctx, str, ifc); err != nil {
+		if _, err := setStreamOK(ctx, withSubjectsFrom(withDomain(withState(withTargets()))), ifc); err != nil {
 			return err
 		}
-		c.normalEvent(str, "Updated", fmt.Sprintf("Updated stream %q", spec.Name))
+		if c.opts.RecordLastAppliedConfig && changes.applied != nil {
+			// The config merged onto the stream, or the one it was recreated
+			// with, is what was sent to NATS.
+			if err := setStreamLastApplied(ctx, str, *changes.applied, ifc); err != nil {
+				return err
+			}
+		}
+		if c.opts.ManagedByLabel {
+			if err := setStreamManagedBy(ctx, str, ifc); err != nil {
+				return err
+			}
+		}
+		if recreate {
+			c.warningEvent(str, "Recreated", fmt.Sprintf("Recreated stream %q with %s storage", spec.Name, strings.ToLower(getStorage(spec.Storage).String())))
+			c.enqueueStreamConsumers(str.Namespace, spec.Name)
+		} else {
+			c.normalEvent(str, "Updated", fmt.Sprintf("Updated stream %q", spec.Name))
+		}
+		if recreate || changes.limits {
+			c.adviseRetentionLimits(str, spec)
+		}
+		recordStreamResult(ctx, ActionUpdated, spec)
 		return nil
 	case deleteOK:
-		if str.Spec.PreventDelete || readOnly {
+		if str.Spec.PreventDelete || readOnly || c.opts.DisableFinalizers {
 			c.normalEvent(str, "SkipDelete", fmt.Sprintf("Skip deleting stream %q", spec.Name))
-			if _, err := setStreamOK(c.ctx, str, ifc); err != nil {
+			if _, err := setStreamOK(ctx, str, ifc); err != nil {
 				return err
 			}
 			return nil
 		}
 		c.normalEvent(str, "Deleting", fmt.Sprintf("Deleting stream %q", spec.Name))
+		c.strCache.invalidate(cacheKey)
 		if err := natsClientUtil(deleteStream); err != nil {
 			return err
 		}
+		// Resources held by other finalizers stay around until those are
+		// removed, so record the outcome for anyone watching them.
+		if len(str.Finalizers) > 0 {
+			if _, err := setStreamDeleted(ctx, str, ifc); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+		recordStreamResult(ctx, ActionDeleted, spec)
 	default:
-		c.normalEvent(str, "Noop", fmt.Sprintf("Nothing done for stream %q (prevent-delete=%v, prevent-update=%v)",
+		c.noopEvent(str, "Noop", fmt.Sprintf("Nothing done for stream %q (prevent-delete=%v, prevent-update=%v)",
 			spec.Name, spec.PreventDelete, spec.PreventUpdate,
 		))
-		// Noop events only update the status of the CRD.
-		if _, err := setStreamOK(c.ctx, str, ifc); err != nil {
+		// Noop events only update the status of the CRD, and label
+		// resources created before the label was enabled.
+		if _, err := setStreamOK(ctx, withDomain(withState(withTargets())), ifc); err != nil {
 			return err
 		}
+		if c.opts.ManagedByLabel {
+			if err := setStreamManagedBy(ctx, str, ifc); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// nearCapacity reports whether the stream holds CapacityWarningPercent or more
+// of its max bytes. Streams without a bytes limit never are.
+func (c *Controller) nearCapacity(info *jsmapi.StreamInfo) bool {
+	if c.opts.CapacityWarningPercent <= 0 || info.Config.MaxBytes <= 0 {
+		return false
+	}
+	return info.State.Bytes*100 >= uint64(info.Config.MaxBytes)*uint64(c.opts.CapacityWarningPercent)
+}
+
+// adviseRetentionLimits records a RetentionLimits event when the stream has
+// both a max age and max bytes. Whichever is reached first applies, so one of
+// them is effectively unreachable depending on how fast the stream fills up;
+// the event gives the ingest rate past which max bytes takes over. It's only
+// recorded when the stream is created or those limits change.
+func (c *Controller) adviseRetentionLimits(str *apis.Stream, spec apis.StreamSpec) {
+	cfg, err := streamSpecToConfig(spec)
+	if err != nil {
+		return
+	}
+	if msg := retentionAdvisory(cfg); msg != "" {
+		c.normalEvent(str, "RetentionLimits", msg)
+	}
+}
+
+func retentionAdvisory(cfg jsmapi.StreamConfig) string {
+	if cfg.MaxAge <= 0 || cfg.MaxBytes <= 0 {
+		return ""
+	}
+	rate := float64(cfg.MaxBytes) / cfg.MaxAge.Seconds()
+	return fmt.Sprintf("Stream %q keeps messages for %s or up to %d bytes, whichever is reached first: "+
+		"below %.0f bytes/s maxBytes is never reached, above it messages are dropped before maxAge",
+		cfg.Name, cfg.MaxAge, cfg.MaxBytes, rate)
+}
+
+// defaultMaxPayload is the largest message a server accepts unless
+// configured otherwise.
+const defaultMaxPayload = 1 << 20
+
+// discardNewFull reports whether a stream that discards new messages once
+// it's over its max bytes is full: a message of its max size, or of the
+// default max payload, would no longer fit.
+func discardNewFull(info *jsmapi.StreamInfo) bool {
+	if info.Config.Discard != jsmapi.DiscardNew || info.Config.MaxBytes <= 0 {
+		return false
+	}
+	msgSize := uint64(defaultMaxPayload)
+	if info.Config.MaxMsgSize > 0 {
+		msgSize = uint64(info.Config.MaxMsgSize)
+	}
+	return info.State.Bytes+msgSize > uint64(info.Config.MaxBytes)
+}
+
+// checkStreamNameConflict returns an error, and warns on every resource
+// involved, when other Stream resources manage the same NATS stream as str.
+// Resources with the allow-stream-name-conflict annotation are ignored.
+func (c *Controller) checkStreamNameConflict(str *apis.Stream, name string) error {
+	if str.Annotations[allowStreamNameConflictAnnotation] == "true" {
+		return nil
+	}
+
+	streams, err := c.strLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	var conflicts []*apis.Stream
+	for _, other := range streams {
+		if other.Namespace == str.Namespace && other.Name == str.Name {
+			continue
+		}
+		if other.Spec.Name != name || other.DeletionTimestamp != nil {
+			continue
+		}
+		if other.Annotations[allowStreamNameConflictAnnotation] == "true" {
+			continue
+		}
+		conflicts = append(conflicts, other)
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(conflicts))
+	for _, other := range conflicts {
+		keys = append(keys, objectKey(other.Namespace, other.Name))
+		c.warningEvent(other, "ConflictingStreamName", fmt.Sprintf("Stream %q is also managed by %s", name, objectKey(str.Namespace, str.Name)))
+	}
+	sort.Strings(keys)
+	msg := fmt.Sprintf("Stream %q is also managed by %s, refusing to reconcile until resolved", name, strings.Join(keys, ", "))
+	c.warningEvent(str, "ConflictingStreamName", msg)
+	return errors.New(msg)
+}
+
+// checkStreamQuota returns an error, and warns on str, when creating its
+// stream would exceed MaxStreamsPerNamespace. Streams in a namespace are
+// admitted oldest first, so which ones are refused doesn't depend on the
+// order they happen to be reconciled in.
+func (c *Controller) checkStreamQuota(str *apis.Stream) error {
+	max := c.opts.MaxStreamsPerNamespace
+	if max <= 0 {
+		return nil
+	}
+
+	streams, err := c.strLister.Streams(str.Namespace).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	var ahead int
+	for _, other := range streams {
+		if other.Name == str.Name || other.DeletionTimestamp != nil {
+			continue
+		}
+		ot, st := other.CreationTimestamp, str.CreationTimestamp
+		if ot.Before(&st) || (ot.Equal(&st) && other.Name < str.Name) {
+			ahead++
+		}
+	}
+	if ahead < max {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Namespace %q is limited to %d streams, refusing to create stream %q", str.Namespace, max, str.Spec.Name)
+	c.warningEvent(str, "QuotaExceeded", msg)
+	return errors.New(msg)
+}
+
 func streamExists(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err error) {
 	defer func() {
 		if err != nil {
@@ -293,20 +767,56 @@ func createStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 		}
 	}()
 
-	maxAge, err := getMaxAge(spec.MaxAge)
+	if err := checkStreamFeatures(c.ServerVersion(), spec); err != nil {
+		return err
+	}
+	opts, err := streamCreateOptions(spec)
 	if err != nil {
 		return err
 	}
 
+	_, err = c.NewStream(ctx, spec.Name, opts)
+	return err
+}
+
+// streamCreateConfig returns the config a stream is created with from spec.
+func streamCreateConfig(spec apis.StreamSpec) (jsmapi.StreamConfig, error) {
+	opts, err := streamCreateOptions(spec)
+	if err != nil {
+		return jsmapi.StreamConfig{}, err
+	}
+	config, err := jsm.NewStreamConfiguration(jsm.DefaultStream, opts...)
+	if err != nil {
+		return jsmapi.StreamConfig{}, err
+	}
+	config.Name = spec.Name
+	return *config, nil
+}
+
+// streamCreateOptions returns the options a stream is created with from spec.
+func streamCreateOptions(spec apis.StreamSpec) ([]jsm.StreamOption, error) {
+	maxAge, err := getMaxAge(spec.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+
 	duplicates, err := getDuplicates(spec.DuplicateWindow)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := validateDuplicateWindow(duplicates, maxAge); err != nil {
+		return nil, err
+	}
+
+	maxMsgSize, err := getMaxMsgSize(spec.MaxMsgSize)
+	if err != nil {
+		return nil, err
 	}
 
 	opts := []jsm.StreamOption{
 		jsm.Subjects(spec.Subjects...),
 		jsm.MaxConsumers(spec.MaxConsumers),
-		jsm.MaxMessageSize(int32(spec.MaxMsgSize)),
+		jsm.MaxMessageSize(maxMsgSize),
 		jsm.MaxMessages(int64(spec.MaxMsgs)),
 		jsm.Replicas(spec.Replicas),
 		jsm.DuplicateWindow(duplicates),
@@ -358,7 +868,7 @@ func createStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 	if spec.Mirror != nil {
 		ss, err := getStreamSource(spec.Mirror)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		opts = append(opts, func(o *jsmapi.StreamConfig) error {
@@ -381,7 +891,7 @@ func createStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 	for _, ss := range spec.Sources {
 		jss, err := getStreamSource(ss)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		srcs = append(srcs, jss)
 	}
@@ -394,6 +904,7 @@ func createStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 		opts = append(opts, jsm.Republish(&jsmapi.RePublish{
 			Source:      spec.Republish.Source,
 			Destination: spec.Republish.Destination,
+			HeadersOnly: spec.Republish.HeadersOnly,
 		}))
 	}
 
@@ -409,25 +920,299 @@ func createStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 		opts = append(opts, jsm.DenyDelete())
 	}
 
-	_, err = c.NewStream(ctx, spec.Name, opts)
-	return err
+	return opts, nil
+}
+
+// checkRepublishExported warns when the account of the stream declares its
+// exports but none covers the republish destination, as other accounts
+// importing the republished messages would then silently miss them.
+func (c *Controller) checkRepublishExported(str *apis.Stream, acc *apis.Account) {
+	rp := str.Spec.Republish
+	if rp == nil || acc == nil || len(acc.Spec.Exports) == 0 {
+		return
+	}
+
+	dest := republishDestination(rp)
+	for _, export := range acc.Spec.Exports {
+		if subjectMatches(export, dest) {
+			return
+		}
+	}
+	c.warningEvent(str, "RepublishNotExported",
+		fmt.Sprintf("Republish destination %q of stream %q is not exported by account %q", rp.Destination, str.Spec.Name, acc.Name))
+}
+
+// republishDestination returns the destination of rp with its subject
+// mapping functions, which can map to any token, replaced by wildcards.
+func republishDestination(rp *apis.RePublish) string {
+	tokens := strings.Split(rp.Destination, ".")
+	for i, t := range tokens {
+		if strings.HasPrefix(t, "{{") || strings.HasPrefix(t, "$") {
+			tokens[i] = "*"
+		}
+	}
+	return strings.Join(tokens, ".")
+}
+
+// validateRepublish returns an error when the republish config of spec would
+// be rejected by NATS: it needs a destination, which can't overlap the
+// subjects of the stream as republished messages would be stored again.
+func validateRepublish(spec apis.StreamSpec) error {
+	rp := spec.Republish
+	if rp == nil {
+		return nil
+	}
+	if rp.Destination == "" {
+		return errors.New("invalid republish without a destination")
+	}
+	if rp.Source != "" {
+		if err := validateSubject(rp.Source); err != nil {
+			return fmt.Errorf("invalid republish source %q: %w", rp.Source, err)
+		}
+	}
+	dest := republishDestination(rp)
+	if err := validateSubject(dest); err != nil {
+		return fmt.Errorf("invalid republish destination %q: %w", rp.Destination, err)
+	}
+	for _, subj := range spec.Subjects {
+		if subjectsOverlap(dest, subj) {
+			return fmt.Errorf("invalid republish destination %q overlapping stream subject %q", rp.Destination, subj)
+		}
+	}
+	return nil
 }
 
-func updateStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err error) {
+// streamTargets returns the state NATS reports for each source and the mirror
+// of the stream. Targets NATS doesn't report on yet are Unknown. Error
+// messages are truncated to maxMessage.
+func streamTargets(ctx context.Context, c jsmClient, spec apis.StreamSpec, maxMessage int) ([]apis.TargetStatus, error) {
+	js, err := c.LoadStream(ctx, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := js.LatestInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sources from other domains or accounts may share a name, they're told
+	// apart by their API prefix.
+	reported := make(map[string]*jsmapi.StreamSourceInfo)
+	for _, si := range info.Sources {
+		reported[targetKey(si.Name, si.External)] = si
+	}
+	var sources []*apis.StreamSource
+	if spec.Mirror != nil {
+		sources = append(sources, spec.Mirror)
+		if info.Mirror != nil {
+			reported[targetKey(info.Mirror.Name, info.Mirror.External)] = info.Mirror
+		}
+	}
+	sources = append(sources, spec.Sources...)
+
+	targets := make([]apis.TargetStatus, 0, len(sources))
+	for _, ss := range sources {
+		jss, err := getStreamSource(ss)
+		if err != nil {
+			return nil, err
+		}
+		t := apis.TargetStatus{Name: ss.Name}
+		si, ok := reported[targetKey(jss.Name, jss.External)]
+		switch {
+		case !ok:
+			t.Status = k8sapi.ConditionUnknown
+			t.Reason = "Pending"
+			t.Message = "Not reported by NATS yet"
+		case si.Error != nil:
+			t.Status = k8sapi.ConditionFalse
+			t.Reason = "Errored"
+			t.Message = si.Error.Description
+			if t.Message == "" {
+				t.Message = si.Error.Error()
+			}
+			t.Message = truncateMessage(t.Message, maxMessage)
+		default:
+			t.Status = k8sapi.ConditionTrue
+			t.Reason = "Active"
+			t.Message = fmt.Sprintf("%d messages behind", si.Lag)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// targetKey identifies a source or mirror of a stream by its name and API
+// prefix.
+func targetKey(name string, ext *jsmapi.ExternalStream) string {
+	if ext == nil {
+		return name
+	}
+	return name + " " + ext.ApiPrefix
+}
+
+// streamChanges are the sources an update attached to or detached from a
+// stream, by name, the placement it moved the stream to, if any, whether it
+// changed the retention limits of the stream, and the config it sent, nil
+// when the stream was left as is.
+type streamChanges struct {
+	added     []string
+	removed   []string
+	placement *jsmapi.Placement
+	limits    bool
+	applied   *jsmapi.StreamConfig
+
+	// addedSubjects and removedSubjects are set even if the update failed,
+	// to tell which subjects the server refused to change.
+	addedSubjects   []string
+	removedSubjects []string
+}
+
+func updateStream(ctx context.Context, c jsmClient, js jsmStream, spec apis.StreamSpec) (changes streamChanges, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("failed to update stream %q: %w", spec.Name, err)
 		}
 	}()
 
-	js, err := c.LoadStream(ctx, spec.Name)
+	if err := checkStreamFeatures(c.ServerVersion(), spec); err != nil {
+		return changes, err
+	}
+
+	desired, err := streamSpecToConfig(spec)
 	if err != nil {
-		return err
+		return changes, err
+	}
+
+	// Only the fields managed by the spec are changed, anything else set on
+	// the stream out-of-band is kept as is.
+	current := js.Configuration()
+	if desired.Placement == nil {
+		// Placement is left to the server unless the spec asks for one.
+		desired.Placement = current.Placement
+	}
+	config, changed := mergeStreamConfig(current, desired)
+	if len(changed) == 0 {
+		return changes, nil
+	}
+	klog.Infof("Updating stream %q fields: %s", spec.Name, strings.Join(changed, ", "))
+
+	addedSubjects, removedSubjects := diffSubjects(current.Subjects, config.Subjects)
+	if err := js.UpdateConfiguration(config); err != nil {
+		changes.addedSubjects, changes.removedSubjects = addedSubjects, removedSubjects
+		return changes, err
 	}
+	changes = diffStreamSources(current.Sources, config.Sources)
+	changes.addedSubjects, changes.removedSubjects = addedSubjects, removedSubjects
+	changes.applied = &config
+	for _, name := range changed {
+		if name == "MaxAge" || name == "MaxBytes" {
+			changes.limits = true
+		}
+	}
+	if !reflect.DeepEqual(current.Placement, config.Placement) {
+		// Changing the placement makes the server move the replicas of the
+		// stream to peers matching it.
+		changes.placement = config.Placement
+	}
+	return changes, nil
+}
+
+// storageChange returns the storage type of js, the stream of spec as loaded
+// from NATS, and whether spec asks for another one. The CRD defaults the storage, a spec
+// without one never asks for a change.
+func storageChange(js jsmStream, spec apis.StreamSpec) (jsmapi.StorageType, bool) {
+	if spec.Storage == "" {
+		return 0, false
+	}
+	current := js.Configuration().Storage
+	return current, current != getStorage(spec.Storage)
+}
+
+// recreateStream deletes js, the stream of spec, along with its messages and
+// consumers, and creates it again from spec. It returns the config the
+// stream was created with.
+func recreateStream(ctx context.Context, c jsmClient, js jsmStream, spec apis.StreamSpec) (config jsmapi.StreamConfig, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to recreate stream %q: %w", spec.Name, err)
+		}
+	}()
+
+	if config, err = streamCreateConfig(spec); err != nil {
+		return config, err
+	}
+	if err := js.Delete(); err != nil {
+		return config, err
+	}
+	return config, createStream(ctx, c, spec)
+}
+
+// enqueueStreamConsumers queues the consumers of stream in ns, so the ones a
+// recreated stream lost are created again without waiting for a resync. They
+// are reconciled even if unchanged since their last reconcile.
+func (c *Controller) enqueueStreamConsumers(ns, stream string) {
+	consumers, err := c.cnsLister.Consumers(ns).List(labels.Everything())
+	if err != nil {
+		klog.Infof("failed to list consumers of stream %q: %s", stream, err)
+		return
+	}
+	for _, cns := range consumers {
+		if cns.Spec.StreamName == stream {
+			c.forced.add(outcomeKey("consumer", cns.Namespace, cns.Name))
+			c.cnsQueue.Add(objectKey(cns.Namespace, cns.Name))
+		}
+	}
+}
+
+// diffStreamSources returns the names of the sources in desired but not in
+// current, and the other way around.
+func diffStreamSources(current, desired []*jsmapi.StreamSource) streamChanges {
+	var changes streamChanges
 
+	names := make(map[string]bool, len(current))
+	for _, s := range current {
+		names[s.Name] = true
+	}
+	for _, s := range desired {
+		if !names[s.Name] {
+			changes.added = append(changes.added, s.Name)
+		}
+		delete(names, s.Name)
+	}
+	for _, s := range current {
+		if names[s.Name] {
+			changes.removed = append(changes.removed, s.Name)
+		}
+	}
+
+	return changes
+}
+
+// diffSubjects returns the subjects in desired but not in current, and the
+// other way around.
+func diffSubjects(current, desired []string) (added, removed []string) {
+	subjects := make(map[string]bool, len(current))
+	for _, s := range current {
+		subjects[s] = true
+	}
+	for _, s := range desired {
+		if !subjects[s] {
+			added = append(added, s)
+		}
+		delete(subjects, s)
+	}
+	for _, s := range current {
+		if subjects[s] {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+func streamSpecToConfig(spec apis.StreamSpec) (jsmapi.StreamConfig, error) {
 	maxAge, err := getMaxAge(spec.MaxAge)
 	if err != nil {
-		return err
+		return jsmapi.StreamConfig{}, err
 	}
 
 	retention := getRetention(spec.Retention)
@@ -436,7 +1221,15 @@ func updateStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 
 	duplicates, err := getDuplicates(spec.DuplicateWindow)
 	if err != nil {
-		return err
+		return jsmapi.StreamConfig{}, err
+	}
+	if err := validateDuplicateWindow(duplicates, maxAge); err != nil {
+		return jsmapi.StreamConfig{}, err
+	}
+
+	maxMsgSize, err := getMaxMsgSize(spec.MaxMsgSize)
+	if err != nil {
+		return jsmapi.StreamConfig{}, err
 	}
 
 	config := jsmapi.StreamConfig{
@@ -446,9 +1239,10 @@ func updateStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 		Subjects:      spec.Subjects,
 		MaxConsumers:  spec.MaxConsumers,
 		MaxMsgs:       int64(spec.MaxMsgs),
+		MaxMsgsPer:    int64(spec.MaxMsgsPerSubject),
 		MaxBytes:      int64(spec.MaxBytes),
 		MaxAge:        maxAge,
-		MaxMsgSize:    int32(spec.MaxMsgSize),
+		MaxMsgSize:    maxMsgSize,
 		Storage:       storage,
 		Discard:       discard,
 		Replicas:      spec.Replicas,
@@ -468,21 +1262,82 @@ func updateStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 	if spec.Mirror != nil {
 		ss, err := getStreamSource(spec.Mirror)
 		if err != nil {
-			return err
+			return jsmapi.StreamConfig{}, err
 		}
 
 		config.Mirror = ss
 	}
-	config.Sources = make([]*jsmapi.StreamSource, len(spec.Sources))
-	for i, ss := range spec.Sources {
+	if spec.Placement != nil {
+		config.Placement = &jsmapi.Placement{
+			Cluster: spec.Placement.Cluster,
+			Tags:    spec.Placement.Tags,
+		}
+	}
+	for _, ss := range spec.Sources {
 		jss, err := getStreamSource(ss)
 		if err != nil {
-			return err
+			return jsmapi.StreamConfig{}, err
 		}
-		config.Sources[i] = jss
+		config.Sources = append(config.Sources, jss)
 	}
 
-	return js.UpdateConfiguration(config)
+	return config, nil
+}
+
+// managedStreamFields are the StreamConfig fields set from a StreamSpec on
+// update.
+var managedStreamFields = []string{
+	"Description",
+	"Retention",
+	"Subjects",
+	"MaxConsumers",
+	"MaxMsgs",
+	"MaxMsgsPer",
+	"MaxBytes",
+	"MaxAge",
+	"MaxMsgSize",
+	"Storage",
+	"Discard",
+	"Replicas",
+	"NoAck",
+	"Duplicates",
+	"AllowDirect",
+	"DenyDelete",
+	"RollupAllowed",
+	"RePublish",
+	"Mirror",
+	"Placement",
+	"Sources",
+}
+
+// mergeStreamConfig applies the managed fields of desired that differ from
+// current on top of current, and returns the result along with the names of
+// the changed fields.
+func mergeStreamConfig(current, desired jsmapi.StreamConfig) (jsmapi.StreamConfig, []string) {
+	merged := current
+	mv := reflect.ValueOf(&merged).Elem()
+	dv := reflect.ValueOf(desired)
+
+	var changed []string
+	for _, name := range managedStreamFields {
+		mf, df := mv.FieldByName(name), dv.FieldByName(name)
+		if fieldsEqual(mf, df) {
+			continue
+		}
+		mf.Set(df)
+		changed = append(changed, name)
+	}
+
+	return merged, changed
+}
+
+// fieldsEqual compares two config values, treating nil and empty slices as
+// equal.
+func fieldsEqual(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
 }
 
 func deleteStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err error) {
@@ -509,18 +1364,24 @@ func deleteStream(ctx context.Context, c jsmClient, spec apis.StreamSpec) (err e
 	return str.Delete()
 }
 
-func setStreamErrored(ctx context.Context, s *apis.Stream, sif typed.StreamInterface, err error) (*apis.Stream, error) {
+func setStreamErrored(ctx context.Context, s *apis.Stream, sif typed.StreamInterface, err error, maxMessage int) (*apis.Stream, error) {
 	if err == nil {
 		return s, nil
 	}
 
+	reason := "Errored"
+	var terr *reconcileTimeoutError
+	if errors.As(err, &terr) {
+		reason = "ReconcileTimeout"
+	}
+
 	sc := s.DeepCopy()
 	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, apis.Condition{
 		Type:               readyCondType,
 		Status:             k8sapi.ConditionFalse,
 		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
-		Reason:             "Errored",
-		Message:            err.Error(),
+		Reason:             reason,
+		Message:            truncateMessage(err.Error(), maxMessage),
 	})
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -544,6 +1405,7 @@ func setStreamOK(ctx context.Context, s *apis.Stream, i typed.StreamInterface) (
 	sc := s.DeepCopy()
 
 	sc.Status.ObservedGeneration = s.Generation
+	sc.Status.ObservedSpecHash = specHash(s.Spec)
 	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, apis.Condition{
 		Type:               readyCondType,
 		Status:             k8sapi.ConditionTrue,
@@ -569,12 +1431,111 @@ func setStreamOK(ctx context.Context, s *apis.Stream, i typed.StreamInterface) (
 	return res, err
 }
 
+// setStreamDeleted sets the terminal Deleted condition of a stream deleted
+// from NATS.
+func setStreamDeleted(ctx context.Context, s *apis.Stream, i typed.StreamInterface) (*apis.Stream, error) {
+	sc := s.DeepCopy()
+
+	sc.Status.ObservedGeneration = s.Generation
+	sc.Status.Conditions = upsertCondition(sc.Status.Conditions, apis.Condition{
+		Type:               deletedCondType,
+		Status:             k8sapi.ConditionTrue,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:             "Deleted",
+		Message:            "Stream successfully deleted",
+	})
+
+	var res *apis.Stream
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		res, err = i.UpdateStatus(ctx, sc, k8smeta.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set stream %q status: %w", s.Spec.Name, err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// setStreamManagedBy adds the managed-by label to the stream if it has none,
+// leaving its other labels, and a managed-by label set otherwise, as is.
+func setStreamManagedBy(ctx context.Context, s *apis.Stream, i typed.StreamInterface) error {
+	if _, ok := s.Labels[managedByLabel]; ok {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		cur, err := i.Get(ctx, s.Name, k8smeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get stream %q: %w", s.Spec.Name, err)
+		}
+		if _, ok := cur.Labels[managedByLabel]; ok {
+			return nil
+		}
+		if cur.Labels == nil {
+			cur.Labels = make(map[string]string)
+		}
+		cur.Labels[managedByLabel] = managedByValue
+
+		if _, err := i.Update(ctx, cur, k8smeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to label stream %q: %w", s.Spec.Name, err)
+		}
+		return nil
+	})
+}
+
+// setStreamLastApplied records the config applied to the stream in the
+// last-applied-config annotation. Metadata changes don't bump the
+// generation, so this doesn't trigger another reconcile.
+func setStreamLastApplied(ctx context.Context, s *apis.Stream, config jsmapi.StreamConfig, i typed.StreamInterface) error {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		cur, err := i.Get(ctx, s.Name, k8smeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get stream %q: %w", s.Spec.Name, err)
+		}
+		if cur.Annotations[lastAppliedConfigAnnotation] == string(b) {
+			return nil
+		}
+		if cur.Annotations == nil {
+			cur.Annotations = make(map[string]string)
+		}
+		cur.Annotations[lastAppliedConfigAnnotation] = string(b)
+
+		if _, err := i.Update(ctx, cur, k8smeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to set stream %q last applied config: %w", s.Spec.Name, err)
+		}
+		return nil
+	})
+}
+
+// getMaxAge parses the max age of a stream. Empty, "0" and "0s" all mean
+// the messages never expire, any positive duration sets their age.
 func getMaxAge(v string) (time.Duration, error) {
 	if v == "" {
 		return time.Duration(0), nil
 	}
 
-	return time.ParseDuration(v)
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("max age %s must not be negative", v)
+	}
+	return d, nil
 }
 
 func getRetention(v string) jsmapi.RetentionPolicy {
@@ -606,6 +1567,15 @@ func getDiscard(v string) jsmapi.DiscardPolicy {
 	return discard
 }
 
+// getMaxMsgSize checks the max message size of a stream fits NATS, -1 being
+// unlimited.
+func getMaxMsgSize(v int) (int32, error) {
+	if v < -1 || v > math.MaxInt32 {
+		return 0, fmt.Errorf("max message size %d must be between -1 and %d", v, math.MaxInt32)
+	}
+	return int32(v), nil
+}
+
 func getDuplicates(v string) (time.Duration, error) {
 	if v == "" {
 		return time.Duration(0), nil
@@ -614,28 +1584,127 @@ func getDuplicates(v string) (time.Duration, error) {
 	return time.ParseDuration(v)
 }
 
+// validateDuplicateWindow rejects a duplicate window longer than the max age
+// of the messages it tracks. A zero max age means unlimited.
+func validateDuplicateWindow(duplicates, maxAge time.Duration) error {
+	if maxAge > 0 && duplicates > maxAge {
+		return fmt.Errorf("duplicate window %s exceeds max age %s", duplicates, maxAge)
+	}
+	return nil
+}
+
 func getStreamSource(ss *apis.StreamSource) (*jsmapi.StreamSource, error) {
 	jss := &jsmapi.StreamSource{
 		Name:          ss.Name,
 		FilterSubject: ss.FilterSubject,
 	}
 
+	if ss.OptStartSeq > 0 && ss.OptStartTime != "" {
+		return nil, fmt.Errorf("source %q sets both 'optStartSeq' and 'optStartTime', only one start position is allowed", ss.Name)
+	}
 	if ss.OptStartSeq > 0 {
 		jss.OptStartSeq = uint64(ss.OptStartSeq)
 	} else if ss.OptStartTime != "" {
 		t, err := time.Parse(time.RFC3339, ss.OptStartTime)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("invalid 'optStartTime' of source %q: %w", ss.Name, err)
 		}
 		jss.OptStartTime = &t
 	}
 
-	if ss.ExternalAPIPrefix != "" || ss.ExternalDeliverPrefix != "" {
+	apiPrefix := ss.ExternalAPIPrefix
+	if ss.ExternalDomain != "" {
+		if apiPrefix != "" {
+			return nil, fmt.Errorf("source %q sets both 'externalApiPrefix' and 'externalDomain'", ss.Name)
+		}
+		// The API of a domain is served under its own prefix.
+		apiPrefix = fmt.Sprintf("$JS.%s.API", ss.ExternalDomain)
+	}
+	if apiPrefix != "" || ss.ExternalDeliverPrefix != "" {
 		jss.External = &jsmapi.ExternalStream{
-			ApiPrefix:     ss.ExternalAPIPrefix,
+			ApiPrefix:     apiPrefix,
 			DeliverPrefix: ss.ExternalDeliverPrefix,
 		}
 	}
 
 	return jss, nil
 }
+
+// streamCache remembers, per resource, the generation at which a stream was
+// last found to exist in NATS.
+type streamCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]streamCacheEntry
+}
+
+type streamCacheEntry struct {
+	generation int64
+	expires    time.Time
+}
+
+func newStreamCache(ttl time.Duration) *streamCache {
+	return &streamCache{
+		ttl:     ttl,
+		entries: make(map[string]streamCacheEntry),
+	}
+}
+
+// hit reports whether the stream for key was observed at generation within
+// the TTL.
+func (sc *streamCache) hit(key string, generation int64) bool {
+	if sc.ttl <= 0 {
+		return false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	e, ok := sc.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(e.expires) {
+		delete(sc.entries, key)
+		return false
+	}
+
+	return e.generation == generation
+}
+
+func (sc *streamCache) observe(key string, generation int64) {
+	if sc.ttl <= 0 {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.entries[key] = streamCacheEntry{
+		generation: generation,
+		expires:    time.Now().Add(sc.ttl),
+	}
+}
+
+func (sc *streamCache) invalidate(key string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	delete(sc.entries, key)
+}
+
+// recordStreamResult records action on the result carried by ctx, along with
+// the config applied when the stream was created or updated.
+func recordStreamResult(ctx context.Context, action ReconcileAction, spec apis.StreamSpec) {
+	res := resultFrom(ctx)
+	if res == nil {
+		return
+	}
+	res.Action = action
+	if action == ActionCreated || action == ActionUpdated {
+		if config, err := streamSpecToConfig(spec); err == nil {
+			res.Config = config
+		}
+	}
+}
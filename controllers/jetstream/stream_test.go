@@ -2,15 +2,21 @@ package jetstream
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	jsmapi "github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats.go"
 
 	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
 	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
 
+	k8sapi "k8s.io/api/core/v1"
 	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
@@ -273,3 +279,2521 @@ func TestProcessStream(t *testing.T) {
 		}
 	})
 }
+
+func TestProcessStreamPausedUntil(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-stream"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+			Annotations: map[string]string{
+				pauseUntilAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339),
+			},
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+	}
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	if err := informer.Informer().GetStore().Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: errors.New("unexpected call to load stream"),
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(rec.Events), 1; got != want {
+		t.Error("unexpected number of events")
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	if gotEvent := <-rec.Events; !strings.Contains(gotEvent, "PausedUntil") {
+		t.Error("unexpected event")
+		t.Fatalf("got=%s; want=%s", gotEvent, "PausedUntil...")
+	}
+
+	// Once the pause has expired the stream is reconciled as usual.
+	resumed := str.DeepCopy()
+	resumed.Annotations[pauseUntilAnnotation] = time.Now().Add(-time.Minute).Format(time.RFC3339)
+	if err := informer.Informer().GetStore().Update(resumed); err != nil {
+		t.Fatal(err)
+	}
+
+	jsmc = &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(rec.Events), 2; got != want {
+		t.Error("unexpected number of events")
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	for i := 0; i < 2; i++ {
+		if gotEvent := <-rec.Events; !strings.Contains(gotEvent, "Creat") {
+			t.Error("unexpected event")
+			t.Fatalf("got=%s; want=%s", gotEvent, "Creating/Created...")
+		}
+	}
+}
+
+func TestProcessStreamCachesLoad(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+		StreamCacheTTL: time.Minute,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{},
+	}
+	for i := 0; i < 2; i++ {
+		if err := ctrl.processStream(ns, name, jsmc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := jsmc.loadStreamCalls, 1; got != want {
+		t.Error("unexpected number of LoadStream calls")
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+}
+
+func TestProcessStreamSuppressNoopEvents(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                context.Background(),
+		KubeIface:          k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:     jc,
+		Recorder:           rec,
+		SuppressNoopEvents: true,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(rec.Events); got != 0 {
+		t.Fatalf("unexpected event: %s", <-rec.Events)
+	}
+}
+
+func TestProcessStreamDisableFinalizers(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:               context.Background(),
+		KubeIface:         k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:    jc,
+		Recorder:          rec,
+		DisableFinalizers: true,
+	})
+
+	ts := k8smeta.Unix(1600216923, 0)
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:         ns,
+			Name:              name,
+			Generation:        2,
+			DeletionTimestamp: &ts,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject()
+		if str := obj.(*apis.Stream); len(str.Finalizers) > 0 {
+			t.Errorf("unexpected finalizers: %v", str.Finalizers)
+		}
+		return true, obj, nil
+	})
+
+	ms := &mockStream{}
+	jsmc := &mockJsmClient{
+		loadStream: ms,
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if ms.deleted {
+		t.Fatal("unexpected stream delete")
+	}
+	if gotEvent := <-rec.Events; !strings.Contains(gotEvent, "SkipDelete") {
+		t.Error("unexpected event")
+		t.Fatalf("got=%s; want=%s", gotEvent, "SkipDelete...")
+	}
+}
+
+func TestProcessStreamDeletedCondition(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	ts := k8smeta.Unix(1600216923, 0)
+	ns, name := "default", "my-stream"
+	finalizer := "example.com/backup"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:         ns,
+			Name:              name,
+			Generation:        2,
+			DeletionTimestamp: &ts,
+			Finalizers:        []string{finalizer},
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record every write, in order, with whether the finalizer was still
+	// there and whether the Deleted condition was set.
+	type write struct {
+		finalizer, deleted bool
+	}
+	var writes []write
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		str := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		w := write{finalizer: len(str.Finalizers) > 0}
+		for _, cond := range str.Status.Conditions {
+			if cond.Type == deletedCondType && cond.Status == k8sapi.ConditionTrue {
+				w.deleted = true
+			}
+		}
+		writes = append(writes, w)
+		return true, str, nil
+	})
+
+	ms := &mockStream{}
+	jsmc := &mockJsmClient{
+		loadStream: ms,
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ms.deleted {
+		t.Fatal("stream was not deleted")
+	}
+	for i, w := range writes {
+		if !w.finalizer {
+			t.Fatalf("write %d removed the finalizer before the Deleted condition was written", i)
+		}
+		if w.deleted {
+			return
+		}
+	}
+	t.Fatalf("got writes %+v; want the Deleted condition", writes)
+}
+
+func TestProcessStreamSpecChangedDuringReconcile(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	ns, name := "default", "my-stream"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+	}
+
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	if err := store.Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	var observed string
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		observed = obj.Status.ObservedSpecHash
+
+		// The stream is edited again while it's being reconciled.
+		edited := str.DeepCopy()
+		edited.Generation = 2
+		edited.Spec.MaxAge = "2h"
+		if err := store.Update(edited); err != nil {
+			t.Error(err)
+		}
+		return true, obj, nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		newStream:     &mockStream{},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := observed, specHash(str.Spec); got != want {
+		t.Fatalf("got=%s; want=%s observed spec hash", got, want)
+	}
+	if got := ctrl.strQueue.Len(); got != 1 {
+		t.Fatalf("got=%d; want the edited stream requeued", got)
+	}
+}
+
+func TestProcessStreamRecordsLastAppliedConfig(t *testing.T) {
+	t.Parallel()
+
+	ns, name := "default", "my-stream"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:     name,
+			Subjects: []string{"orders.*", "orders.*"},
+			MaxAge:   "1h",
+			Storage:  "memory",
+		},
+	}
+
+	jc := clientsetfake.NewSimpleClientset(str)
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                     context.Background(),
+		KubeIface:               k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:          jc,
+		Recorder:                rec,
+		RecordLastAppliedConfig: true,
+		SpecMutator:             replicasMutator{},
+	})
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	if err := informer.Informer().GetStore().Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotAnnotation string
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		ua := a.(k8stesting.UpdateAction)
+		obj := ua.GetObject()
+		if ua.GetSubresource() == "" {
+			gotAnnotation = obj.(*apis.Stream).Annotations[lastAppliedConfigAnnotation]
+		}
+		return true, obj, nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		newStream:     &mockStream{},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsmapi.StreamConfig
+	if err := json.Unmarshal([]byte(gotAnnotation), &got); err != nil {
+		t.Fatalf("failed to decode annotation %q: %s", gotAnnotation, err)
+	}
+	// The config applied is recorded, after the mutator and deduplication.
+	if got.Name != name || got.MaxAge != time.Hour || len(got.Subjects) != 1 || got.Replicas != 3 {
+		t.Error("unexpected last applied config")
+		t.Fatalf("got=%+v", got)
+	}
+}
+
+func TestProcessStreamUpdateRecordsLastAppliedConfig(t *testing.T) {
+	t.Parallel()
+
+	ns, name := "default", "orders"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "2h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	}
+
+	jc := clientsetfake.NewSimpleClientset(str)
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                     context.Background(),
+		KubeIface:               k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:          jc,
+		Recorder:                rec,
+		RecordLastAppliedConfig: true,
+	})
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	if err := informer.Informer().GetStore().Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotAnnotation string
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		ua := a.(k8stesting.UpdateAction)
+		obj := ua.GetObject()
+		if ua.GetSubresource() == "" {
+			gotAnnotation = obj.(*apis.Stream).Annotations[lastAppliedConfigAnnotation]
+		}
+		return true, obj, nil
+	})
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{
+			Name:      name,
+			Storage:   jsmapi.MemoryStorage,
+			MaxAge:    time.Hour,
+			Placement: &jsmapi.Placement{Cluster: "west"},
+		},
+	}
+	jsmc := &mockJsmClient{
+		loadStream: ms,
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsmapi.StreamConfig
+	if err := json.Unmarshal([]byte(gotAnnotation), &got); err != nil {
+		t.Fatalf("failed to decode annotation %q: %s", gotAnnotation, err)
+	}
+	// The config merged onto the stream is recorded, with the placement
+	// the spec leaves to the server.
+	if !reflect.DeepEqual(got, *ms.updatedConfig) {
+		t.Fatalf("got=%+v; want=%+v", got, *ms.updatedConfig)
+	}
+	if want := (&jsmapi.Placement{Cluster: "west"}); !reflect.DeepEqual(got.Placement, want) {
+		t.Fatalf("got placement=%+v; want=%+v", got.Placement, want)
+	}
+
+	// Nothing is recorded when nothing was sent.
+	ms.config, ms.updatedConfig, gotAnnotation = got, nil, ""
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if ms.updatedConfig != nil {
+		t.Fatalf("unexpected update: %+v", *ms.updatedConfig)
+	}
+	if gotAnnotation != "" {
+		t.Fatalf("unexpected last applied config %q", gotAnnotation)
+	}
+}
+
+func TestProcessStreamManagedByLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		labels map[string]string
+		want   map[string]string
+	}{
+		"adds the label": {
+			labels: map[string]string{"team": "payments"},
+			want:   map[string]string{"team": "payments", managedByLabel: managedByValue},
+		},
+		"keeps a label set otherwise": {
+			labels: map[string]string{managedByLabel: "argocd"},
+			want:   map[string]string{managedByLabel: "argocd"},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ns, name := "default", "my-stream"
+			str := &apis.Stream{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 1,
+					Labels:     tt.labels,
+				},
+				Spec: apis.StreamSpec{
+					Name:    name,
+					Storage: "memory",
+				},
+			}
+
+			jc := clientsetfake.NewSimpleClientset(str)
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       record.NewFakeRecorder(10),
+				ManagedByLabel: true,
+			})
+			if err := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore().Add(str); err != nil {
+				t.Fatal(err)
+			}
+
+			jsmc := &mockJsmClient{
+				loadStreamErr: jsmapi.ApiError{Code: 404},
+				newStream:     &mockStream{},
+			}
+			if err := ctrl.processStream(ns, name, jsmc); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := jc.JetstreamV1beta2().Streams(ns).Get(context.Background(), name, k8smeta.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Labels, tt.want) {
+				t.Fatalf("got=%v; want=%v", got.Labels, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessStreamUnsupportedFeature(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:        name,
+			MaxAge:      "1h",
+			Storage:     "memory",
+			AllowDirect: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		serverVersion: "2.8.4",
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		newStreamErr:  errors.New("unexpected call to create stream"),
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	want := `Unsupported feature "allowDirect" not supported by server version 2.8.4`
+	for i := 0; i < 2; i++ {
+		gotEvent := <-rec.Events
+		if strings.Contains(gotEvent, "Creating") {
+			continue
+		}
+		if !strings.Contains(gotEvent, want) {
+			t.Error("unexpected event")
+			t.Fatalf("got=%s; want=%s...", gotEvent, want)
+		}
+	}
+}
+
+func TestProcessStreamJetStreamNotEnabled(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 503, ErrCode: 10039, Description: "jetstream not enabled for account"},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if gotEvent := <-rec.Events; !strings.Contains(gotEvent, "JetStreamNotEnabled") {
+		t.Error("unexpected event")
+		t.Fatalf("got=%s; want=%s", gotEvent, "JetStreamNotEnabled...")
+	}
+}
+
+func TestProcessStreamConnectionLost(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// The connection drops between loading the stream and updating it.
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{updateErr: nats.ErrConnectionClosed},
+	}
+	err = ctrl.processStream(ns, name, jsmc)
+	if !errors.Is(err, nats.ErrConnectionClosed) {
+		t.Fatalf("got=%v; want=%v", err, nats.ErrConnectionClosed)
+	}
+
+	for {
+		select {
+		case gotEvent := <-rec.Events:
+			if strings.Contains(gotEvent, "ConnectionLost") {
+				return
+			}
+		default:
+			t.Fatal("missing ConnectionLost event")
+		}
+	}
+}
+
+func TestProcessStreamWaitingForLeader(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updates int
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		updates++
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// The stream leader is being elected while it's updated.
+	noLeader := jsmapi.ApiError{Code: 503, ErrCode: 10008, Description: "JetStream system temporarily unavailable"}
+	jsmc := &mockJsmClient{
+		loadStream: &mockStream{updateErr: noLeader},
+	}
+	err = ctrl.processStream(ns, name, jsmc)
+	if classifyError(err) != errKindNoLeader {
+		t.Fatalf("got=%v; want a no leader error", err)
+	}
+	if updates != 0 {
+		t.Fatalf("got=%d status updates; want none, so the stream isn't marked errored", updates)
+	}
+
+	var waiting bool
+	for len(rec.Events) > 0 {
+		if strings.Contains(<-rec.Events, "WaitingForLeader") {
+			waiting = true
+		}
+	}
+	if !waiting {
+		t.Fatal("missing WaitingForLeader event")
+	}
+}
+
+func TestProcessStreamSubjectRemoval(t *testing.T) {
+	t.Parallel()
+
+	newController := func(t *testing.T) (*Controller, *record.FakeRecorder, string, string) {
+		jc := clientsetfake.NewSimpleClientset()
+		rec := record.NewFakeRecorder(10)
+		ctrl := NewController(Options{
+			Ctx:            context.Background(),
+			KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+			JetstreamIface: jc,
+			Recorder:       rec,
+		})
+
+		ns, name := "default", "my-stream"
+
+		informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+		err := informer.Informer().GetStore().Add(&apis.Stream{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:  ns,
+				Name:       name,
+				Generation: 2,
+			},
+			Spec: apis.StreamSpec{
+				Name:     name,
+				Subjects: []string{"orders.new", "orders.shipped"},
+				Storage:  "memory",
+			},
+			Status: apis.Status{
+				ObservedGeneration: 1,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+			return true, a.(k8stesting.UpdateAction).GetObject(), nil
+		})
+		return ctrl, rec, ns, name
+	}
+
+	findEvent := func(rec *record.FakeRecorder, reason string) string {
+		for {
+			select {
+			case e := <-rec.Events:
+				if strings.Contains(e, reason) {
+					return e
+				}
+			default:
+				return ""
+			}
+		}
+	}
+
+	current := jsmapi.StreamConfig{
+		Name:     "my-stream",
+		Subjects: []string{"orders.new", "orders.cancelled"},
+		Storage:  jsmapi.MemoryStorage,
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl, rec, ns, name := newController(t)
+		ms := &mockStream{config: current}
+		if err := ctrl.processStream(ns, name, &mockJsmClient{loadStream: ms}); err != nil {
+			t.Fatal(err)
+		}
+
+		if ms.updatedConfig == nil {
+			t.Fatal("stream was not updated")
+		}
+		if got, want := ms.updatedConfig.Subjects, []string{"orders.new", "orders.shipped"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got=%v; want=%v", got, want)
+		}
+		got := findEvent(rec, "SubjectsChanged")
+		if !strings.Contains(got, "added [orders.shipped], removed [orders.cancelled]") {
+			t.Fatalf("got=%q; want a SubjectsChanged event listing the changes", got)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl, rec, ns, name := newController(t)
+		ms := &mockStream{
+			config:    current,
+			updateErr: jsmapi.ApiError{Code: 500, Description: "stream subjects can not be removed while messages exist"},
+		}
+		if err := ctrl.processStream(ns, name, &mockJsmClient{loadStream: ms}); err == nil {
+			t.Fatal("unexpected success")
+		}
+
+		if got := findEvent(rec, "SubjectsRejected"); !strings.Contains(got, "[orders.cancelled]") {
+			t.Fatalf("got=%q; want a SubjectsRejected event listing the removed subjects", got)
+		}
+	})
+}
+
+// slowJsmClient is a mockJsmClient whose LoadStream only returns once ctx
+// is done.
+type slowJsmClient struct {
+	*mockJsmClient
+}
+
+func (c slowJsmClient) LoadStream(ctx context.Context, name string) (jsmStream, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestProcessStreamMaxReconcileDuration(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                  context.Background(),
+		KubeIface:            k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:       jc,
+		Recorder:             rec,
+		MaxReconcileDuration: 50 * time.Millisecond,
+	})
+
+	ns, name := "default", "my-stream"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			Storage: "memory",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reason string
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		str := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		for _, cond := range str.Status.Conditions {
+			if cond.Type == readyCondType {
+				reason = cond.Reason
+			}
+		}
+		return true, str, nil
+	})
+
+	start := time.Now()
+	err = ctrl.processStream(ns, name, slowJsmClient{&mockJsmClient{}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got=%v; want=%v", err, context.DeadlineExceeded)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Fatalf("reconcile took %s after the deadline", took)
+	}
+
+	if reason != "ReconcileTimeout" {
+		t.Fatalf("got=%q; want=ReconcileTimeout", reason)
+	}
+	if gotEvent := <-rec.Events; !strings.Contains(gotEvent, "ReconcileTimeout") {
+		t.Fatalf("got=%s; want=%s", gotEvent, "ReconcileTimeout...")
+	}
+}
+
+func TestUpdateStreamPreservesUnmanagedFields(t *testing.T) {
+	t.Parallel()
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{
+			Name:         "orders",
+			Subjects:     []string{"orders.*"},
+			Storage:      jsmapi.MemoryStorage,
+			MaxAge:       time.Hour,
+			Replicas:     1,
+			DenyPurge:    true,
+			MirrorDirect: true,
+		},
+	}
+	jsmc := &mockJsmClient{
+		loadStream: ms,
+	}
+
+	_, err := updateStream(context.Background(), jsmc, ms, apis.StreamSpec{
+		Name:     "orders",
+		Subjects: []string{"orders.*"},
+		Storage:  "memory",
+		MaxAge:   "2h",
+		Replicas: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms.updatedConfig == nil {
+		t.Fatal("expected stream configuration update")
+	}
+	if got, want := ms.updatedConfig.MaxAge, 2*time.Hour; got != want {
+		t.Error("unexpected max age")
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+	if !ms.updatedConfig.DenyPurge || !ms.updatedConfig.MirrorDirect {
+		t.Error("unmanaged fields were reset")
+		t.Fatalf("got=%+v", *ms.updatedConfig)
+	}
+}
+
+func TestProcessStreamSourceChanges(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "aggregate"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+			Sources: []*apis.StreamSource{
+				{Name: "orders"},
+				{Name: "refunds"},
+			},
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{
+			Name:    name,
+			Storage: jsmapi.MemoryStorage,
+			MaxAge:  time.Hour,
+			Sources: []*jsmapi.StreamSource{
+				{Name: "orders"},
+				{Name: "payments"},
+			},
+		},
+	}
+	jsmc := &mockJsmClient{
+		loadStream: ms,
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if ms.updatedConfig == nil {
+		t.Fatal("expected stream configuration update")
+	}
+	if got := len(ms.updatedConfig.Sources); got != 2 || ms.updatedConfig.Sources[1].Name != "refunds" {
+		t.Fatalf("unexpected sources: %+v", ms.updatedConfig.Sources)
+	}
+
+	var gotAdded, gotRemoved bool
+	for len(rec.Events) > 0 {
+		ev := <-rec.Events
+		gotAdded = gotAdded || strings.Contains(ev, `SourceAdded Added source "refunds"`)
+		gotRemoved = gotRemoved || strings.Contains(ev, `SourceRemoved Removed source "payments"`)
+	}
+	if !gotAdded || !gotRemoved {
+		t.Fatalf("missing source events: added=%t removed=%t", gotAdded, gotRemoved)
+	}
+}
+
+func TestProcessStreamPlacementChange(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "orders"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+			Placement: &apis.StreamPlacement{
+				Cluster: "east",
+				Tags:    []string{"ssd"},
+			},
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{
+			Name:      name,
+			Storage:   jsmapi.MemoryStorage,
+			MaxAge:    time.Hour,
+			Placement: &jsmapi.Placement{Cluster: "west"},
+		},
+	}
+	jsmc := &mockJsmClient{
+		loadStream: ms,
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	if ms.updatedConfig == nil {
+		t.Fatal("expected stream configuration update")
+	}
+	want := &jsmapi.Placement{Cluster: "east", Tags: []string{"ssd"}}
+	if got := ms.updatedConfig.Placement; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%+v; want=%+v", got, want)
+	}
+
+	var gotMoving bool
+	for len(rec.Events) > 0 {
+		if strings.Contains(<-rec.Events, `Moving Moving stream "orders" to cluster "east"`) {
+			gotMoving = true
+		}
+	}
+	if !gotMoving {
+		t.Fatal("missing Moving event")
+	}
+}
+
+func TestProcessStreamStorageChange(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(20)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "orders"
+	informers := ctrl.informerFactory.Jetstream().V1beta2()
+	store := informers.Streams().Informer().GetStore()
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			Storage: "file",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	}
+	if err := store.Add(str); err != nil {
+		t.Fatal(err)
+	}
+	err := informers.Consumers().Informer().GetStore().Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: ns, Name: "shipping"},
+		Spec:       apis.ConsumerSpec{StreamName: name, DurableName: "shipping"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{Name: name, Storage: jsmapi.MemoryStorage},
+	}
+	jsmc := &mockJsmClient{loadStream: ms, newStream: &mockStream{}}
+	events := func() string {
+		var events []string
+		for len(rec.Events) > 0 {
+			events = append(events, <-rec.Events)
+		}
+		return strings.Join(events, "\n")
+	}
+
+	// Without the annotation the stream and its messages are left alone.
+	if err := ctrl.processStream(ns, name, jsmc); err == nil {
+		t.Fatal("got nil error; want the storage change refused")
+	}
+	if ms.deleted || ms.updatedConfig != nil || jsmc.newStreamOpts != nil {
+		t.Fatal("got the stream changed; want it left alone")
+	}
+	if got := events(); !strings.Contains(got, `StorageChangeBlocked Storage of stream "orders" can't change from memory to file in place`) {
+		t.Fatalf("missing StorageChangeBlocked event in:\n%s", got)
+	}
+
+	// With it the stream is recreated, and its consumers queued to be
+	// recreated too.
+	str = str.DeepCopy()
+	str.Annotations = map[string]string{recreateOnStorageChangeAnnotation: "true"}
+	if err := store.Update(str); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if !ms.deleted || jsmc.newStreamOpts == nil {
+		t.Fatalf("got deleted=%t, created=%t; want the stream recreated", ms.deleted, jsmc.newStreamOpts != nil)
+	}
+	got := events()
+	for _, want := range []string{
+		`Recreating Deleting and recreating stream "orders" to change its storage from memory to file`,
+		`Recreated Recreated stream "orders" with file storage`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing event %q in:\n%s", want, got)
+		}
+	}
+	if got := ctrl.cnsQueue.Len(); got != 1 {
+		t.Fatalf("got=%d; want the consumer of the stream queued", got)
+	}
+}
+
+func TestStreamSpecToConfigDuplicateWindow(t *testing.T) {
+	t.Parallel()
+
+	config, err := streamSpecToConfig(apis.StreamSpec{
+		Name:            "orders",
+		MaxAge:          "1h",
+		DuplicateWindow: "10m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Duplicates, 10*time.Minute; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+
+	_, err = streamSpecToConfig(apis.StreamSpec{
+		Name:            "orders",
+		MaxAge:          "1h",
+		DuplicateWindow: "2h",
+	})
+	if want := "duplicate window 2h0m0s exceeds max age 1h0m0s"; err == nil || err.Error() != want {
+		t.Fatalf("got=%v; want=%s", err, want)
+	}
+
+	// Without a max age, any window is allowed.
+	if _, err := streamSpecToConfig(apis.StreamSpec{Name: "orders", DuplicateWindow: "48h"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessStreamMaxAge(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		maxAge string
+		want   time.Duration
+	}{
+		"empty is unlimited":    {maxAge: "", want: 0},
+		"zero is unlimited":     {maxAge: "0", want: 0},
+		"zero seconds":          {maxAge: "0s", want: 0},
+		"positive sets the age": {maxAge: "1h", want: time.Hour},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       record.NewFakeRecorder(10),
+			})
+
+			ns, name := "default", "orders"
+
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+			err := informer.Informer().GetStore().Add(&apis.Stream{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 2,
+				},
+				Spec: apis.StreamSpec{
+					Name:    name,
+					MaxAge:  tt.maxAge,
+					Storage: "memory",
+				},
+				Status: apis.Status{
+					ObservedGeneration: 1,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				return true, a.(k8stesting.UpdateAction).GetObject(), nil
+			})
+
+			ms := &mockStream{
+				config: jsmapi.StreamConfig{
+					Name:    name,
+					Storage: jsmapi.MemoryStorage,
+					MaxAge:  2 * time.Hour,
+				},
+			}
+			jsmc := &mockJsmClient{
+				loadStream: ms,
+			}
+			if err := ctrl.processStream(ns, name, jsmc); err != nil {
+				t.Fatal(err)
+			}
+
+			if ms.updatedConfig == nil {
+				t.Fatal("expected stream configuration update")
+			}
+			if got := ms.updatedConfig.MaxAge; got != tt.want {
+				t.Fatalf("got=%s; want=%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessStreamRetentionLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		maxAge   string
+		maxBytes int
+		want     string
+	}{
+		"age only":   {maxAge: "1h"},
+		"bytes only": {maxBytes: 1 << 30},
+		"both": {
+			maxAge:   "1h",
+			maxBytes: 1 << 30,
+			want:     `RetentionLimits Stream "orders" keeps messages for 1h0m0s or up to 1073741824 bytes, whichever is reached first: below 298262 bytes/s maxBytes is never reached`,
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := clientsetfake.NewSimpleClientset()
+			rec := record.NewFakeRecorder(10)
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: jc,
+				Recorder:       rec,
+			})
+
+			ns, name := "default", "orders"
+
+			informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+			err := informer.Informer().GetStore().Add(&apis.Stream{
+				ObjectMeta: k8smeta.ObjectMeta{
+					Namespace:  ns,
+					Name:       name,
+					Generation: 1,
+				},
+				Spec: apis.StreamSpec{
+					Name:     name,
+					MaxAge:   tt.maxAge,
+					MaxBytes: tt.maxBytes,
+					Storage:  "memory",
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+				return true, a.(k8stesting.UpdateAction).GetObject(), nil
+			})
+
+			jsmc := &mockJsmClient{
+				loadStreamErr: jsmapi.ApiError{Code: 404},
+				newStream:     &mockStream{},
+			}
+			if err := ctrl.processStream(ns, name, jsmc); err != nil {
+				t.Fatal(err)
+			}
+
+			var events []string
+			for len(rec.Events) > 0 {
+				events = append(events, <-rec.Events)
+			}
+			all := strings.Join(events, "\n")
+			if tt.want == "" {
+				if strings.Contains(all, "RetentionLimits") {
+					t.Fatalf("got events:\n%s\nwant no RetentionLimits event", all)
+				}
+				return
+			}
+			if !strings.Contains(all, tt.want) {
+				t.Fatalf("missing event %q in:\n%s", tt.want, all)
+			}
+		})
+	}
+}
+
+func TestProcessStreamRetentionLimitsUpdate(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "orders"
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:     name,
+			Subjects: []string{"orders.*"},
+			MaxAge:   "1h",
+			MaxBytes: 1 << 30,
+			Storage:  "memory",
+		},
+		Status: apis.Status{ObservedGeneration: 1},
+	}
+	if err := store.Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{
+			Name:     name,
+			Subjects: []string{"orders.new"},
+			MaxAge:   time.Hour,
+			MaxBytes: 1 << 30,
+			Storage:  jsmapi.MemoryStorage,
+		},
+	}
+	jsmc := &mockJsmClient{loadStream: ms}
+	retentionEvents := func() int {
+		var n int
+		for len(rec.Events) > 0 {
+			if strings.Contains(<-rec.Events, "RetentionLimits") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Updates leaving the limits alone don't repeat the advisory.
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if got := retentionEvents(); got != 0 {
+		t.Fatalf("got=%d RetentionLimits events; want=0", got)
+	}
+
+	str = str.DeepCopy()
+	str.Generation = 3
+	str.Spec.MaxAge = "2h"
+	if err := store.Update(str); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if got := retentionEvents(); got != 1 {
+		t.Fatalf("got=%d RetentionLimits events; want=1", got)
+	}
+}
+
+func TestStreamSpecToConfigMaxMsgSize(t *testing.T) {
+	t.Parallel()
+
+	current := jsmapi.StreamConfig{Name: "orders", Storage: jsmapi.MemoryStorage, MaxMsgSize: -1}
+	desired, err := streamSpecToConfig(apis.StreamSpec{Name: "orders", Storage: "memory", MaxMsgSize: 1024 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, changed := mergeStreamConfig(current, desired)
+	if got, want := config.MaxMsgSize, int32(1024*1024); got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	if !reflect.DeepEqual(changed, []string{"MaxMsgSize"}) {
+		t.Fatalf("unexpected changed fields: %v", changed)
+	}
+
+	for _, size := range []int{-2, math.MaxInt32 + 1} {
+		if _, err := streamSpecToConfig(apis.StreamSpec{Name: "orders", MaxMsgSize: size}); err == nil {
+			t.Errorf("expected error for max message size %d", size)
+		}
+	}
+}
+
+func TestGetMaxAge(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"-1h", "forever"} {
+		if _, err := getMaxAge(v); err == nil {
+			t.Fatalf("expected error parsing max age %q", v)
+		}
+	}
+}
+
+func TestGetStreamSource(t *testing.T) {
+	t.Parallel()
+
+	ss, err := getStreamSource(&apis.StreamSource{
+		Name:           "orders",
+		OptStartTime:   "2020-09-16T00:42:03Z",
+		ExternalDomain: "hub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 9, 16, 0, 42, 3, 0, time.UTC); ss.OptStartTime == nil || !ss.OptStartTime.Equal(want) {
+		t.Fatalf("got=%v; want=%s", ss.OptStartTime, want)
+	}
+	if ss.OptStartSeq != 0 {
+		t.Fatalf("got=%d; want no start sequence", ss.OptStartSeq)
+	}
+	if ss.External == nil || ss.External.ApiPrefix != "$JS.hub.API" {
+		t.Fatalf("got=%+v; want the API prefix of the hub domain", ss.External)
+	}
+
+	for name, src := range map[string]*apis.StreamSource{
+		"seq and time":      {Name: "orders", OptStartSeq: 10, OptStartTime: "2020-09-16T00:42:03Z"},
+		"prefix and domain": {Name: "orders", ExternalAPIPrefix: "$JS.hub.API", ExternalDomain: "hub"},
+		"invalid time":      {Name: "orders", OptStartTime: "yesterday"},
+	} {
+		if _, err := getStreamSource(src); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestProcessStreamSourceTargets(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "aggregate"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 2,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			MaxAge:  "1h",
+			Storage: "memory",
+			Sources: []*apis.StreamSource{
+				{Name: "orders"},
+				{Name: "orders", ExternalDomain: "hub"},
+				{Name: "refunds"},
+				{Name: "payments"},
+			},
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotTargets []apis.TargetStatus
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject()
+		gotTargets = obj.(*apis.Stream).Status.Targets
+		return true, obj, nil
+	})
+
+	ms := &mockStream{
+		info: &jsmapi.StreamInfo{
+			Sources: []*jsmapi.StreamSourceInfo{
+				{Name: "orders", Lag: 3},
+				{Name: "orders", External: &jsmapi.ExternalStream{ApiPrefix: "$JS.hub.API"}, Lag: 7},
+				{Name: "refunds", Error: &jsmapi.ApiError{Code: 404, Description: "stream not found"}},
+			},
+		},
+	}
+	jsmc := &mockJsmClient{loadStream: ms}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []apis.TargetStatus{
+		{Name: "orders", Status: k8sapi.ConditionTrue, Reason: "Active", Message: "3 messages behind"},
+		{Name: "orders", Status: k8sapi.ConditionTrue, Reason: "Active", Message: "7 messages behind"},
+		{Name: "refunds", Status: k8sapi.ConditionFalse, Reason: "Errored", Message: "stream not found"},
+		{Name: "payments", Status: k8sapi.ConditionUnknown, Reason: "Pending", Message: "Not reported by NATS yet"},
+	}
+	if !reflect.DeepEqual(gotTargets, want) {
+		t.Error("unexpected targets")
+		t.Fatalf("got=%+v; want=%+v", gotTargets, want)
+	}
+
+	var gotErrored bool
+	for len(rec.Events) > 0 {
+		if strings.Contains(<-rec.Events, `SourceErrored Source "refunds"`) {
+			gotErrored = true
+		}
+	}
+	if !gotErrored {
+		t.Fatal("missing SourceErrored event")
+	}
+
+	// Reconciles with nothing to do refresh the targets as well, without
+	// warning about sources that were already errored.
+	str, _, err := informer.Informer().GetStore().GetByKey(ns + "/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconciled := str.(*apis.Stream).DeepCopy()
+	reconciled.Status.ObservedGeneration = 2
+	reconciled.Status.Targets = gotTargets
+	if err := informer.Informer().GetStore().Update(reconciled); err != nil {
+		t.Fatal(err)
+	}
+	ms.info.Sources = append(ms.info.Sources, &jsmapi.StreamSourceInfo{Name: "payments"})
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if got := gotTargets[3]; got.Name != "payments" || got.Status != k8sapi.ConditionTrue {
+		t.Fatalf("got=%+v; want payments active", got)
+	}
+	for len(rec.Events) > 0 {
+		if e := <-rec.Events; strings.Contains(e, "SourceErrored") {
+			t.Fatalf("got %q again", e)
+		}
+	}
+}
+
+func TestMergeStreamConfig(t *testing.T) {
+	t.Parallel()
+
+	current := jsmapi.StreamConfig{
+		Name:      "orders",
+		Subjects:  []string{"orders.*"},
+		MaxBytes:  1024,
+		DenyPurge: true,
+	}
+	desired := jsmapi.StreamConfig{
+		Name:     "orders",
+		Subjects: []string{"orders.*"},
+		MaxBytes: 2048,
+		Sources:  []*jsmapi.StreamSource{},
+	}
+
+	merged, changed := mergeStreamConfig(current, desired)
+	if got, want := strings.Join(changed, ","), "MaxBytes"; got != want {
+		t.Error("unexpected changed fields")
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+	if merged.MaxBytes != 2048 || !merged.DenyPurge {
+		t.Error("unexpected merged config")
+		t.Fatalf("got=%+v", merged)
+	}
+}
+
+func TestCheckRepublishExported(t *testing.T) {
+	t.Parallel()
+
+	acc := &apis.Account{
+		ObjectMeta: k8smeta.ObjectMeta{Name: "orders-account"},
+		Spec: apis.AccountSpec{
+			Exports: []string{"public.orders.>"},
+		},
+	}
+
+	tests := map[string]struct {
+		acc         *apis.Account
+		destination string
+		wantWarning bool
+	}{
+		"exported destination": {
+			acc:         acc,
+			destination: "public.orders.created",
+		},
+		"mapped destination within export": {
+			acc:         acc,
+			destination: "public.orders.{{wildcard(1)}}",
+		},
+		"destination outside of exports": {
+			acc:         acc,
+			destination: "internal.orders.created",
+			wantWarning: true,
+		},
+		"account without exports": {
+			acc:         &apis.Account{ObjectMeta: k8smeta.ObjectMeta{Name: "other"}},
+			destination: "internal.orders.created",
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := record.NewFakeRecorder(10)
+			ctrl := NewController(Options{
+				Ctx:            context.Background(),
+				KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+				JetstreamIface: clientsetfake.NewSimpleClientset(),
+				Recorder:       rec,
+			})
+
+			str := &apis.Stream{
+				Spec: apis.StreamSpec{
+					Name: "orders",
+					Republish: &apis.RePublish{
+						Source:      "orders.>",
+						Destination: tt.destination,
+					},
+				},
+			}
+			ctrl.checkRepublishExported(str, tt.acc)
+
+			if !tt.wantWarning {
+				if len(rec.Events) > 0 {
+					t.Fatalf("unexpected event: %s", <-rec.Events)
+				}
+				return
+			}
+			if len(rec.Events) == 0 {
+				t.Fatal("expected a warning")
+			}
+			want := `Warning RepublishNotExported Republish destination "internal.orders.created" of stream "orders" is not exported by account "orders-account"`
+			if got := <-rec.Events; got != want {
+				t.Fatalf("got=%s; want=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestProcessStreamInvalidSubject(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	ns, name := "default", "orders"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:     name,
+			Storage:  "memory",
+			Subjects: []string{"orders.*", "orders.created.>.eu"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err == nil {
+		t.Fatal("expected invalid subject error")
+	}
+	if got := jsmc.loadStreamCalls; got != 0 {
+		t.Fatalf("got=%d NATS calls; want=0", got)
+	}
+
+	var gotInvalid bool
+	for len(rec.Events) > 0 {
+		if strings.Contains(<-rec.Events, `InvalidSubject Stream "orders" has an invalid subject "orders.created.>.eu" at index 1`) {
+			gotInvalid = true
+		}
+	}
+	if !gotInvalid {
+		t.Fatal("missing InvalidSubject event")
+	}
+}
+
+func TestProcessStreamRepublishHeadersOnly(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       record.NewFakeRecorder(10),
+	})
+
+	ns, name := "default", "orders"
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:     name,
+			Storage:  "memory",
+			Subjects: []string{"orders.*"},
+			Republish: &apis.RePublish{
+				Source:      "orders.created",
+				Destination: "audit.orders.created",
+				HeadersOnly: true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		newStream:     &mockStream{},
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg jsmapi.StreamConfig
+	for _, opt := range jsmc.newStreamOpts {
+		if err := opt(&cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := &jsmapi.RePublish{Source: "orders.created", Destination: "audit.orders.created", HeadersOnly: true}
+	if !reflect.DeepEqual(cfg.RePublish, want) {
+		t.Fatalf("got=%+v; want=%+v", cfg.RePublish, want)
+	}
+}
+
+func TestValidateRepublish(t *testing.T) {
+	t.Parallel()
+
+	subjects := []string{"orders.*"}
+	tests := map[string]struct {
+		rp      *apis.RePublish
+		wantErr string
+	}{
+		"none":             {},
+		"headers only":     {rp: &apis.RePublish{Destination: "audit.>", HeadersOnly: true}},
+		"mapped":           {rp: &apis.RePublish{Source: "orders.*", Destination: "audit.{{wildcard(1)}}"}},
+		"no destination":   {rp: &apis.RePublish{Source: "orders.*", HeadersOnly: true}, wantErr: "without a destination"},
+		"invalid source":   {rp: &apis.RePublish{Source: "orders..created", Destination: "audit.>"}, wantErr: `invalid republish source "orders..created"`},
+		"overlapping":      {rp: &apis.RePublish{Destination: "orders.audit"}, wantErr: `overlapping stream subject "orders.*"`},
+		"mapped overlap":   {rp: &apis.RePublish{Destination: "orders.{{wildcard(1)}}"}, wantErr: "overlapping"},
+		"invalid wildcard": {rp: &apis.RePublish{Destination: "audit.>.x"}, wantErr: "invalid republish destination"},
+	}
+	for name, tt := range tests {
+		err := validateRepublish(apis.StreamSpec{Subjects: subjects, Republish: tt.rp})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got=%v; want=%s", name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProcessStreamStatusRefreshInterval(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        jc,
+		Recorder:              record.NewFakeRecorder(10),
+		StatusRefreshInterval: time.Hour,
+	})
+
+	ns, name := "default", "orders"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	}
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	if err := store.Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	var writes []*apis.LiveState
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		writes = append(writes, obj.Status.State)
+		// Keep the informer cache in sync with the written status.
+		if err := store.Update(obj); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	ms := &mockStream{
+		info: &jsmapi.StreamInfo{
+			Config: jsmapi.StreamConfig{Name: name, Storage: jsmapi.MemoryStorage},
+			State:  jsmapi.StreamState{Msgs: 5, Bytes: 500},
+		},
+	}
+	jsmc := &mockJsmClient{loadStream: ms}
+	process := func() *apis.LiveState {
+		t.Helper()
+		if err := ctrl.processStream(ns, name, jsmc); err != nil {
+			t.Fatal(err)
+		}
+		return writes[len(writes)-1]
+	}
+
+	if got := process(); got == nil || got.Messages != 5 || got.Bytes != 500 {
+		t.Fatalf("got=%+v; want 5 messages, 500 bytes", got)
+	}
+
+	// Within the interval the live state isn't refreshed.
+	ms.info.State = jsmapi.StreamState{Msgs: 10, Bytes: 1000}
+	if got := process(); got == nil || got.Messages != 5 {
+		t.Fatalf("got=%+v; want 5 messages", got)
+	}
+
+	// Once the interval has passed it is.
+	obj, _, _ := store.GetByKey(ns + "/" + name)
+	stale := obj.(*apis.Stream).DeepCopy()
+	stale.Status.State.RefreshedAt = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	if err := store.Update(stale); err != nil {
+		t.Fatal(err)
+	}
+	if got := process(); got == nil || got.Messages != 10 || got.Bytes != 1000 {
+		t.Fatalf("got=%+v; want 10 messages, 1000 bytes", got)
+	}
+}
+
+func TestProcessStreamNearCapacity(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                    context.Background(),
+		KubeIface:              k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:         jc,
+		Recorder:               rec,
+		StatusRefreshInterval:  time.Nanosecond,
+		CapacityWarningPercent: 80,
+	})
+
+	ns, name := "default", "orders"
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	err := store.Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:     name,
+			Storage:  "memory",
+			MaxBytes: 1000,
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state *apis.LiveState
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		state = obj.Status.State
+		if err := store.Update(obj); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	ms := &mockStream{
+		info: &jsmapi.StreamInfo{
+			Config: jsmapi.StreamConfig{Name: name, Storage: jsmapi.MemoryStorage, MaxBytes: 1000},
+			State:  jsmapi.StreamState{Bytes: 500},
+		},
+	}
+	jsmc := &mockJsmClient{loadStream: ms}
+	nearCapacityEvents := func() (n int) {
+		for len(rec.Events) > 0 {
+			if strings.Contains(<-rec.Events, "NearCapacity") {
+				n++
+			}
+		}
+		return n
+	}
+
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.NearCapacity {
+		t.Fatalf("got=%+v; want a stream below capacity", state)
+	}
+	if got := nearCapacityEvents(); got != 0 {
+		t.Fatalf("got=%d; want no NearCapacity events", got)
+	}
+
+	// Crossing the threshold warns once, later refreshes don't repeat it.
+	ms.info.State.Bytes = 850
+	for i := 0; i < 2; i++ {
+		if err := ctrl.processStream(ns, name, jsmc); err != nil {
+			t.Fatal(err)
+		}
+		if !state.NearCapacity {
+			t.Fatalf("got=%+v; want a stream near capacity", state)
+		}
+	}
+	if got := nearCapacityEvents(); got != 1 {
+		t.Fatalf("got=%d; want 1 NearCapacity event", got)
+	}
+}
+
+func TestProcessStreamDiscardNewFull(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        jc,
+		Recorder:              rec,
+		StatusRefreshInterval: time.Nanosecond,
+	})
+
+	ns, name := "default", "orders"
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	err := store.Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:       name,
+			Storage:    "memory",
+			Discard:    "new",
+			MaxBytes:   1000,
+			MaxMsgSize: 100,
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var full bool
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		obj := a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		full = obj.Status.DiscardNewFull
+		if err := store.Update(obj); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	ms := &mockStream{
+		info: &jsmapi.StreamInfo{
+			Config: jsmapi.StreamConfig{
+				Name:       name,
+				Storage:    jsmapi.MemoryStorage,
+				Discard:    jsmapi.DiscardNew,
+				MaxBytes:   1000,
+				MaxMsgSize: 100,
+			},
+			State: jsmapi.StreamState{Bytes: 900},
+		},
+	}
+	jsmc := &mockJsmClient{loadStream: ms}
+	fullEvents := func() (n int) {
+		for len(rec.Events) > 0 {
+			if strings.Contains(<-rec.Events, "DiscardNewFull") {
+				n++
+			}
+		}
+		return n
+	}
+
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if full {
+		t.Fatal("got a full stream; want room for another message")
+	}
+	if got := fullEvents(); got != 0 {
+		t.Fatalf("got=%d; want no DiscardNewFull events", got)
+	}
+
+	// Reaching capacity is reported once, even after space frees up again.
+	ms.info.State.Bytes = 950
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	ms.info.State.Bytes = 500
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	ms.info.State.Bytes = 1000
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if !full {
+		t.Fatal("got discardNewFull unset; want it set once the stream was full")
+	}
+	if got := fullEvents(); got != 1 {
+		t.Fatalf("got=%d; want 1 DiscardNewFull event", got)
+	}
+}
+
+func TestProcessStreamConflictingStreamName(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: jc,
+		Recorder:       rec,
+	})
+
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for _, key := range []struct{ ns, name string }{{"team-a", "orders"}, {"team-b", "orders-copy"}} {
+		err := store.Add(&apis.Stream{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:  key.ns,
+				Name:       key.name,
+				Generation: 1,
+			},
+			Spec: apis.StreamSpec{
+				Name:    "orders",
+				Storage: "memory",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	jsmc := &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+	}
+	err := ctrl.processStream("team-a", "orders", jsmc)
+	if want := `Stream "orders" is also managed by team-b/orders-copy`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("got=%v; want=%s", err, want)
+	}
+	if got := jsmc.loadStreamCalls; got != 0 {
+		t.Fatalf("got=%d NATS calls; want=0", got)
+	}
+
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	all := strings.Join(events, "\n")
+	for _, want := range []string{
+		`ConflictingStreamName Stream "orders" is also managed by team-a/orders`,
+		`ConflictingStreamName Stream "orders" is also managed by team-b/orders-copy, refusing to reconcile until resolved`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("missing event %q in:\n%s", want, all)
+		}
+	}
+
+	// The override annotation lets both reconcile, e.g. across accounts.
+	obj, _, _ := store.GetByKey("team-a/orders")
+	allowed := obj.(*apis.Stream).DeepCopy()
+	allowed.Annotations = map[string]string{allowStreamNameConflictAnnotation: "true"}
+	if err := store.Update(allowed); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctrl.checkStreamNameConflict(allowed, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := store.GetByKey("team-b/orders-copy")
+	if err := ctrl.checkStreamNameConflict(other.(*apis.Stream), "orders"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessStreamQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                    context.Background(),
+		KubeIface:              k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:         jc,
+		Recorder:               rec,
+		MaxStreamsPerNamespace: 1,
+	})
+
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for i, name := range []string{"orders", "billing"} {
+		err := store.Add(&apis.Stream{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:         "team-a",
+				Name:              name,
+				Generation:        1,
+				CreationTimestamp: k8smeta.Unix(1600216923+int64(i), 0),
+			},
+			Spec: apis.StreamSpec{
+				Name:    strings.ToUpper(name),
+				Storage: "memory",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	drain := func() string {
+		var events []string
+		for len(rec.Events) > 0 {
+			events = append(events, <-rec.Events)
+		}
+		return strings.Join(events, "\n")
+	}
+
+	// The newer resource is over the quota, whichever is reconciled first.
+	err := ctrl.processStream("team-a", "billing", &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		newStream:     &mockStream{},
+	})
+	if want := `limited to 1 streams, refusing to create stream "BILLING"`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("got=%v; want=%s", err, want)
+	}
+	if all := drain(); !strings.Contains(all, "QuotaExceeded") || strings.Contains(all, "Created") {
+		t.Fatalf("got events:\n%s\nwant a QuotaExceeded event and no Created event", all)
+	}
+
+	err = ctrl.processStream("team-a", "orders", &mockJsmClient{
+		loadStreamErr: jsmapi.ApiError{Code: 404},
+		newStream:     &mockStream{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := drain(); !strings.Contains(all, "Created") {
+		t.Fatalf("got events:\n%s\nwant a Created event", all)
+	}
+
+	// A stream that already exists is still updated.
+	if err := ctrl.processStream("team-a", "billing", &mockJsmClient{}); err != nil {
+		t.Fatal(err)
+	}
+	if all := drain(); strings.Contains(all, "QuotaExceeded") {
+		t.Fatalf("got events:\n%s\nwant no QuotaExceeded event", all)
+	}
+}
+
+func TestProcessStreamReconcileOnChangeOnly(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        jc,
+		Recorder:              record.NewFakeRecorder(10),
+		ReconcileOnChangeOnly: true,
+	})
+
+	ns, name := "default", "orders"
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			Storage: "memory",
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+			Conditions: []apis.Condition{{
+				Type:   readyCondType,
+				Status: k8sapi.ConditionTrue,
+			}},
+		},
+	}
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	if err := store.Add(str); err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// A resync of the reconciled generation doesn't reach NATS.
+	jsmc := &mockJsmClient{}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if got := jsmc.loadStreamCalls; got != 0 {
+		t.Fatalf("got=%d NATS calls; want=0", got)
+	}
+
+	// A new generation is reconciled.
+	edited := str.DeepCopy()
+	edited.Generation = 2
+	edited.Spec.MaxAge = "1h"
+	if err := store.Update(edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctrl.processStream(ns, name, jsmc); err != nil {
+		t.Fatal(err)
+	}
+	if got := jsmc.loadStreamCalls; got == 0 {
+		t.Fatal("got no NATS calls; want the new generation reconciled")
+	}
+}
+
+func TestProcessStreamRequeueAfterSuccess(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:                   context.Background(),
+		KubeIface:             k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:        jc,
+		Recorder:              record.NewFakeRecorder(10),
+		StreamRequeueInterval: 50 * time.Millisecond,
+	})
+	defer ctrl.strQueue.ShutDown()
+
+	ns, name := "default", "orders"
+	store := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	err := store.Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:    name,
+			Storage: "memory",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		return true, a.(k8stesting.UpdateAction).GetObject(), nil
+	})
+
+	// A failed reconcile isn't requeued this way, the queue backs off.
+	if err := ctrl.processStream(ns, name, &mockJsmClient{loadStreamErr: errors.New("boom")}); err == nil {
+		t.Fatal("expected error")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := ctrl.strQueue.Len(); got != 0 {
+		t.Fatalf("got=%d queued; want=0 after a failed reconcile", got)
+	}
+
+	if err := ctrl.processStream(ns, name, &mockJsmClient{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ctrl.strQueue.Len(); got != 0 {
+		t.Fatalf("got=%d queued; want the requeue delayed", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ctrl.strQueue.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream wasn't requeued after a successful reconcile")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	item, _ := ctrl.strQueue.Get()
+	if item != objectKey(ns, name) {
+		t.Fatalf("got=%v; want=%s", item, objectKey(ns, name))
+	}
+}
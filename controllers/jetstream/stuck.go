@@ -0,0 +1,80 @@
+package jetstream
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	klog "k8s.io/klog/v2"
+)
+
+// stuckTerminatingVar is the expvar name of the stuck terminating gauge.
+const stuckTerminatingVar = "jetstream_stuck_terminating_resources"
+
+// PublishMetrics publishes the controller metrics with expvar, to be served
+// under /debug/vars. It must be called at most once per process.
+func (c *Controller) PublishMetrics() {
+	expvar.Publish(stuckTerminatingVar, c.stuckTerminating)
+	expvar.Publish(reconcilesVar, c.metrics.total)
+	expvar.Publish(reconcileErrorsVar, c.metrics.errors)
+	expvar.Publish(ackDelayVar, c.acks.delays)
+}
+
+// watchStuckTerminating periodically looks for streams and consumers stuck
+// terminating.
+func (c *Controller) watchStuckTerminating() {
+	tick := time.NewTicker(c.opts.CleanupPeriod)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case now := <-tick.C:
+			c.checkStuckTerminating(now)
+		}
+	}
+}
+
+// checkStuckTerminating warns about each stream and consumer deleted for
+// longer than the StuckTerminatingThreshold that still has finalizers, and
+// sets the stuck terminating gauge to their number.
+func (c *Controller) checkStuckTerminating(now time.Time) {
+	var stuck int64
+	check := func(o runtime.Object, meta k8smeta.Object) {
+		deleted := meta.GetDeletionTimestamp()
+		if deleted == nil || len(meta.GetFinalizers()) == 0 {
+			return
+		}
+		age := now.Sub(deleted.Time)
+		if age < c.opts.StuckTerminatingThreshold {
+			return
+		}
+		stuck++
+		c.warningEvent(o, "StuckTerminating", fmt.Sprintf("Deleted %s ago but still has finalizers %v",
+			age.Truncate(time.Second), meta.GetFinalizers()))
+	}
+
+	streams, err := c.strLister.List(labels.Everything())
+	if err != nil {
+		klog.Infof("failed to list streams stuck terminating: %s", err)
+		return
+	}
+	for _, s := range streams {
+		check(s, s)
+	}
+
+	consumers, err := c.cnsLister.List(labels.Everything())
+	if err != nil {
+		klog.Infof("failed to list consumers stuck terminating: %s", err)
+		return
+	}
+	for _, cns := range consumers {
+		check(cns, cns)
+	}
+
+	c.stuckTerminating.Set(stuck)
+}
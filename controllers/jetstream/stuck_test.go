@@ -0,0 +1,107 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCheckStuckTerminating(t *testing.T) {
+	t.Parallel()
+
+	rec := record.NewFakeRecorder(10)
+	ctrl := NewController(Options{
+		Ctx:                       context.Background(),
+		KubeIface:                 k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:            clientsetfake.NewSimpleClientset(),
+		Recorder:                  rec,
+		StuckTerminatingThreshold: 10 * time.Minute,
+	})
+
+	now := time.Now()
+	deletedAt := func(ago time.Duration) *k8smeta.Time {
+		t := k8smeta.NewTime(now.Add(-ago))
+		return &t
+	}
+
+	streams := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for _, s := range []*apis.Stream{
+		{
+			// Stuck for an hour.
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:         "default",
+				Name:              "stuck",
+				DeletionTimestamp: deletedAt(time.Hour),
+				Finalizers:        []string{"example.com/cleanup"},
+			},
+		},
+		{
+			// Only just deleted.
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace:         "default",
+				Name:              "terminating",
+				DeletionTimestamp: deletedAt(time.Minute),
+				Finalizers:        []string{"example.com/cleanup"},
+			},
+		},
+		{
+			ObjectMeta: k8smeta.ObjectMeta{
+				Namespace: "default",
+				Name:      "live",
+			},
+		},
+	} {
+		if err := streams.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consumers := ctrl.informerFactory.Jetstream().V1beta2().Consumers().Informer().GetStore()
+	err := consumers.Add(&apis.Consumer{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:         "default",
+			Name:              "stuck",
+			DeletionTimestamp: deletedAt(2 * time.Hour),
+			Finalizers:        []string{"example.com/cleanup"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl.checkStuckTerminating(now)
+
+	if got, want := ctrl.stuckTerminating.Value(), int64(2); got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+	if got, want := len(rec.Events), 2; got != want {
+		t.Fatalf("got=%d events; want=%d", got, want)
+	}
+	want := "Warning StuckTerminating Deleted 1h0m0s ago but still has finalizers [example.com/cleanup]"
+	if got := <-rec.Events; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+
+	// Once the finalizers are gone, the gauge drops back.
+	if err := streams.Update(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:         "default",
+			Name:              "stuck",
+			DeletionTimestamp: deletedAt(time.Hour),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctrl.checkStuckTerminating(now)
+
+	if got, want := ctrl.stuckTerminating.Value(), int64(1); got != want {
+		t.Fatalf("got=%d; want=%d", got, want)
+	}
+}
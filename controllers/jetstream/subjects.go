@@ -0,0 +1,120 @@
+package jetstream
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+
+	k8sapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// validateSubject returns why subject isn't a valid NATS subject, in which
+// the * and > wildcards may only be whole tokens and > only the last one.
+func validateSubject(subject string) error {
+	if subject == "" {
+		return fmt.Errorf("empty subject")
+	}
+	if i := strings.IndexAny(subject, " \t\r\n"); i >= 0 {
+		return fmt.Errorf("whitespace at position %d", i)
+	}
+
+	tokens := strings.Split(subject, ".")
+	for i, t := range tokens {
+		switch {
+		case t == "":
+			return fmt.Errorf("empty token %d, check for leading, trailing or double dots", i+1)
+		case t == ">" && i != len(tokens)-1:
+			return fmt.Errorf("wildcard > in token %d isn't the last token", i+1)
+		case t != "*" && t != ">" && strings.ContainsAny(t, "*>"):
+			return fmt.Errorf("wildcard in token %q isn't the whole token", t)
+		}
+	}
+	return nil
+}
+
+// validateSubjects checks every subject, pointing at the first invalid one.
+func validateSubjects(subjects []string) error {
+	for i, s := range subjects {
+		if err := validateSubject(s); err != nil {
+			return fmt.Errorf("invalid subject %q at index %d: %w", s, i, err)
+		}
+	}
+	return nil
+}
+
+// dedupeSubjects returns subjects without duplicates, keeping the first
+// occurrence of each, and the duplicates that were dropped.
+func dedupeSubjects(subjects []string) (deduped, dropped []string) {
+	seen := make(map[string]struct{}, len(subjects))
+	deduped = make([]string, 0, len(subjects))
+	for _, s := range subjects {
+		if _, ok := seen[s]; ok {
+			dropped = append(dropped, s)
+			continue
+		}
+		seen[s] = struct{}{}
+		deduped = append(deduped, s)
+	}
+	return deduped, dropped
+}
+
+// subjectsFrom returns the subjects of spec followed by those in its
+// subjectsFrom ConfigMap key, if any, along with the resourceVersion of that
+// ConfigMap.
+func (c *Controller) subjectsFrom(ns string, spec apis.StreamSpec) (subjects []string, version string, err error) {
+	ref := spec.SubjectsFrom
+	if ref == nil {
+		return spec.Subjects, "", nil
+	}
+	if !c.opts.SubjectsFromConfigMaps {
+		return nil, "", errors.New("subjectsFrom needs the controller to watch ConfigMaps, with -subjects-from-configmaps")
+	}
+
+	cm, err := c.cmLister.ConfigMaps(ns).Get(ref.Name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get subjects from ConfigMap %q: %w", ref.Name, err)
+	}
+	data, ok := cm.Data[ref.Key]
+	if !ok {
+		return nil, "", fmt.Errorf("subjects key %q not found in ConfigMap %q", ref.Key, ref.Name)
+	}
+
+	subjects = append([]string{}, spec.Subjects...)
+	for _, s := range strings.FieldsFunc(data, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if s = strings.TrimSpace(s); s != "" {
+			subjects = append(subjects, s)
+		}
+	}
+	return subjects, cm.ResourceVersion, nil
+}
+
+// enqueueSubjectsFromStreams queues the streams taking subjects from a changed
+// ConfigMap.
+func (c *Controller) enqueueSubjectsFromStreams(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	cm, ok := obj.(*k8sapi.ConfigMap)
+	if !ok {
+		return
+	}
+
+	streams, err := c.strLister.Streams(cm.Namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, str := range streams {
+		if ref := str.Spec.SubjectsFrom; ref == nil || ref.Name != cm.Name {
+			continue
+		}
+		if err := enqueueWork(c.strQueue, str); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package jetstream
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	k8sapi "k8s.io/api/core/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestValidateSubject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		subject string
+		wantErr string
+	}{
+		{"orders", ""},
+		{"orders.*.eu", ""},
+		{"orders.>", ""},
+		{">", ""},
+		{"", "empty subject"},
+		{".orders", "empty token 1"},
+		{"orders.", "empty token 2"},
+		{"orders..eu", "empty token 2"},
+		{"orders created", "whitespace at position 6"},
+		{"orders.\tcreated", "whitespace at position 7"},
+		{"orders.>.eu", "wildcard > in token 2 isn't the last token"},
+		{"orders.eu*", `wildcard in token "eu*" isn't the whole token`},
+		{"orders.>>", `wildcard in token ">>" isn't the whole token`},
+	}
+	for _, tt := range tests {
+		err := validateSubject(tt.subject)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %s", tt.subject, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q: got=%v; want=%s", tt.subject, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSubjects(t *testing.T) {
+	t.Parallel()
+
+	err := validateSubjects([]string{"orders.*", "payments..eu"})
+	if want := `invalid subject "payments..eu" at index 1: empty token 2`; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("got=%v; want=%s", err, want)
+	}
+}
+
+func TestDedupeSubjects(t *testing.T) {
+	t.Parallel()
+
+	deduped, dropped := dedupeSubjects([]string{"a", "b", "a", "c", "b"})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(deduped, want) {
+		t.Fatalf("got=%v; want=%v", deduped, want)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(dropped, want) {
+		t.Fatalf("got=%v; want=%v", dropped, want)
+	}
+}
+
+func TestProcessStreamSubjectsFrom(t *testing.T) {
+	t.Parallel()
+
+	jc := clientsetfake.NewSimpleClientset()
+	ctrl := NewController(Options{
+		Ctx:                    context.Background(),
+		KubeIface:              k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface:         jc,
+		Recorder:               record.NewFakeRecorder(10),
+		SubjectsFromConfigMaps: true,
+	})
+
+	cm := &k8sapi.ConfigMap{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:       "default",
+			Name:            "shared-subjects",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			"subjects": "orders.eu\norders.us, orders.apac\n\n",
+		},
+	}
+	cms := ctrl.cmInformerFactory.Core().V1().ConfigMaps().Informer().GetStore()
+	if err := cms.Add(cm); err != nil {
+		t.Fatal(err)
+	}
+
+	str := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace:  "default",
+			Name:       "orders",
+			Generation: 1,
+		},
+		Spec: apis.StreamSpec{
+			Name:         "orders",
+			Storage:      "memory",
+			Subjects:     []string{"orders.local"},
+			SubjectsFrom: &apis.ConfigMapKeyRef{Name: "shared-subjects", Key: "subjects"},
+		},
+		Status: apis.Status{
+			ObservedGeneration: 1,
+		},
+	}
+	other := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{Namespace: "default", Name: "billing"},
+		Spec:       apis.StreamSpec{Name: "billing"},
+	}
+	strs := ctrl.informerFactory.Jetstream().V1beta2().Streams().Informer().GetStore()
+	for _, s := range []*apis.Stream{str, other} {
+		if err := strs.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var written *apis.Stream
+	jc.PrependReactor("update", "streams", func(a k8stesting.Action) (handled bool, o runtime.Object, err error) {
+		written = a.(k8stesting.UpdateAction).GetObject().(*apis.Stream)
+		return true, written, nil
+	})
+
+	// The ConfigMap subjects are applied even though the generation was
+	// already observed, as they were never applied.
+	ms := &mockStream{
+		config: jsmapi.StreamConfig{Name: "orders", Storage: jsmapi.MemoryStorage, Subjects: []string{"orders.local"}},
+	}
+	if err := ctrl.processStream("default", "orders", &mockJsmClient{loadStream: ms}); err != nil {
+		t.Fatal(err)
+	}
+	if ms.updatedConfig == nil {
+		t.Fatal("stream wasn't updated")
+	}
+	want := []string{"orders.local", "orders.eu", "orders.us", "orders.apac"}
+	if got := ms.updatedConfig.Subjects; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v; want=%v", got, want)
+	}
+	if got := written.Status.SubjectsFromVersion; got != "1" {
+		t.Fatalf("got=%q; want the applied ConfigMap version", got)
+	}
+
+	// Changing the ConfigMap only queues the streams taking subjects from it.
+	ctrl.enqueueSubjectsFromStreams(cm)
+	if got := ctrl.strQueue.Len(); got != 1 {
+		t.Fatalf("got=%d; want=1 queued stream", got)
+	}
+	if key, _ := ctrl.strQueue.Get(); key != "default/orders" {
+		t.Fatalf("got=%v; want=default/orders", key)
+	}
+}
+
+func TestSubjectsFromNeedsConfigMaps(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+	})
+	_, _, err := ctrl.subjectsFrom("default", apis.StreamSpec{
+		SubjectsFrom: &apis.ConfigMapKeyRef{Name: "shared-subjects", Key: "subjects"},
+	})
+	if err == nil {
+		t.Fatal("got no error; want subjectsFrom refused without watching ConfigMaps")
+	}
+}
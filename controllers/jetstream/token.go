@@ -0,0 +1,57 @@
+package jetstream
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	klog "k8s.io/klog/v2"
+)
+
+// JWTProvider mints NATS user JWTs on demand, for instance from an external
+// token broker, so that no long-lived credentials have to be stored.
+// UserJWT is called on every connect and reconnect, and Sign then signs the
+// server's nonce with the key of the user of the JWT it returned.
+type JWTProvider interface {
+	UserJWT() (string, error)
+	Sign(nonce []byte) ([]byte, error)
+}
+
+// tokenFileOption authenticates with the token in path, read on every
+// connect so that a rotated token, like a projected service account token,
+// is used once the previous one expires. The last token read is kept if the
+// file can't be read again.
+func tokenFileOption(path string) (nats.Option, error) {
+	token, err := readToken(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	return nats.TokenHandler(func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		next, err := readToken(path)
+		if err != nil {
+			klog.Warningf("failed to read token, using the previous one: %s", err)
+			return token
+		}
+		token = next
+		return token
+	}), nil
+}
+
+func readToken(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %q is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,118 @@
+package jetstream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestTokenFileOption(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opt, err := tokenFileOption(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opts nats.Options
+	if err := opt(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := opts.TokenHandler(), "first"; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+
+	// A rotated token is used on the next connect.
+	if err := os.WriteFile(path, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := opts.TokenHandler(), "second"; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+
+	// The last token is kept while the file can't be read.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := opts.TokenHandler(), "second"; got != want {
+		t.Fatalf("got=%s; want=%s", got, want)
+	}
+}
+
+func TestTokenFileOptionMissing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := tokenFileOption(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("unexpected success")
+	}
+}
+
+type stubJWTProvider struct {
+	calls int
+}
+
+func (p *stubJWTProvider) UserJWT() (string, error) {
+	p.calls++
+	return fmt.Sprintf("jwt-%d", p.calls), nil
+}
+
+func (p *stubJWTProvider) Sign(nonce []byte) ([]byte, error) {
+	return append([]byte("signed:"), nonce...), nil
+}
+
+func TestAuthOptionsJWTProvider(t *testing.T) {
+	t.Parallel()
+
+	provider := &stubJWTProvider{}
+	c := &Controller{opts: Options{JWTProvider: provider}}
+	authOpts, err := c.authOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts nats.Options
+	for _, opt := range authOpts {
+		if err := opt(&opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opts.UserJWT == nil || opts.SignatureCB == nil {
+		t.Fatal("JWT provider isn't wired into the connection options")
+	}
+
+	// A fresh JWT is minted on every connect, after the one the option
+	// mints to check the provider.
+	for _, want := range []string{"jwt-2", "jwt-3"} {
+		if got, err := opts.UserJWT(); err != nil || got != want {
+			t.Fatalf("got=%s, %v; want=%s", got, err, want)
+		}
+	}
+	if got, err := opts.SignatureCB([]byte("nonce")); err != nil || string(got) != "signed:nonce" {
+		t.Fatalf("got=%s, %v; want=signed:nonce", got, err)
+	}
+
+	// Credentials take precedence over the provider, their option reads the
+	// creds file instead of minting a JWT.
+	c.opts.NATSCredentials = filepath.Join(t.TempDir(), "missing.creds")
+	authOpts, err = c.authOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authOpts) != 1 {
+		t.Fatalf("got=%d; want=1 auth option", len(authOpts))
+	}
+	if err := authOpts[0](&nats.Options{}); err == nil || !strings.Contains(err.Error(), "missing.creds") {
+		t.Fatalf("got=%v; want an error reading the creds file", err)
+	}
+	if provider.calls != 3 {
+		t.Fatalf("got=%d; want=3 provider calls", provider.calls)
+	}
+}
@@ -0,0 +1,102 @@
+package jetstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	admissionv1 "k8s.io/api/admission/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	klog "k8s.io/klog/v2"
+)
+
+// allowSubjectOverlapAnnotation lets a stream overlap the subjects of other
+// managed streams, e.g. when they live in different accounts.
+const allowSubjectOverlapAnnotation = "jetstream.nats.io/allow-subject-overlap"
+
+// ValidateStreams is an admission webhook handler that rejects streams with a
+// name NATS doesn't allow, or whose subjects overlap with those of another
+// managed stream, which NATS would refuse to create.
+func (c *Controller) ValidateStreams(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+
+	resp := &admissionv1.AdmissionResponse{
+		UID:     review.Request.UID,
+		Allowed: true,
+	}
+	var str apis.Stream
+	if err := json.Unmarshal(review.Request.Object.Raw, &str); err != nil {
+		resp.Allowed = false
+		resp.Result = &k8smeta.Status{Message: fmt.Sprintf("invalid stream: %s", err)}
+	} else if err := validateName("stream name", str.Spec.Name); err != nil {
+		resp.Allowed = false
+		resp.Result = &k8smeta.Status{Message: err.Error()}
+	} else if err := c.validateStreamSubjects(&str); err != nil {
+		resp.Allowed = false
+		resp.Result = &k8smeta.Status{Message: err.Error()}
+	}
+	review.Response = resp
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.Infof("failed to write admission response: %s", err)
+	}
+}
+
+// validateStreamSubjects returns an error naming the first invalid subject of
+// str, or the first managed stream with a subject overlapping those of str
+// unless str allows the overlap.
+func (c *Controller) validateStreamSubjects(str *apis.Stream) error {
+	if err := validateSubjects(str.Spec.Subjects); err != nil {
+		return err
+	}
+	if str.Annotations[allowSubjectOverlapAnnotation] == "true" {
+		return nil
+	}
+
+	streams, err := c.strLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	for _, other := range streams {
+		if other.Namespace == str.Namespace && other.Name == str.Name {
+			continue
+		}
+		if other.DeletionTimestamp != nil {
+			continue
+		}
+		for _, a := range str.Spec.Subjects {
+			for _, b := range other.Spec.Subjects {
+				if subjectsOverlap(a, b) {
+					return fmt.Errorf("subject %q overlaps with subject %q of stream %s/%s, set the %s annotation to allow it",
+						a, b, other.Namespace, other.Name, allowSubjectOverlapAnnotation)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// subjectsOverlap reports whether some subject matches both a and b, which
+// may contain the * and > wildcards.
+func subjectsOverlap(a, b string) bool {
+	at := strings.Split(a, ".")
+	bt := strings.Split(b, ".")
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		if at[i] == ">" || bt[i] == ">" {
+			return true
+		}
+		if at[i] != bt[i] && at[i] != "*" && bt[i] != "*" {
+			return false
+		}
+	}
+	return len(at) == len(bt)
+}
@@ -0,0 +1,175 @@
+package jetstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	apis "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	clientsetfake "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/fake"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sclientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSubjectsOverlap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "payments", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.created.eu", false},
+		{"orders.>", "orders.created.eu", true},
+		{"orders.>", "orders", false},
+		{"*.created", "orders.*", true},
+		{"orders.*.eu", "orders.created.us", false},
+		{">", "payments.created", true},
+	}
+
+	for _, tt := range tests {
+		if got := subjectsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("subjectsOverlap(%q, %q): got=%t; want=%t", tt.a, tt.b, got, tt.want)
+		}
+		if got := subjectsOverlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("subjectsOverlap(%q, %q): got=%t; want=%t", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestValidateStreams(t *testing.T) {
+	t.Parallel()
+
+	ctrl := NewController(Options{
+		Ctx:            context.Background(),
+		KubeIface:      k8sclientsetfake.NewSimpleClientset(),
+		JetstreamIface: clientsetfake.NewSimpleClientset(),
+		Recorder:       record.NewFakeRecorder(10),
+	})
+	defer os.RemoveAll(ctrl.cacheDir)
+
+	informer := ctrl.informerFactory.Jetstream().V1beta2().Streams()
+	err := informer.Informer().GetStore().Add(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "orders",
+		},
+		Spec: apis.StreamSpec{
+			Name:     "orders",
+			Subjects: []string{"orders.>"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	review := func(str *apis.Stream) *admissionv1.AdmissionResponse {
+		t.Helper()
+
+		raw, err := json.Marshal(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: k8smeta.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:    "review-uid",
+				Object: runtime.RawExtension{Raw: raw},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		ctrl.ValidateStreams(rr, httptest.NewRequest(http.MethodPost, "/validate-streams", bytes.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("got=%d; want=%d", rr.Code, http.StatusOK)
+		}
+
+		var got admissionv1.AdmissionReview
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Response == nil || got.Response.UID != "review-uid" {
+			t.Fatalf("unexpected response: %+v", got.Response)
+		}
+		return got.Response
+	}
+
+	overlapping := &apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "orders-eu",
+		},
+		Spec: apis.StreamSpec{
+			Name:     "orders-eu",
+			Subjects: []string{"orders.*.eu"},
+		},
+	}
+	resp := review(overlapping)
+	if resp.Allowed {
+		t.Fatal("expected overlapping stream to be rejected")
+	}
+	if want := `subject "orders.*.eu" overlaps with subject "orders.>" of stream default/orders`; !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got=%s; want=%s", resp.Result.Message, want)
+	}
+
+	overlapping.Annotations = map[string]string{allowSubjectOverlapAnnotation: "true"}
+	if resp := review(overlapping); !resp.Allowed {
+		t.Fatalf("expected allowed overlap, got: %s", resp.Result.Message)
+	}
+
+	// Invalid subjects are rejected regardless of the annotation.
+	overlapping.Spec.Subjects = []string{"orders.eu.", "orders.*.eu"}
+	resp = review(overlapping)
+	if resp.Allowed {
+		t.Fatal("expected stream with an invalid subject to be rejected")
+	}
+	if want := `invalid subject "orders.eu." at index 0`; !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got=%s; want=%s", resp.Result.Message, want)
+	}
+
+	// Names NATS doesn't allow are rejected.
+	resp = review(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "orders-us",
+		},
+		Spec: apis.StreamSpec{
+			Name:     "orders.us",
+			Subjects: []string{"orders-us.>"},
+		},
+	})
+	if resp.Allowed {
+		t.Fatal("expected stream with an invalid name to be rejected")
+	}
+	if want := `invalid stream name "orders.us": must not contain '.'`; !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got=%s; want=%s", resp.Result.Message, want)
+	}
+
+	// Updating the stream itself doesn't conflict with its current version.
+	if resp := review(&apis.Stream{
+		ObjectMeta: k8smeta.ObjectMeta{
+			Namespace: "default",
+			Name:      "orders",
+		},
+		Spec: apis.StreamSpec{
+			Name:     "orders",
+			Subjects: []string{"orders.*"},
+		},
+	}); !resp.Allowed {
+		t.Fatalf("expected update to be allowed, got: %s", resp.Result.Message)
+	}
+}
@@ -22,9 +22,14 @@ func (c *Account) GetSpec() interface{} {
 
 // AccountSpec is the spec for a Account resource
 type AccountSpec struct {
-	Servers []string     `json:"servers"`
-	TLS     *TLSSecret   `json:"tls"`
-	Creds   *CredsSecret `json:"creds"`
+	Servers []string       `json:"servers"`
+	TLS     *TLSSecret     `json:"tls"`
+	Creds   *CredsSecret   `json:"creds"`
+	Context *ContextSecret `json:"context"`
+
+	// Exports are the subjects the account exports to other accounts. When
+	// set, streams republishing outside of them are warned about.
+	Exports []string `json:"exports"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
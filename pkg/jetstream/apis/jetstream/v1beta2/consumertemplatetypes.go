@@ -0,0 +1,41 @@
+package v1beta2
+
+import (
+	k8smeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ConsumerTemplate is a specification for a ConsumerTemplate resource
+type ConsumerTemplate struct {
+	k8smeta.TypeMeta   `json:",inline"`
+	k8smeta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConsumerTemplateSpec `json:"spec"`
+	Status Status               `json:"status"`
+}
+
+func (c *ConsumerTemplate) GetSpec() interface{} {
+	return c.Spec
+}
+
+// ConsumerTemplateSpec is the spec for a ConsumerTemplate resource. The
+// consumer spec is stamped out as a Consumer on each Stream matching the
+// selector, with streamName set to the name of that stream.
+type ConsumerTemplateSpec struct {
+	ConsumerSpec `json:",inline"`
+
+	StreamSelector *k8smeta.LabelSelector `json:"streamSelector"`
+	MaxConsumers   int                    `json:"maxConsumers"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ConsumerTemplateList is a list of ConsumerTemplate resources
+type ConsumerTemplateList struct {
+	k8smeta.TypeMeta `json:",inline"`
+	k8smeta.ListMeta `json:"metadata"`
+
+	Items []ConsumerTemplate `json:"items"`
+}
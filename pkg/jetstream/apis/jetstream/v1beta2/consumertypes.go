@@ -12,49 +12,72 @@ type Consumer struct {
 	k8smeta.TypeMeta   `json:",inline"`
 	k8smeta.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   ConsumerSpec `json:"spec"`
-	Status Status       `json:"status"`
+	Spec   ConsumerSpec   `json:"spec"`
+	Status ConsumerStatus `json:"status"`
 }
 
 func (c *Consumer) GetSpec() interface{} {
 	return c.Spec
 }
 
+// ConsumerStatus is the status of a Consumer resource
+type ConsumerStatus struct {
+	Status `json:",inline"`
+
+	// ConsumerName is the resolved durable name of the Consumer, as last
+	// accepted by NATS.
+	ConsumerName string `json:"consumerName,omitempty"`
+}
+
 // ConsumerSpec is the spec for a Consumer resource
 type ConsumerSpec struct {
-	AckPolicy          string   `json:"ackPolicy"`
-	AckWait            string   `json:"ackWait"`
-	BackOff            []string `json:"backoff"`
-	Creds              string   `json:"creds"`
-	DeliverGroup       string   `json:"deliverGroup"`
-	DeliverPolicy      string   `json:"deliverPolicy"`
-	DeliverSubject     string   `json:"deliverSubject"`
-	Description        string   `json:"description"`
-	PreventDelete      bool     `json:"preventDelete"`
-	PreventUpdate      bool     `json:"preventUpdate"`
-	DurableName        string   `json:"durableName"`
-	FilterSubject      string   `json:"filterSubject"`
-	FlowControl        bool     `json:"flowControl"`
-	HeadersOnly        bool     `json:"headersOnly"`
-	HeartbeatInterval  string   `json:"heartbeatInterval"`
-	MaxAckPending      int      `json:"maxAckPending"`
-	MaxDeliver         int      `json:"maxDeliver"`
-	MaxRequestBatch    int      `json:"maxRequestBatch"`
-	MaxRequestExpires  string   `json:"maxRequestExpires"`
-	MaxRequestMaxBytes int      `json:"maxRequestMaxBytes"`
-	MaxWaiting         int      `json:"maxWaiting"`
-	MemStorage         bool     `json:"memStorage"`
-	Nkey               string   `json:"nkey"`
-	OptStartSeq        int      `json:"optStartSeq"`
-	OptStartTime       string   `json:"optStartTime"`
-	RateLimitBps       int      `json:"rateLimitBps"`
-	ReplayPolicy       string   `json:"replayPolicy"`
-	Replicas           int      `json:"replicas"`
-	SampleFreq         string   `json:"sampleFreq"`
-	Servers            []string `json:"servers"`
-	StreamName         string   `json:"streamName"`
-	TLS                TLS      `json:"tls"`
-	Account            string   `json:"account"`
+	AckPolicy            string            `json:"ackPolicy"`
+	AckWait              string            `json:"ackWait"`
+	AllowRecreate        bool              `json:"allowRecreate"`
+	UpdateStrategy       string            `json:"updateStrategy,omitempty"`
+	BackOff              []string          `json:"backoff"`
+	Creds                string            `json:"creds"`
+	DeliverGroup         string            `json:"deliverGroup"`
+	DeliverPolicy        string            `json:"deliverPolicy"`
+	DeliverSubject       string            `json:"deliverSubject"`
+	DeadLetterSubject    string            `json:"deadLetterSubject,omitempty"`
+	Description          string            `json:"description"`
+	PreventDelete        bool              `json:"preventDelete"`
+	PreventUpdate        bool              `json:"preventUpdate"`
+	DurableName          string            `json:"durableName"`
+	FilterSubject        string            `json:"filterSubject"`
+	FlowControl          bool              `json:"flowControl"`
+	HeadersOnly          bool              `json:"headersOnly"`
+	HeartbeatInterval    string            `json:"heartbeatInterval"`
+	MaxAckPending        int               `json:"maxAckPending"`
+	MaxDeliver           int               `json:"maxDeliver"`
+	MaxRequestBatch      int               `json:"maxRequestBatch"`
+	MaxRequestExpires    string            `json:"maxRequestExpires"`
+	MaxRequestMaxBytes   int               `json:"maxRequestMaxBytes"`
+	MaxWaiting           int               `json:"maxWaiting"`
+	MemStorage           bool              `json:"memStorage"`
+	NATSOptions          map[string]string `json:"natsOptions"`
+	Nkey                 string            `json:"nkey"`
+	OptStartSeq          int               `json:"optStartSeq"`
+	OptStartTime         string            `json:"optStartTime"`
+	ProcessingSLA        *ProcessingSLA    `json:"processingSLA"`
+	RateLimitBps         int               `json:"rateLimitBps"`
+	RecreateFromAckFloor bool              `json:"recreateFromAckFloor"`
+	ReplayPolicy         string            `json:"replayPolicy"`
+	Replicas             int               `json:"replicas"`
+	SampleFreq           string            `json:"sampleFreq"`
+	Servers              []string          `json:"servers"`
+	StreamName           string            `json:"streamName"`
+	TLS                  TLS               `json:"tls"`
+	Account              string            `json:"account"`
+}
+
+// ProcessingSLA derives the ackWait and maxAckPending of a Consumer from how
+// long its subscribers may take to process a message and how many messages
+// they process per second.
+type ProcessingSLA struct {
+	Duration   string `json:"duration"`
+	Throughput int    `json:"throughput"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -35,6 +35,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&StreamList{},
 		&Consumer{},
 		&ConsumerList{},
+		&ConsumerTemplate{},
+		&ConsumerTemplateList{},
 		&Account{},
 		&AccountList{},
 	)
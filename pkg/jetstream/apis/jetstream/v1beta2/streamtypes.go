@@ -22,35 +22,45 @@ func (s *Stream) GetSpec() interface{} {
 
 // StreamSpec is the spec for a Stream resource
 type StreamSpec struct {
-	Account           string           `json:"account"`
-	AllowDirect       bool             `json:"allowDirect"`
-	AllowRollup       bool             `json:"allowRollup"`
-	Creds             string           `json:"creds"`
-	DenyDelete        bool             `json:"denyDelete"`
-	Description       string           `json:"description"`
-	PreventDelete     bool             `json:"preventDelete"`
-	PreventUpdate     bool             `json:"preventUpdate"`
-	Discard           string           `json:"discard"`
-	DuplicateWindow   string           `json:"duplicateWindow"`
-	MaxAge            string           `json:"maxAge"`
-	MaxBytes          int              `json:"maxBytes"`
-	MaxConsumers      int              `json:"maxConsumers"`
-	MaxMsgs           int              `json:"maxMsgs"`
-	MaxMsgSize        int              `json:"maxMsgSize"`
-	MaxMsgsPerSubject int              `json:"maxMsgsPerSubject"`
-	Mirror            *StreamSource    `json:"mirror"`
-	Name              string           `json:"name"`
-	Nkey              string           `json:"nkey"`
-	NoAck             bool             `json:"noAck"`
-	Placement         *StreamPlacement `json:"placement"`
-	Replicas          int              `json:"replicas"`
-	Republish         *RePublish       `json:"republish"`
-	Retention         string           `json:"retention"`
-	Servers           []string         `json:"servers"`
-	Sources           []*StreamSource  `json:"sources"`
-	Storage           string           `json:"storage"`
-	Subjects          []string         `json:"subjects"`
-	TLS               TLS              `json:"tls"`
+	Account           string            `json:"account"`
+	AllowDirect       bool              `json:"allowDirect"`
+	AllowRollup       bool              `json:"allowRollup"`
+	Creds             string            `json:"creds"`
+	DenyDelete        bool              `json:"denyDelete"`
+	Description       string            `json:"description"`
+	PreventDelete     bool              `json:"preventDelete"`
+	PreventUpdate     bool              `json:"preventUpdate"`
+	Discard           string            `json:"discard"`
+	DuplicateWindow   string            `json:"duplicateWindow"`
+	MaxAge            string            `json:"maxAge"`
+	MaxBytes          int               `json:"maxBytes"`
+	MaxConsumers      int               `json:"maxConsumers"`
+	MaxMsgs           int               `json:"maxMsgs"`
+	MaxMsgSize        int               `json:"maxMsgSize"`
+	MaxMsgsPerSubject int               `json:"maxMsgsPerSubject"`
+	Mirror            *StreamSource     `json:"mirror"`
+	Name              string            `json:"name"`
+	NATSOptions       map[string]string `json:"natsOptions"`
+	Nkey              string            `json:"nkey"`
+	NoAck             bool              `json:"noAck"`
+	Placement         *StreamPlacement  `json:"placement"`
+	Replicas          int               `json:"replicas"`
+	Republish         *RePublish        `json:"republish"`
+	Retention         string            `json:"retention"`
+	Servers           []string          `json:"servers"`
+	Sources           []*StreamSource   `json:"sources"`
+	Storage           string            `json:"storage"`
+	Subjects          []string          `json:"subjects"`
+	TLS               TLS               `json:"tls"`
+
+	// SubjectsFrom is a ConfigMap key in the namespace of the Stream holding
+	// more subjects, separated by newlines or commas, added to Subjects.
+	SubjectsFrom *ConfigMapKeyRef `json:"subjectsFrom,omitempty"`
+}
+
+type ConfigMapKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
 type StreamPlacement struct {
@@ -66,6 +76,9 @@ type StreamSource struct {
 
 	ExternalAPIPrefix     string `json:"externalApiPrefix"`
 	ExternalDeliverPrefix string `json:"externalDeliverPrefix"`
+	// ExternalDomain is the JetStream domain of the source, used to derive
+	// the external API prefix.
+	ExternalDomain string `json:"externalDomain,omitempty"`
 }
 
 type RePublish struct {
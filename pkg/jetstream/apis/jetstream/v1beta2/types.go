@@ -12,6 +12,71 @@ type CredentialsSecret struct {
 type Status struct {
 	ObservedGeneration int64       `json:"observedGeneration"`
 	Conditions         []Condition `json:"conditions"`
+
+	// ObservedSpecHash is a hash of the spec last acted on, to tell a spec
+	// edited during a reconcile from the one it acted on.
+	ObservedSpecHash string `json:"observedSpecHash,omitempty"`
+
+	// StreamCreated is the creation time of the stream a Consumer was
+	// created on, to tell when that stream has since been replaced.
+	StreamCreated string `json:"streamCreated,omitempty"`
+
+	// Targets is the state of each source or mirror of a Stream, so a
+	// partially failing stream shows which of them are unhealthy.
+	Targets []TargetStatus `json:"targets,omitempty"`
+
+	// State is the live state of a Stream or Consumer in NATS, refreshed at
+	// most once per status refresh interval.
+	State *LiveState `json:"state,omitempty"`
+
+	// Domain is the JetStream domain a Stream or Consumer is managed in,
+	// when not the one of the server the controller connects to.
+	Domain string `json:"domain,omitempty"`
+
+	// SubjectsFromVersion is the resourceVersion of the subjectsFrom
+	// ConfigMap last applied to a Stream.
+	SubjectsFromVersion string `json:"subjectsFromVersion,omitempty"`
+
+	// DiscardNewFull is set once a Stream with discard new has filled its
+	// maxBytes, so that the event explaining publishes are now rejected is
+	// only recorded the first time.
+	DiscardNewFull bool `json:"discardNewFull,omitempty"`
+}
+
+// LiveState is a snapshot of the state of a Stream or Consumer in NATS.
+type LiveState struct {
+	// Messages and Bytes are stored in a Stream.
+	Messages uint64 `json:"messages,omitempty"`
+	Bytes    uint64 `json:"bytes,omitempty"`
+
+	// NumPending is the number of messages left for a Consumer to deliver,
+	// and NumAckPending those delivered but not acknowledged yet.
+	NumPending    uint64 `json:"numPending,omitempty"`
+	NumAckPending int    `json:"numAckPending,omitempty"`
+
+	// NumRedelivered is the number of messages a Consumer redelivered, and
+	// AckFloor the stream sequence up to which all are acknowledged.
+	NumRedelivered int    `json:"numRedelivered,omitempty"`
+	AckFloor       uint64 `json:"ackFloor,omitempty"`
+
+	// StuckRefreshes counts the consecutive refreshes that found a Consumer
+	// redelivering more messages while its AckFloor stayed put.
+	StuckRefreshes int `json:"stuckRefreshes,omitempty"`
+
+	// NearCapacity is set when a Stream's bytes have crossed the capacity
+	// warning threshold of its maxBytes.
+	NearCapacity bool `json:"nearCapacity,omitempty"`
+
+	// RefreshedAt is when the state was last refreshed, in RFC3339.
+	RefreshedAt string `json:"refreshedAt"`
+}
+
+// TargetStatus is the state of one source or mirror of a Stream.
+type TargetStatus struct {
+	Name    string                 `json:"name"`
+	Status  k8sapi.ConditionStatus `json:"status"`
+	Reason  string                 `json:"reason"`
+	Message string                 `json:"message"`
 }
 
 type Condition struct {
@@ -38,6 +103,17 @@ type TLSSecret struct {
 type CredsSecret struct {
 	File   string    `json:"file"`
 	Secret SecretRef `json:"secret"`
+
+	// Source is the secret backend the creds are read from, a Kubernetes
+	// secret when empty. Other backends are plugged into the controller.
+	Source string `json:"source,omitempty"`
+}
+
+// ContextSecret is a nats CLI context file stored in a secret, along with
+// any files it refers to.
+type ContextSecret struct {
+	File   string    `json:"file"`
+	Secret SecretRef `json:"secret"`
 }
 
 type SecretRef struct {
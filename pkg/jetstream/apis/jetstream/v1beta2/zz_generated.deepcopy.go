@@ -19,6 +19,7 @@
 package v1beta2
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -101,6 +102,16 @@ func (in *AccountSpec) DeepCopyInto(out *AccountSpec) {
 		*out = new(CredsSecret)
 		**out = **in
 	}
+	if in.Context != nil {
+		in, out := &in.Context, &out.Context
+		*out = new(ContextSecret)
+		**out = **in
+	}
+	if in.Exports != nil {
+		in, out := &in.Exports, &out.Exports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -130,6 +141,22 @@ func (in *Condition) DeepCopy() *Condition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyRef) DeepCopyInto(out *ConfigMapKeyRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyRef.
+func (in *ConfigMapKeyRef) DeepCopy() *ConfigMapKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Consumer) DeepCopyInto(out *Consumer) {
 	*out = *in
@@ -199,6 +226,18 @@ func (in *ConsumerSpec) DeepCopyInto(out *ConsumerSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NATSOptions != nil {
+		in, out := &in.NATSOptions, &out.NATSOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ProcessingSLA != nil {
+		in, out := &in.ProcessingSLA, &out.ProcessingSLA
+		*out = new(ProcessingSLA)
+		**out = **in
+	}
 	if in.Servers != nil {
 		in, out := &in.Servers, &out.Servers
 		*out = make([]string, len(*in))
@@ -218,6 +257,123 @@ func (in *ConsumerSpec) DeepCopy() *ConsumerSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsumerStatus) DeepCopyInto(out *ConsumerStatus) {
+	*out = *in
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsumerStatus.
+func (in *ConsumerStatus) DeepCopy() *ConsumerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsumerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsumerTemplate) DeepCopyInto(out *ConsumerTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsumerTemplate.
+func (in *ConsumerTemplate) DeepCopy() *ConsumerTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsumerTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConsumerTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsumerTemplateList) DeepCopyInto(out *ConsumerTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ConsumerTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsumerTemplateList.
+func (in *ConsumerTemplateList) DeepCopy() *ConsumerTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsumerTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConsumerTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsumerTemplateSpec) DeepCopyInto(out *ConsumerTemplateSpec) {
+	*out = *in
+	in.ConsumerSpec.DeepCopyInto(&out.ConsumerSpec)
+	if in.StreamSelector != nil {
+		in, out := &in.StreamSelector, &out.StreamSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsumerTemplateSpec.
+func (in *ConsumerTemplateSpec) DeepCopy() *ConsumerTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsumerTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContextSecret) DeepCopyInto(out *ContextSecret) {
+	*out = *in
+	out.Secret = in.Secret
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContextSecret.
+func (in *ContextSecret) DeepCopy() *ContextSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(ContextSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CredentialsSecret) DeepCopyInto(out *CredentialsSecret) {
 	*out = *in
@@ -251,6 +407,38 @@ func (in *CredsSecret) DeepCopy() *CredsSecret {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LiveState) DeepCopyInto(out *LiveState) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LiveState.
+func (in *LiveState) DeepCopy() *LiveState {
+	if in == nil {
+		return nil
+	}
+	out := new(LiveState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProcessingSLA) DeepCopyInto(out *ProcessingSLA) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProcessingSLA.
+func (in *ProcessingSLA) DeepCopy() *ProcessingSLA {
+	if in == nil {
+		return nil
+	}
+	out := new(ProcessingSLA)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RePublish) DeepCopyInto(out *RePublish) {
 	*out = *in
@@ -291,6 +479,16 @@ func (in *Status) DeepCopyInto(out *Status) {
 		*out = make([]Condition, len(*in))
 		copy(*out, *in)
 	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.State != nil {
+		in, out := &in.State, &out.State
+		*out = new(LiveState)
+		**out = **in
+	}
 	return
 }
 
@@ -410,6 +608,13 @@ func (in *StreamSpec) DeepCopyInto(out *StreamSpec) {
 		*out = new(StreamSource)
 		**out = **in
 	}
+	if in.NATSOptions != nil {
+		in, out := &in.NATSOptions, &out.NATSOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Placement != nil {
 		in, out := &in.Placement, &out.Placement
 		*out = new(StreamPlacement)
@@ -442,6 +647,11 @@ func (in *StreamSpec) DeepCopyInto(out *StreamSpec) {
 		copy(*out, *in)
 	}
 	in.TLS.DeepCopyInto(&out.TLS)
+	if in.SubjectsFrom != nil {
+		in, out := &in.SubjectsFrom, &out.SubjectsFrom
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
 	return
 }
 
@@ -496,3 +706,19 @@ func (in *TLSSecret) DeepCopy() *TLSSecret {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetStatus.
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
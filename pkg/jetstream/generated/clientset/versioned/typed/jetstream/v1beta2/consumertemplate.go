@@ -0,0 +1,192 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta2
+
+import (
+	"context"
+	"time"
+
+	v1beta2 "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	scheme "github.com/nats-io/nack/pkg/jetstream/generated/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ConsumerTemplatesGetter has a method to return a ConsumerTemplateInterface.
+// A group's client should implement this interface.
+type ConsumerTemplatesGetter interface {
+	ConsumerTemplates(namespace string) ConsumerTemplateInterface
+}
+
+// ConsumerTemplateInterface has methods to work with ConsumerTemplate resources.
+type ConsumerTemplateInterface interface {
+	Create(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.CreateOptions) (*v1beta2.ConsumerTemplate, error)
+	Update(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.UpdateOptions) (*v1beta2.ConsumerTemplate, error)
+	UpdateStatus(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.UpdateOptions) (*v1beta2.ConsumerTemplate, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1beta2.ConsumerTemplate, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1beta2.ConsumerTemplateList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta2.ConsumerTemplate, err error)
+	ConsumerTemplateExpansion
+}
+
+// consumerTemplates implements ConsumerTemplateInterface
+type consumerTemplates struct {
+	client rest.Interface
+	ns     string
+}
+
+// newConsumerTemplates returns a ConsumerTemplates
+func newConsumerTemplates(c *JetstreamV1beta2Client, namespace string) *consumerTemplates {
+	return &consumerTemplates{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the consumerTemplate, and returns the corresponding consumerTemplate object, and an error if there is any.
+func (c *consumerTemplates) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	result = &v1beta2.ConsumerTemplate{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ConsumerTemplates that match those selectors.
+func (c *consumerTemplates) List(ctx context.Context, opts v1.ListOptions) (result *v1beta2.ConsumerTemplateList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1beta2.ConsumerTemplateList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested consumerTemplates.
+func (c *consumerTemplates) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a consumerTemplate and creates it.  Returns the server's representation of the consumerTemplate, and an error, if there is any.
+func (c *consumerTemplates) Create(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.CreateOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	result = &v1beta2.ConsumerTemplate{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(consumerTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a consumerTemplate and updates it. Returns the server's representation of the consumerTemplate, and an error, if there is any.
+func (c *consumerTemplates) Update(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.UpdateOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	result = &v1beta2.ConsumerTemplate{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		Name(consumerTemplate.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(consumerTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *consumerTemplates) UpdateStatus(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.UpdateOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	result = &v1beta2.ConsumerTemplate{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		Name(consumerTemplate.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(consumerTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the consumerTemplate and deletes it. Returns an error if one occurs.
+func (c *consumerTemplates) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *consumerTemplates) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched consumerTemplate.
+func (c *consumerTemplates) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta2.ConsumerTemplate, err error) {
+	result = &v1beta2.ConsumerTemplate{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("consumertemplates").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta2 "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeConsumerTemplates implements ConsumerTemplateInterface
+type FakeConsumerTemplates struct {
+	Fake *FakeJetstreamV1beta2
+	ns   string
+}
+
+var consumertemplatesResource = schema.GroupVersionResource{Group: "jetstream.nats.io", Version: "v1beta2", Resource: "consumertemplates"}
+
+var consumertemplatesKind = schema.GroupVersionKind{Group: "jetstream.nats.io", Version: "v1beta2", Kind: "ConsumerTemplate"}
+
+// Get takes name of the consumerTemplate, and returns the corresponding consumerTemplate object, and an error if there is any.
+func (c *FakeConsumerTemplates) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(consumertemplatesResource, c.ns, name), &v1beta2.ConsumerTemplate{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.ConsumerTemplate), err
+}
+
+// List takes label and field selectors, and returns the list of ConsumerTemplates that match those selectors.
+func (c *FakeConsumerTemplates) List(ctx context.Context, opts v1.ListOptions) (result *v1beta2.ConsumerTemplateList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(consumertemplatesResource, consumertemplatesKind, c.ns, opts), &v1beta2.ConsumerTemplateList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta2.ConsumerTemplateList{ListMeta: obj.(*v1beta2.ConsumerTemplateList).ListMeta}
+	for _, item := range obj.(*v1beta2.ConsumerTemplateList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested consumerTemplates.
+func (c *FakeConsumerTemplates) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(consumertemplatesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a consumerTemplate and creates it.  Returns the server's representation of the consumerTemplate, and an error, if there is any.
+func (c *FakeConsumerTemplates) Create(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.CreateOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(consumertemplatesResource, c.ns, consumerTemplate), &v1beta2.ConsumerTemplate{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.ConsumerTemplate), err
+}
+
+// Update takes the representation of a consumerTemplate and updates it. Returns the server's representation of the consumerTemplate, and an error, if there is any.
+func (c *FakeConsumerTemplates) Update(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.UpdateOptions) (result *v1beta2.ConsumerTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(consumertemplatesResource, c.ns, consumerTemplate), &v1beta2.ConsumerTemplate{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.ConsumerTemplate), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeConsumerTemplates) UpdateStatus(ctx context.Context, consumerTemplate *v1beta2.ConsumerTemplate, opts v1.UpdateOptions) (*v1beta2.ConsumerTemplate, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(consumertemplatesResource, "status", c.ns, consumerTemplate), &v1beta2.ConsumerTemplate{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.ConsumerTemplate), err
+}
+
+// Delete takes name of the consumerTemplate and deletes it. Returns an error if one occurs.
+func (c *FakeConsumerTemplates) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(consumertemplatesResource, c.ns, name, opts), &v1beta2.ConsumerTemplate{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeConsumerTemplates) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(consumertemplatesResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta2.ConsumerTemplateList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched consumerTemplate.
+func (c *FakeConsumerTemplates) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta2.ConsumerTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(consumertemplatesResource, c.ns, name, pt, data, subresources...), &v1beta2.ConsumerTemplate{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.ConsumerTemplate), err
+}
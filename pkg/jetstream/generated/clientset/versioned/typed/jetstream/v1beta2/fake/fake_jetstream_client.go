@@ -33,6 +33,10 @@ func (c *FakeJetstreamV1beta2) Consumers(namespace string) v1beta2.ConsumerInter
 	return &FakeConsumers{c, namespace}
 }
 
+func (c *FakeJetstreamV1beta2) ConsumerTemplates(namespace string) v1beta2.ConsumerTemplateInterface {
+	return &FakeConsumerTemplates{c, namespace}
+}
+
 func (c *FakeJetstreamV1beta2) Streams(namespace string) v1beta2.StreamInterface {
 	return &FakeStreams{c, namespace}
 }
@@ -19,4 +19,6 @@ type AccountExpansion interface{}
 
 type ConsumerExpansion interface{}
 
+type ConsumerTemplateExpansion interface{}
+
 type StreamExpansion interface{}
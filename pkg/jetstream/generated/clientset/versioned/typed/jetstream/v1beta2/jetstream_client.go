@@ -27,6 +27,7 @@ type JetstreamV1beta2Interface interface {
 	RESTClient() rest.Interface
 	AccountsGetter
 	ConsumersGetter
+	ConsumerTemplatesGetter
 	StreamsGetter
 }
 
@@ -43,6 +44,10 @@ func (c *JetstreamV1beta2Client) Consumers(namespace string) ConsumerInterface {
 	return newConsumers(c, namespace)
 }
 
+func (c *JetstreamV1beta2Client) ConsumerTemplates(namespace string) ConsumerTemplateInterface {
+	return newConsumerTemplates(c, namespace)
+}
+
 func (c *JetstreamV1beta2Client) Streams(namespace string) StreamInterface {
 	return newStreams(c, namespace)
 }
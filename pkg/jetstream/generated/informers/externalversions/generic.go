@@ -54,6 +54,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Jetstream().V1beta2().Accounts().Informer()}, nil
 	case v1beta2.SchemeGroupVersion.WithResource("consumers"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Jetstream().V1beta2().Consumers().Informer()}, nil
+	case v1beta2.SchemeGroupVersion.WithResource("consumertemplates"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Jetstream().V1beta2().ConsumerTemplates().Informer()}, nil
 	case v1beta2.SchemeGroupVersion.WithResource("streams"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Jetstream().V1beta2().Streams().Informer()}, nil
 
@@ -25,6 +25,8 @@ type Interface interface {
 	Accounts() AccountInformer
 	// Consumers returns a ConsumerInformer.
 	Consumers() ConsumerInformer
+	// ConsumerTemplates returns a ConsumerTemplateInformer.
+	ConsumerTemplates() ConsumerTemplateInformer
 	// Streams returns a StreamInformer.
 	Streams() StreamInformer
 }
@@ -50,6 +52,11 @@ func (v *version) Consumers() ConsumerInformer {
 	return &consumerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// ConsumerTemplates returns a ConsumerTemplateInformer.
+func (v *version) ConsumerTemplates() ConsumerTemplateInformer {
+	return &consumerTemplateInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Streams returns a StreamInformer.
 func (v *version) Streams() StreamInformer {
 	return &streamInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
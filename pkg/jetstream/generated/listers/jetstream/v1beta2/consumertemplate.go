@@ -0,0 +1,96 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta2
+
+import (
+	v1beta2 "github.com/nats-io/nack/pkg/jetstream/apis/jetstream/v1beta2"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ConsumerTemplateLister helps list ConsumerTemplates.
+// All objects returned here must be treated as read-only.
+type ConsumerTemplateLister interface {
+	// List lists all ConsumerTemplates in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1beta2.ConsumerTemplate, err error)
+	// ConsumerTemplates returns an object that can list and get ConsumerTemplates.
+	ConsumerTemplates(namespace string) ConsumerTemplateNamespaceLister
+	ConsumerTemplateListerExpansion
+}
+
+// consumerTemplateLister implements the ConsumerTemplateLister interface.
+type consumerTemplateLister struct {
+	indexer cache.Indexer
+}
+
+// NewConsumerTemplateLister returns a new ConsumerTemplateLister.
+func NewConsumerTemplateLister(indexer cache.Indexer) ConsumerTemplateLister {
+	return &consumerTemplateLister{indexer: indexer}
+}
+
+// List lists all ConsumerTemplates in the indexer.
+func (s *consumerTemplateLister) List(selector labels.Selector) (ret []*v1beta2.ConsumerTemplate, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1beta2.ConsumerTemplate))
+	})
+	return ret, err
+}
+
+// ConsumerTemplates returns an object that can list and get ConsumerTemplates.
+func (s *consumerTemplateLister) ConsumerTemplates(namespace string) ConsumerTemplateNamespaceLister {
+	return consumerTemplateNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// ConsumerTemplateNamespaceLister helps list and get ConsumerTemplates.
+// All objects returned here must be treated as read-only.
+type ConsumerTemplateNamespaceLister interface {
+	// List lists all ConsumerTemplates in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1beta2.ConsumerTemplate, err error)
+	// Get retrieves the ConsumerTemplate from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1beta2.ConsumerTemplate, error)
+	ConsumerTemplateNamespaceListerExpansion
+}
+
+// consumerTemplateNamespaceLister implements the ConsumerTemplateNamespaceLister
+// interface.
+type consumerTemplateNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all ConsumerTemplates in the indexer for a given namespace.
+func (s consumerTemplateNamespaceLister) List(selector labels.Selector) (ret []*v1beta2.ConsumerTemplate, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1beta2.ConsumerTemplate))
+	})
+	return ret, err
+}
+
+// Get retrieves the ConsumerTemplate from the indexer for a given namespace and name.
+func (s consumerTemplateNamespaceLister) Get(name string) (*v1beta2.ConsumerTemplate, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1beta2.Resource("consumertemplate"), name)
+	}
+	return obj.(*v1beta2.ConsumerTemplate), nil
+}
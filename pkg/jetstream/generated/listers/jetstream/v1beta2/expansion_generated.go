@@ -31,6 +31,14 @@ type ConsumerListerExpansion interface{}
 // ConsumerNamespaceLister.
 type ConsumerNamespaceListerExpansion interface{}
 
+// ConsumerTemplateListerExpansion allows custom methods to be added to
+// ConsumerTemplateLister.
+type ConsumerTemplateListerExpansion interface{}
+
+// ConsumerTemplateNamespaceListerExpansion allows custom methods to be added to
+// ConsumerTemplateNamespaceLister.
+type ConsumerTemplateNamespaceListerExpansion interface{}
+
 // StreamListerExpansion allows custom methods to be added to
 // StreamLister.
 type StreamListerExpansion interface{}